@@ -18,18 +18,267 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/order/{order_uid}": {
+        "/api/schemas": {
             "get": {
-                "description": "Возвращает заказ по его уникальному идентификатору",
+                "description": "Возвращает субъекты JSON Schema сообщений с доступными и текущей версиями.\nВерсия сообщения передается в заголовке schema-version, старые версии приводятся к текущей",
                 "tags": [
-                    "order"
+                    "schemas"
                 ],
-                "summary": "Получить заказ по UID",
+                "summary": "Список схем сообщений",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/schema.Subject"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/schemas/{subject}/{version}": {
+            "get": {
+                "description": "Возвращает JSON Schema (draft 2020-12) версии субъекта",
+                "produces": [
+                    "application/schema+json"
+                ],
+                "tags": [
+                    "schemas"
+                ],
+                "summary": "Получить схему сообщения",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "UID заказа",
-                        "name": "order_uid",
+                        "description": "Субъект схемы, например order",
+                        "name": "subject",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Версия схемы",
+                        "name": "version",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "404": {
+                        "description": "schema not found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/consumer": {
+            "get": {
+                "description": "Возвращает состояние consumer брокера: running, draining или paused, и выполняющиеся replay",
+                "tags": [
+                    "consumer"
+                ],
+                "summary": "Состояние consumer",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/serverhandlers.ConsumerState"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/consumer/drain": {
+            "post": {
+                "description": "Останавливает получение сообщений из брокера и ждет завершения обрабатываемых сообщений.\nВыполняющиеся replay отменяются, их обработчики тоже ожидаются.\nЕсли они не завершились до таймаута запроса, consumer остается приостановленным",
+                "tags": [
+                    "consumer"
+                ],
+                "summary": "Дренировать consumer",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/serverhandlers.ConsumerState"
+                        }
+                    },
+                    "504": {
+                        "description": "handlers are still running",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/consumer/offsets": {
+            "post": {
+                "description": "Сдвигает offsets группы KAFKA_GROUP_ID для партиций потока на первые сообщения после timestamp\nили на явные offsets партиций. Подписки приложения на время сдвига останавливаются,\nдругие экземпляры приложения должны быть остановлены",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "consumer"
+                ],
+                "summary": "Сдвинуть offsets consumer group",
+                "parameters": [
+                    {
+                        "description": "Поток и timestamp или offsets",
+                        "name": "target",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/serverhandlers.OffsetsResetRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/serverhandlers.OffsetsResetResult"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid target",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "consumer group has active members",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/consumer/pause": {
+            "post": {
+                "description": "Останавливает получение сообщений из брокера, API продолжает работать.\nОбрабатываемые сообщения не прерываются. Выполняющиеся replay отменяются,\nновые replay не запускаются до resume",
+                "tags": [
+                    "consumer"
+                ],
+                "summary": "Приостановить consumer",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/serverhandlers.ConsumerState"
+                        }
+                    },
+                    "504": {
+                        "description": "request timed out",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/consumer/resume": {
+            "post": {
+                "description": "Возобновляет получение сообщений из брокера после pause или drain и разрешает запуск replay.\nОтмененные replay не продолжаются",
+                "tags": [
+                    "consumer"
+                ],
+                "summary": "Возобновить consumer",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/serverhandlers.ConsumerState"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/rejections": {
+            "get": {
+                "description": "Возвращает сообщения брокера, отклоненные обработчиком, новые первыми: исходный payload (base64),\nзаголовки, ошибки полей и позицию сообщения. Фильтруется по причине и интервалу дат [from, to)",
+                "tags": [
+                    "rejections"
+                ],
+                "summary": "Список отклоненных сообщений",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Причина: payload, content_type, decode, validation, rules, conflict",
+                        "name": "reason",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Начало интервала, RFC 3339",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Конец интервала (не включительно), RFC 3339",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Количество сообщений (по умолчанию 50, максимум 500)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Rejection"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid filter",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/rejections/{id}": {
+            "get": {
+                "description": "Возвращает отклоненное сообщение брокера по ID",
+                "tags": [
+                    "rejections"
+                ],
+                "summary": "Отклоненное сообщение",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID отклоненного сообщения",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
@@ -37,149 +286,1538 @@ const docTemplate = `{
                 "responses": {
                     "200": {
                         "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Rejection"
+                        }
+                    },
+                    "404": {
+                        "description": "rejection not found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/rejections/{id}/replay": {
+            "post": {
+                "description": "Сохраняет исправленный заказ отклоненного сообщения так же, как POST /api/v1/orders,\nи отмечает сообщение повторенным. Исходный payload не изменяется",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "rejections"
+                ],
+                "summary": "Повторить отклоненное сообщение",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID отклоненного сообщения",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Исправленный заказ",
+                        "name": "order",
+                        "in": "body",
+                        "required": true,
                         "schema": {
                             "$ref": "#/definitions/models.Order"
                         }
                     },
+                    {
+                        "type": "string",
+                        "description": "Версия схемы заказа",
+                        "name": "Schema-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "order updated or ignored",
+                        "schema": {
+                            "$ref": "#/definitions/serverhandlers.OrderSaveResult"
+                        }
+                    },
+                    "201": {
+                        "description": "order created",
+                        "schema": {
+                            "$ref": "#/definitions/serverhandlers.OrderSaveResult"
+                        }
+                    },
                     "400": {
-                        "description": "missing order uid",
+                        "description": "invalid order",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     },
                     "404": {
-                        "description": "order not found",
+                        "description": "rejection not found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     },
-                    "405": {
-                        "description": "method not allowed",
+                    "409": {
+                        "description": "order conflicts with another order",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "business rules violated",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     },
                     "500": {
                         "description": "internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/problem.Problem"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.Delivery": {
-            "description": "Delivery details for the order.",
-            "type": "object",
-            "required": [
-                "address",
-                "city",
-                "email",
-                "name",
-                "phone",
-                "region",
-                "zip"
-            ],
-            "properties": {
-                "address": {
-                    "description": "Address",
-                    "type": "string"
-                },
-                "city": {
-                    "description": "City",
+        },
+        "/api/v1/admin/replays": {
+            "get": {
+                "description": "Возвращает выполняющийся и последние завершенные replay, новые первыми",
+                "tags": [
+                    "consumer"
+                ],
+                "summary": "Список replay",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/replay.Job"
+                            }
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Запускает в фоне повторную обработку диапазона потока заказов отдельным consumer без группы,\noffsets группы не изменяются. Одновременно выполняется только один replay.\nСохраненные заказы с той же или более новой версией не перезаписываются",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "consumer"
+                ],
+                "summary": "Запустить replay",
+                "parameters": [
+                    {
+                        "description": "Начало и конец диапазона",
+                        "name": "range",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/serverhandlers.ReplayRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/replay.Job"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid range",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "another replay is running or consumer is paused",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/replays/{id}": {
+            "get": {
+                "description": "Возвращает состояние и количество обработанных сообщений replay",
+                "tags": [
+                    "consumer"
+                ],
+                "summary": "Состояние replay",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID replay",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/replay.Job"
+                        }
+                    },
+                    "404": {
+                        "description": "replay not found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/replays/{id}/cancel": {
+            "post": {
+                "description": "Отменяет выполняющийся replay. Уже обработанные сообщения не откатываются",
+                "tags": [
+                    "consumer"
+                ],
+                "summary": "Отменить replay",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID replay",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "replay not found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/webhooks": {
+            "get": {
+                "description": "Возвращает все webhook без секретов",
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Список webhook",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Webhook"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Подписывает URL на события заказов. Запросы подписываются HMAC-SHA256 с секретом webhook.\nПоддерживается только событие order.saved, неизвестные события отклоняются с 400\nСекрет не возвращается в ответах",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Создать webhook",
+                "parameters": [
+                    {
+                        "description": "Webhook (url, secret, events: order.saved, delivery_services)",
+                        "name": "webhook",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Webhook"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Webhook"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid webhook",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/webhooks/{id}": {
+            "delete": {
+                "description": "Удаляет webhook вместе с журналом доставок",
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Удалить webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID webhook",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/webhooks/{id}/deliveries": {
+            "get": {
+                "description": "Возвращает последние попытки доставки событий webhook, новые первыми",
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Журнал доставок webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID webhook",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Количество попыток (по умолчанию 50, максимум 500)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.WebhookDelivery"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "invalid limit",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/webhooks/{id}/enable": {
+            "post": {
+                "description": "Включает webhook, отключенный после неудачных доставок, и сбрасывает счетчик ошибок",
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Включить webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID webhook",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "webhook not found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/order/{order_uid}": {
+            "get": {
+                "description": "Возвращает заказ по его уникальному идентификатору.\nПерсональные данные получателя маскируются, если у клиента нет роли с полным доступом.\nСуммы в ответе указаны в минимальных единицах валюты (центах, копейках),\nв formatted они отформатированы для локали заказа.\nОтвет сжимается (zstd, gzip) по Accept-Encoding.\nС параметром wait запрос ждет, пока заказ будет получен из брокера и сохранен",
+                "tags": [
+                    "order"
+                ],
+                "summary": "Получить заказ по UID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID заказа",
+                        "name": "order_uid",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ждать сохранения заказа до указанного времени (например, 10s)",
+                        "name": "wait",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/serverhandlers.OrderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid wait",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "order not found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "405": {
+                        "description": "method not allowed",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "too many waiting requests",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "504": {
+                        "description": "request timed out",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/order/{order_uid}/history": {
+            "get": {
+                "description": "Возвращает все ревизии заказа с источником изменения (сообщение брокера или вызов API)\nи списком измененных полей относительно предыдущей ревизии.\nПерсональные данные получателя маскируются, если у клиента нет роли с полным доступом",
+                "tags": [
+                    "order"
+                ],
+                "summary": "История изменений заказа",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "UID заказа",
+                        "name": "order_uid",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/serverhandlers.OrderHistory"
+                        }
+                    },
+                    "404": {
+                        "description": "order not found",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "504": {
+                        "description": "request timed out",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/orders": {
+            "post": {
+                "description": "Сохраняет заказ так же, как сообщение из брокера: заказ проверяется по схеме и бизнес-правилам\n(сумма товаров, итоговая сумма, цены товаров со скидкой), новая версия заменяет сохраненную,\nа та же или меньшая версия игнорируется. Нарушения правил с действием warn возвращаются в warnings.\nЗаказ проверяется по JSON Schema версии из заголовка Schema-Version (без заголовка - самой старой)\nи приводится к текущей версии. В схеме v2 version обязательна и начинается с 1, каждое исправление\nзаказа должно иметь большую версию. Заказы v1 получают версию 1, поэтому их можно создать, но не исправить",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "order"
+                ],
+                "summary": "Сохранить заказ",
+                "parameters": [
+                    {
+                        "description": "Заказ",
+                        "name": "order",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Order"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Версия схемы заказа",
+                        "name": "Schema-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "order updated or ignored",
+                        "schema": {
+                            "$ref": "#/definitions/serverhandlers.OrderSaveResult"
+                        }
+                    },
+                    "201": {
+                        "description": "order created",
+                        "schema": {
+                            "$ref": "#/definitions/serverhandlers.OrderSaveResult"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid order",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "order conflicts with another order",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "business rules violated",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/orders/stream": {
+            "get": {
+                "description": "Отправляет события order (text/event-stream) для каждого сохраненного заказа.\nПоддерживает продолжение с Last-Event-ID из ограниченного буфера последних событий.\nМедленные клиенты отключаются",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "order"
+                ],
+                "summary": "Поток новых заказов (SSE)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Фильтр по службе доставки",
+                        "name": "delivery_service",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "summary",
+                            "full"
+                        ],
+                        "type": "string",
+                        "description": "summary (по умолчанию) или full",
+                        "name": "view",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID последнего полученного события",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/serverhandlers.OrderSummary"
+                        }
+                    },
+                    "400": {
+                        "description": "invalid view",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "too many stream clients",
+                        "schema": {
+                            "$ref": "#/definitions/problem.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Возвращает готовность API и состояние consumer брокера. Приостановленный consumer\nне делает сервис неготовым, так как API продолжает обслуживать запросы",
+                "tags": [
+                    "health"
+                ],
+                "summary": "Готовность сервиса",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/serverhandlers.Readiness"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "history.Change": {
+            "description": "Changed order field.",
+            "type": "object",
+            "properties": {
+                "field": {
+                    "description": "Field JSON path, for example delivery.phone or items[1].price",
+                    "type": "string"
+                },
+                "new": {
+                    "description": "New value, null if field was removed"
+                },
+                "old": {
+                    "description": "Previous value, null if field was added"
+                }
+            }
+        },
+        "models.Delivery": {
+            "description": "Delivery details for the order.",
+            "type": "object",
+            "required": [
+                "address",
+                "city",
+                "email",
+                "name",
+                "phone",
+                "region",
+                "zip"
+            ],
+            "properties": {
+                "address": {
+                    "description": "Address",
+                    "type": "string"
+                },
+                "city": {
+                    "description": "City",
+                    "type": "string"
+                },
+                "email": {
+                    "description": "Email",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Recipient name",
+                    "type": "string"
+                },
+                "phone": {
+                    "description": "Recipient phone",
+                    "type": "string"
+                },
+                "region": {
+                    "description": "Region",
+                    "type": "string"
+                },
+                "zip": {
+                    "description": "Postal code",
+                    "type": "string"
+                }
+            }
+        },
+        "models.Item": {
+            "description": "Product details in the order.",
+            "type": "object",
+            "required": [
+                "brand",
+                "chrt_id",
+                "name",
+                "nm_id",
+                "price",
+                "rid",
+                "sale",
+                "size",
+                "status",
+                "total_price",
+                "track_number"
+            ],
+            "properties": {
+                "brand": {
+                    "description": "Brand",
+                    "type": "string"
+                },
+                "chrt_id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "description": "Product name",
+                    "type": "string"
+                },
+                "nm_id": {
+                    "type": "integer"
+                },
+                "price": {
+                    "description": "Unit price",
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "rid": {
+                    "description": "RID",
+                    "type": "string"
+                },
+                "sale": {
+                    "description": "Discount (%)",
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 0
+                },
+                "size": {
+                    "description": "Size",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Status",
+                    "type": "integer"
+                },
+                "total_price": {
+                    "description": "Total price",
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "track_number": {
+                    "description": "Tracking number",
+                    "type": "string"
+                }
+            }
+        },
+        "models.Order": {
+            "description": "Order received from external system. Contains delivery, payment and items info.",
+            "type": "object",
+            "required": [
+                "customer_id",
+                "date_created",
+                "delivery",
+                "delivery_service",
+                "entry",
+                "items",
+                "locale",
+                "oof_shard",
+                "order_uid",
+                "payment",
+                "shardkey",
+                "sm_id",
+                "track_number"
+            ],
+            "properties": {
+                "customer_id": {
+                    "description": "Customer ID",
+                    "type": "string"
+                },
+                "date_created": {
+                    "description": "Order creation date",
+                    "type": "string"
+                },
+                "delivery": {
+                    "description": "Delivery info",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Delivery"
+                        }
+                    ]
+                },
+                "delivery_service": {
+                    "description": "Delivery service",
+                    "type": "string"
+                },
+                "entry": {
+                    "type": "string"
+                },
+                "internal_signature": {
+                    "description": "Internal signature",
+                    "type": "string"
+                },
+                "items": {
+                    "description": "List of items",
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/models.Item"
+                    }
+                },
+                "locale": {
+                    "description": "Locale: ISO 639-1 language with optional region (en, en-US)",
+                    "type": "string"
+                },
+                "oof_shard": {
+                    "type": "string"
+                },
+                "order_uid": {
+                    "description": "Unique order identifier",
+                    "type": "string"
+                },
+                "payment": {
+                    "description": "Payment info",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Payment"
+                        }
+                    ]
+                },
+                "shardkey": {
+                    "type": "string"
+                },
+                "sm_id": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "status": {
+                    "description": "Current lifecycle status, set by storage",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.OrderStatus"
+                        }
+                    ]
+                },
+                "timeline": {
+                    "description": "Status changes, oldest first. Set by storage",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.StatusTransition"
+                    }
+                },
+                "track_number": {
+                    "description": "Tracking number",
+                    "type": "string"
+                },
+                "updated_at": {
+                    "description": "Last update date, set by storage",
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Order version, starts from 1. Messages with version not greater than stored one are ignored,\nso every correction must have greater version",
+                    "type": "integer",
+                    "minimum": 1
+                }
+            }
+        },
+        "models.OrderStatus": {
+            "type": "string",
+            "enum": [
+                "created",
+                "paid",
+                "assembling",
+                "shipped",
+                "delivered",
+                "cancelled"
+            ],
+            "x-enum-varnames": [
+                "StatusCreated",
+                "StatusPaid",
+                "StatusAssembling",
+                "StatusShipped",
+                "StatusDelivered",
+                "StatusCancelled"
+            ]
+        },
+        "models.Payment": {
+            "description": "Payment details for the order.",
+            "type": "object",
+            "required": [
+                "amount",
+                "bank",
+                "currency",
+                "custom_fee",
+                "delivery_cost",
+                "goods_total",
+                "payment_dt",
+                "provider",
+                "transaction"
+            ],
+            "properties": {
+                "amount": {
+                    "description": "Payment amount",
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "bank": {
+                    "description": "Bank name",
+                    "type": "string"
+                },
+                "currency": {
+                    "description": "Payment currency, ISO 4217 code",
+                    "type": "string"
+                },
+                "custom_fee": {
+                    "description": "Custom fee",
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "delivery_cost": {
+                    "description": "Delivery cost",
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "goods_total": {
+                    "description": "Total goods cost",
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "payment_dt": {
+                    "description": "Payment timestamp",
+                    "type": "integer"
+                },
+                "provider": {
+                    "description": "Payment provider",
+                    "type": "string"
+                },
+                "request_id": {
+                    "description": "Request ID",
+                    "type": "string"
+                },
+                "transaction": {
+                    "description": "Transaction ID",
+                    "type": "string"
+                }
+            }
+        },
+        "models.Rejection": {
+            "description": "Rejected broker message with its raw payload and errors.",
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "description": "Rejection date",
+                    "type": "string"
+                },
+                "error": {
+                    "description": "Rejection error",
+                    "type": "string"
+                },
+                "errors": {
+                    "description": "Structured field errors",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.RejectionError"
+                    }
+                },
+                "headers": {
+                    "description": "Message headers",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "description": "Unique rejection identifier",
+                    "type": "string"
+                },
+                "key": {
+                    "description": "Message key",
+                    "type": "string"
+                },
+                "payload": {
+                    "description": "Raw message value as consumed, base64 encoded",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "reason": {
+                    "description": "Rejection reason: payload, content_type, decode, validation, rules or conflict",
+                    "type": "string"
+                },
+                "replayed_at": {
+                    "description": "Date of corrected payload replay, empty if rejection is not replayed",
+                    "type": "string"
+                },
+                "replayed_by": {
+                    "description": "Caller who replayed corrected payload",
+                    "type": "string"
+                },
+                "source": {
+                    "description": "Message source: topic, partition and offset",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Source"
+                        }
+                    ]
+                },
+                "stream": {
+                    "description": "Stream message was consumed from",
+                    "type": "string"
+                }
+            }
+        },
+        "models.RejectionError": {
+            "description": "Field error of rejected message.",
+            "type": "object",
+            "properties": {
+                "field": {
+                    "description": "Field path, for example Order.Delivery.Phone or /payment/amount",
+                    "type": "string"
+                },
+                "message": {
+                    "description": "Human-readable error message",
+                    "type": "string"
+                },
+                "rule": {
+                    "description": "Failed validation rule, schema keyword or business rule ID",
+                    "type": "string"
+                }
+            }
+        },
+        "models.Source": {
+            "description": "Order mutation source: broker message position or API caller.",
+            "type": "object",
+            "properties": {
+                "caller": {
+                    "description": "API caller subject",
+                    "type": "string"
+                },
+                "kind": {
+                    "description": "Source kind: broker, api or migration",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.SourceKind"
+                        }
+                    ]
+                },
+                "offset": {
+                    "description": "Broker message offset",
+                    "type": "integer"
+                },
+                "partition": {
+                    "description": "Broker topic partition",
+                    "type": "integer"
+                },
+                "request_id": {
+                    "description": "API request ID",
+                    "type": "string"
+                },
+                "topic": {
+                    "description": "Broker topic",
+                    "type": "string"
+                },
+                "trace_parent": {
+                    "description": "W3C traceparent of broker message handling span",
+                    "type": "string"
+                }
+            }
+        },
+        "models.SourceKind": {
+            "type": "string",
+            "enum": [
+                "broker",
+                "api",
+                "migration"
+            ],
+            "x-enum-varnames": [
+                "SourceBroker",
+                "SourceAPI",
+                "SourceMigration"
+            ]
+        },
+        "models.StatusTransition": {
+            "description": "Order status timeline record.",
+            "type": "object",
+            "properties": {
+                "changed_at": {
+                    "description": "Status change date",
+                    "type": "string"
+                },
+                "from": {
+                    "description": "Previous status, empty for order creation",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.OrderStatus"
+                        }
+                    ]
+                },
+                "reason": {
+                    "description": "Change reason",
+                    "type": "string"
+                },
+                "to": {
+                    "description": "New status",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.OrderStatus"
+                        }
+                    ]
+                }
+            }
+        },
+        "models.Webhook": {
+            "description": "Webhook subscription to order events.",
+            "type": "object",
+            "required": [
+                "events",
+                "secret",
+                "url"
+            ],
+            "properties": {
+                "consecutive_failures": {
+                    "description": "Number of consecutive failed deliveries",
+                    "type": "integer"
+                },
+                "created_at": {
+                    "description": "Webhook creation date",
+                    "type": "string"
+                },
+                "delivery_services": {
+                    "description": "Delivery services filter. Empty means all delivery services",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "enabled": {
+                    "description": "Whether webhook receives events. Webhooks are disabled after too many failed deliveries",
+                    "type": "boolean"
+                },
+                "events": {
+                    "description": "Subscribed event types, one of WebhookEvents",
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string",
+                        "enum": [
+                            "order.saved"
+                        ]
+                    }
+                },
+                "id": {
+                    "description": "Unique webhook identifier",
+                    "type": "string"
+                },
+                "secret": {
+                    "description": "Secret for HMAC-SHA256 payload signature. It is never returned by API",
+                    "type": "string",
+                    "minLength": 16
+                },
+                "url": {
+                    "description": "Target URL receiving POST requests",
+                    "type": "string"
+                }
+            }
+        },
+        "models.WebhookDelivery": {
+            "description": "Webhook delivery attempt log record.",
+            "type": "object",
+            "properties": {
+                "attempt": {
+                    "description": "Attempt number starting from 1",
+                    "type": "integer"
+                },
+                "created_at": {
+                    "description": "Attempt date",
+                    "type": "string"
+                },
+                "duration_ms": {
+                    "description": "Attempt duration in milliseconds",
+                    "type": "integer"
+                },
+                "error": {
+                    "description": "Error of delivery attempt",
+                    "type": "string"
+                },
+                "event": {
+                    "description": "Event type",
+                    "type": "string"
+                },
+                "event_id": {
+                    "description": "Event identifier, the same for all attempts of event delivery",
+                    "type": "string"
+                },
+                "id": {
+                    "description": "Unique delivery attempt identifier",
+                    "type": "string"
+                },
+                "order_uid": {
+                    "description": "Order UID of event",
+                    "type": "string"
+                },
+                "status_code": {
+                    "description": "HTTP status code of webhook response, 0 if there was no response",
+                    "type": "integer"
+                },
+                "success": {
+                    "description": "Whether attempt was successful",
+                    "type": "boolean"
+                },
+                "webhook_id": {
+                    "description": "Webhook identifier",
+                    "type": "string"
+                }
+            }
+        },
+        "problem.FieldError": {
+            "description": "Single invalid field of validation problem.",
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "description": "Violation details, set for business rules violations",
+                    "type": "string"
+                },
+                "field": {
+                    "description": "Field path, for example Order.Delivery.Phone",
+                    "type": "string"
+                },
+                "rule": {
+                    "description": "Failed validation rule, for example required",
+                    "type": "string"
+                }
+            }
+        },
+        "problem.Problem": {
+            "description": "Error response in RFC 7807 problem details format.",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Stable machine-readable error code",
+                    "type": "string"
+                },
+                "detail": {
+                    "description": "Human-readable explanation specific to this occurrence",
+                    "type": "string"
+                },
+                "errors": {
+                    "description": "Field errors, set for validation problems",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/problem.FieldError"
+                    }
+                },
+                "instance": {
+                    "description": "URI reference that identifies this occurrence (request path)",
+                    "type": "string"
+                },
+                "request_id": {
+                    "description": "Request ID for chaining with server logs",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "HTTP status code",
+                    "type": "integer"
+                },
+                "title": {
+                    "description": "Short human-readable summary of the problem type",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "URI reference that identifies the problem type",
+                    "type": "string"
+                }
+            }
+        },
+        "replay.Job": {
+            "description": "Replay of broker stream range.",
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Replay error",
+                    "type": "string"
+                },
+                "finished_at": {
+                    "description": "Replay finish date",
+                    "type": "string"
+                },
+                "from": {
+                    "description": "Replay start",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/replay.Target"
+                        }
+                    ]
+                },
+                "id": {
+                    "description": "Replay identifier",
+                    "type": "string"
+                },
+                "messages": {
+                    "description": "Number of handled messages",
+                    "type": "integer"
+                },
+                "started_at": {
+                    "description": "Replay start date",
+                    "type": "string"
+                },
+                "state": {
+                    "description": "Replay state: running, completed, failed or canceled",
+                    "type": "string"
+                },
+                "stream": {
+                    "description": "Replayed stream",
+                    "type": "string"
+                },
+                "to": {
+                    "description": "Replay end (exclusive), empty means partitions ends at replay start",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/replay.Target"
+                        }
+                    ]
+                }
+            }
+        },
+        "replay.Target": {
+            "description": "Position in stream partitions: timestamp or explicit offsets.",
+            "type": "object",
+            "properties": {
+                "offsets": {
+                    "description": "Explicit offsets of partitions",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "timestamp": {
+                    "description": "Selects the first messages at or after timestamp in every partition",
+                    "type": "string"
+                }
+            }
+        },
+        "rules.Violation": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "description": "Field is a path of inconsistent field, for example items[0].total_price",
                     "type": "string"
                 },
-                "email": {
-                    "description": "Email",
+                "message": {
+                    "description": "Message describes expected and actual values",
                     "type": "string"
                 },
-                "name": {
-                    "description": "Recipient name",
+                "rule": {
+                    "description": "Rule is a violated rule ID",
                     "type": "string"
+                }
+            }
+        },
+        "schema.Subject": {
+            "type": "object",
+            "properties": {
+                "current": {
+                    "description": "Current version, older ones are upcasted to it",
+                    "type": "integer"
                 },
-                "phone": {
-                    "description": "Recipient phone",
+                "name": {
+                    "description": "Subject name",
                     "type": "string"
                 },
-                "region": {
-                    "description": "Region",
-                    "type": "string"
+                "versions": {
+                    "description": "Available versions, oldest first",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "serverhandlers.ConsumerState": {
+            "description": "Broker consumer state: running, draining or paused, and running replays.",
+            "type": "object",
+            "properties": {
+                "replays": {
+                    "description": "Running replays",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/replay.Job"
+                    }
                 },
-                "zip": {
-                    "description": "Postal code",
+                "state": {
+                    "description": "Consumer state: running, draining (paused with running handlers) or paused",
                     "type": "string"
                 }
             }
         },
-        "models.Item": {
-            "description": "Product details in the order.",
+        "serverhandlers.FormattedAmounts": {
+            "description": "Order amounts formatted for order locale.",
             "type": "object",
-            "required": [
-                "brand",
-                "chrt_id",
-                "name",
-                "nm_id",
-                "price",
-                "rid",
-                "sale",
-                "size",
-                "status",
-                "total_price",
-                "track_number"
-            ],
             "properties": {
-                "brand": {
-                    "description": "Brand",
+                "amount": {
+                    "description": "Payment amount",
                     "type": "string"
                 },
-                "chrt_id": {
-                    "type": "integer"
+                "custom_fee": {
+                    "description": "Custom fee",
+                    "type": "string"
                 },
-                "name": {
-                    "description": "Product name",
+                "delivery_cost": {
+                    "description": "Delivery cost",
                     "type": "string"
                 },
-                "nm_id": {
-                    "type": "integer"
+                "goods_total": {
+                    "description": "Total goods cost",
+                    "type": "string"
                 },
+                "items": {
+                    "description": "Items amounts, in items order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/serverhandlers.FormattedItem"
+                    }
+                }
+            }
+        },
+        "serverhandlers.FormattedItem": {
+            "description": "Item amounts formatted for order locale.",
+            "type": "object",
+            "properties": {
                 "price": {
                     "description": "Unit price",
-                    "type": "integer",
-                    "minimum": 0
-                },
-                "rid": {
-                    "description": "RID",
                     "type": "string"
                 },
-                "sale": {
-                    "description": "Discount (%)",
-                    "type": "integer",
-                    "maximum": 100,
-                    "minimum": 0
-                },
-                "size": {
-                    "description": "Size",
+                "total_price": {
+                    "description": "Total price",
                     "type": "string"
+                }
+            }
+        },
+        "serverhandlers.OffsetsResetRequest": {
+            "description": "Consumer group offsets target: timestamp or explicit offsets of partitions.",
+            "type": "object",
+            "properties": {
+                "offsets": {
+                    "description": "Explicit offsets of partitions",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
                 },
-                "status": {
-                    "description": "Status",
-                    "type": "integer"
+                "stream": {
+                    "description": "Stream: orders (default) or statuses",
+                    "type": "string"
                 },
-                "total_price": {
-                    "description": "Total price",
-                    "type": "integer",
-                    "minimum": 0
+                "timestamp": {
+                    "description": "Selects the first messages at or after timestamp in every partition",
+                    "type": "string"
+                }
+            }
+        },
+        "serverhandlers.OffsetsResetResult": {
+            "description": "New next offsets of stream partitions.",
+            "type": "object",
+            "properties": {
+                "offsets": {
+                    "description": "New next offsets of partitions",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
                 },
-                "track_number": {
-                    "description": "Tracking number",
+                "stream": {
+                    "description": "Stream",
                     "type": "string"
                 }
             }
         },
-        "models.Order": {
-            "description": "Order received from external system. Contains delivery, payment and items info.",
+        "serverhandlers.OrderHistory": {
+            "description": "Order revisions with field-level changes.",
+            "type": "object",
+            "properties": {
+                "order_uid": {
+                    "description": "Unique order identifier",
+                    "type": "string"
+                },
+                "revisions": {
+                    "description": "Order revisions, oldest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/serverhandlers.OrderRevision"
+                    }
+                }
+            }
+        },
+        "serverhandlers.OrderResponse": {
+            "description": "Order with amounts formatted for order locale and payment currency.",
             "type": "object",
             "required": [
                 "customer_id",
@@ -220,6 +1858,14 @@ const docTemplate = `{
                 "entry": {
                     "type": "string"
                 },
+                "formatted": {
+                    "description": "Formatted amounts. Not set if payment currency is unknown",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/serverhandlers.FormattedAmounts"
+                        }
+                    ]
+                },
                 "internal_signature": {
                     "description": "Internal signature",
                     "type": "string"
@@ -233,7 +1879,7 @@ const docTemplate = `{
                     }
                 },
                 "locale": {
-                    "description": "Locale",
+                    "description": "Locale: ISO 639-1 language with optional region (en, en-US)",
                     "type": "string"
                 },
                 "oof_shard": {
@@ -258,72 +1904,179 @@ const docTemplate = `{
                     "type": "integer",
                     "minimum": 0
                 },
+                "status": {
+                    "description": "Current lifecycle status, set by storage",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.OrderStatus"
+                        }
+                    ]
+                },
+                "timeline": {
+                    "description": "Status changes, oldest first. Set by storage",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.StatusTransition"
+                    }
+                },
                 "track_number": {
                     "description": "Tracking number",
                     "type": "string"
+                },
+                "updated_at": {
+                    "description": "Last update date, set by storage",
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Order version, starts from 1. Messages with version not greater than stored one are ignored,\nso every correction must have greater version",
+                    "type": "integer",
+                    "minimum": 1
                 }
             }
         },
-        "models.Payment": {
-            "description": "Payment details for the order.",
+        "serverhandlers.OrderRevision": {
+            "description": "Order revision with its source and changed fields.",
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "description": "Changed fields compared to previous revision. Empty for first revision",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/history.Change"
+                    }
+                },
+                "created_at": {
+                    "description": "Revision date",
+                    "type": "string"
+                },
+                "kind": {
+                    "description": "Mutation kind: created, updated or status_changed",
+                    "type": "string"
+                },
+                "revision": {
+                    "description": "Revision number, starting from 1",
+                    "type": "integer"
+                },
+                "source": {
+                    "description": "Mutation source",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Source"
+                        }
+                    ]
+                },
+                "version": {
+                    "description": "Order version after mutation",
+                    "type": "integer"
+                }
+            }
+        },
+        "serverhandlers.OrderSaveResult": {
+            "description": "Result of order saving with business rules warnings.",
+            "type": "object",
+            "properties": {
+                "order_uid": {
+                    "description": "Unique order identifier",
+                    "type": "string"
+                },
+                "result": {
+                    "description": "Save result: created, updated or ignored (stored version is the same or newer)",
+                    "type": "string"
+                },
+                "version": {
+                    "description": "Order version",
+                    "type": "integer"
+                },
+                "warnings": {
+                    "description": "Violations of business rules configured to warn",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/rules.Violation"
+                    }
+                }
+            }
+        },
+        "serverhandlers.OrderSummary": {
+            "description": "Short order info sent by orders stream.",
             "type": "object",
-            "required": [
-                "amount",
-                "bank",
-                "currency",
-                "custom_fee",
-                "delivery_cost",
-                "goods_total",
-                "payment_dt",
-                "provider",
-                "transaction"
-            ],
             "properties": {
                 "amount": {
                     "description": "Payment amount",
-                    "type": "integer",
-                    "minimum": 0
+                    "type": "integer"
                 },
-                "bank": {
-                    "description": "Bank name",
+                "amount_formatted": {
+                    "description": "Payment amount formatted for order locale, for example $18.17",
                     "type": "string"
                 },
                 "currency": {
                     "description": "Payment currency",
                     "type": "string"
                 },
-                "custom_fee": {
-                    "description": "Custom fee",
-                    "type": "integer",
-                    "minimum": 0
-                },
-                "delivery_cost": {
-                    "description": "Delivery cost",
-                    "type": "integer",
-                    "minimum": 0
+                "date_created": {
+                    "description": "Order creation date",
+                    "type": "string"
                 },
-                "goods_total": {
-                    "description": "Total goods cost",
-                    "type": "integer",
-                    "minimum": 0
+                "delivery_service": {
+                    "description": "Delivery service",
+                    "type": "string"
                 },
-                "payment_dt": {
-                    "description": "Payment timestamp",
+                "items_count": {
+                    "description": "Number of items",
                     "type": "integer"
                 },
-                "provider": {
-                    "description": "Payment provider",
+                "order_uid": {
+                    "description": "Unique order identifier",
                     "type": "string"
                 },
-                "request_id": {
-                    "description": "Request ID",
+                "status": {
+                    "description": "Current order status",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.OrderStatus"
+                        }
+                    ]
+                },
+                "track_number": {
+                    "description": "Tracking number",
+                    "type": "string"
+                }
+            }
+        },
+        "serverhandlers.Readiness": {
+            "description": "Readiness of API with broker consumer state.",
+            "type": "object",
+            "properties": {
+                "consumer": {
+                    "description": "Broker consumer state: running, draining or paused",
                     "type": "string"
                 },
-                "transaction": {
-                    "description": "Transaction ID",
+                "status": {
+                    "description": "API status, always ready while server accepts requests",
                     "type": "string"
                 }
             }
+        },
+        "serverhandlers.ReplayRequest": {
+            "description": "Replay range: start and optional end (exclusive).",
+            "type": "object",
+            "properties": {
+                "from": {
+                    "description": "Replay start",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/replay.Target"
+                        }
+                    ]
+                },
+                "to": {
+                    "description": "Replay end (exclusive), empty means partitions ends at replay start",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/replay.Target"
+                        }
+                    ]
+                }
+            }
         }
     }
 }`
@@ -7,21 +7,35 @@ import (
 	"sync"
 	"syscall"
 
-	"github.com/go-playground/validator/v10"
 	"golang.org/x/sync/errgroup"
 
+	"wb-tech-l0/internal/auth"
 	"wb-tech-l0/internal/broker"
 	brokerHandlers "wb-tech-l0/internal/broker/handlers"
 	"wb-tech-l0/internal/broker/kafka"
+	brokerMiddlewares "wb-tech-l0/internal/broker/middlewares"
 	"wb-tech-l0/internal/cache"
 	"wb-tech-l0/internal/cache/local"
+	"wb-tech-l0/internal/codec"
 	"wb-tech-l0/internal/config"
+	"wb-tech-l0/internal/events"
 	"wb-tech-l0/internal/logger"
 	zaplogger "wb-tech-l0/internal/logger/zap"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/outbox"
+	"wb-tech-l0/internal/payload"
+	"wb-tech-l0/internal/pii"
 	"wb-tech-l0/internal/registry"
+	"wb-tech-l0/internal/rejections"
+	"wb-tech-l0/internal/replay"
+	"wb-tech-l0/internal/rules"
+	"wb-tech-l0/internal/schema"
 	"wb-tech-l0/internal/server"
+	serverHandlers "wb-tech-l0/internal/server/handlers"
+	"wb-tech-l0/internal/signing"
 	"wb-tech-l0/internal/storage"
 	"wb-tech-l0/internal/storage/postgres"
+	"wb-tech-l0/internal/webhook"
 )
 
 // App is a struct that represents all application
@@ -42,13 +56,45 @@ type App struct {
 	storage storage.Storage
 	// broker is a Broker client used in application
 	broker broker.Broker
+	// producer is a broker Producer client used in application.
+	// it has the same type as broker
+	producer broker.Producer
 	// cache is a Cache client used in application
 	cache cache.Cache
+	// orders caches orders read by HTTP server, invalidated by broker handlers
+	orders *serverHandlers.OrderCache
+
+	// hub fans out orders saved by broker handler to stream clients
+	hub *events.Hub
+	// waiters are long-poll requests woken by broker handler
+	waiters *events.Waiters
+	// webhooks delivers saved orders events from outbox to subscribed webhooks
+	webhooks *webhook.Dispatcher
+	// outbox relays order events saved with orders to producer
+	outbox *outbox.Relay
+	// rejections purges rejected messages after their retention
+	rejections *rejections.Purger
+	// rules checks orders business rules, shared by broker handler and HTTP server
+	rules *rules.Validator
+	// schemas validates and upcasts versioned messages, shared by broker handler and HTTP server
+	schemas *schema.Registry
+	// codecs decodes orders messages by their content types
+	codecs *codec.Registry
+	// payloads resolves claim-check and compressed orders messages payloads
+	payloads *payload.Resolver
+	// verifier verifies orders messages signatures
+	verifier *signing.Verifier
+	// ordersHandler handles orders messages, shared by subscription and replays.
+	// it is wrapped with handler middlewares by every user
+	ordersHandler broker.Handler
+	// replays runs one-off replays of orders stream ranges
+	replays *replay.Runner
 
 	// registries of supported services
-	storageRegistry *registry.ServiceRegistry[storage.Storage]
-	brokerRegistry  *registry.ServiceRegistry[broker.Broker]
-	cacheRegistry   *registry.ServiceRegistry[cache.Cache]
+	storageRegistry  *registry.ServiceRegistry[storage.Storage]
+	brokerRegistry   *registry.ServiceRegistry[broker.Broker]
+	producerRegistry *registry.ServiceRegistry[broker.Producer]
+	cacheRegistry    *registry.ServiceRegistry[cache.Cache]
 	// we use registries to easily change the services used, even without changing the code.
 	// when adding support for a new service, for example Redis for cache, we only need to register it
 	// with a couple of lines of code. after that, we can choose which cache service to use (local or Redis)
@@ -108,9 +154,10 @@ func New(ctx context.Context, cfg *config.Config, log logger.Logger) (*App, erro
 		log: log,
 		ctx: ctx,
 		// creating registries of supported services.
-		storageRegistry: registry.New[storage.Storage](),
-		brokerRegistry:  registry.New[broker.Broker](),
-		cacheRegistry:   registry.New[cache.Cache](),
+		storageRegistry:  registry.New[storage.Storage](),
+		brokerRegistry:   registry.New[broker.Broker](),
+		producerRegistry: registry.New[broker.Producer](),
+		cacheRegistry:    registry.New[cache.Cache](),
 	}
 
 	// registering all supported services
@@ -125,8 +172,75 @@ func New(ctx context.Context, cfg *config.Config, log logger.Logger) (*App, erro
 		return nil, fmt.Errorf("could not create clients: %w", err)
 	}
 
+	// letting broker keep consumer offsets in storage, so they are saved
+	// with orders atomically. broker uses them only if it is configured to
+	if user, ok := app.broker.(broker.OffsetStoreUser); ok {
+		user.UseOffsetStore(app.storage)
+	}
+
+	// creating HTTP API authenticator
+	authenticator, err := auth.New(&cfg.Server.Auth)
+	if err != nil {
+		app.Shutdown()
+		return nil, fmt.Errorf("could not create authenticator: %w", err)
+	}
+	if !cfg.Server.Auth.Enabled {
+		app.log.Warn("HTTP API authentication is disabled")
+	}
+
+	// creating orders cache, saved orders hub and waiters shared by broker handler and HTTP server
+	app.orders = serverHandlers.NewOrderCache(app.cache)
+	app.hub = events.NewHub(cfg.Server.Stream.BufferSize, cfg.Server.Stream.ClientBuffer, cfg.Server.Stream.MaxClients)
+	app.waiters = events.NewWaiters(cfg.Server.Wait.MaxWaiters)
+	// orders leaving the service have delivery PII masked the same way as API responses
+	masker := pii.New(&cfg.Server.Masking)
+	app.webhooks = webhook.New(&cfg.Webhook, app.storage, masker, app.log.With(logger.Field("component", "webhook")))
+	app.outbox = outbox.New(&cfg.Outbox, app.storage, app.producer, masker, app.log.With(logger.Field("component", "outbox")))
+	app.rejections = rejections.New(&cfg.Rejections, app.storage, app.log.With(logger.Field("component", "rejections")))
+	app.rules = rules.New(&cfg.Rules)
+	app.schemas, err = schema.New()
+	if err != nil {
+		app.Shutdown()
+		return nil, fmt.Errorf("could not load message schemas: %w", err)
+	}
+	avro, err := codec.NewAvro(app.schemas)
+	if err != nil {
+		app.Shutdown()
+		return nil, fmt.Errorf("could not load avro schemas: %w", err)
+	}
+	// messages without content type are JSON
+	app.codecs = codec.NewRegistry(codec.NewJSON(app.schemas), codec.NewProtobuf(app.schemas), avro)
+	app.payloads, err = payload.New(&cfg.Payload)
+	if err != nil {
+		app.Shutdown()
+		return nil, fmt.Errorf("could not create payload resolver: %w", err)
+	}
+	app.verifier = signing.New(&cfg.Signing)
+
+	// using single instance of validator for all orders messages
+	// because it caches information about structs and validations.
+	// messages with invalid signatures are dead lettered before decoding
+	app.ordersHandler = brokerHandlers.SignedHandler(app.log, app.verifier, app.producer, broker.StreamOrders,
+		brokerHandlers.OrdersHandler(app.log, app.storage, models.NewValidator(), app.payloads, app.codecs, app.rules, app.notifier()))
+
+	// replays are available if broker supports them
+	if replayer, ok := app.broker.(broker.Replayer); ok {
+		app.replays = replay.New(app.ctx, app.log.With(logger.Field("component", "replay")), replayer, broker.StreamOrders, app.handler("replay", app.ordersHandler))
+	}
+
 	// creating HTTP server
-	router := server.NewRouter(app.log, app.cache, app.storage)
+	router := server.NewRouter(&cfg.Server, app.log, &server.Dependencies{
+		Authenticator: authenticator,
+		Orders:        app.orders,
+		Storage:       app.storage,
+		Hub:           app.hub,
+		Waiters:       app.waiters,
+		Schemas:       app.schemas,
+		Rules:         app.rules,
+		Notifier:      app.notifier(),
+		Consumer:      app.broker,
+		Replays:       app.replays,
+	})
 	app.httpServer = server.New(&cfg.Server, app.log.With(logger.Field("address", cfg.Server.Address)), router)
 	app.log.Info("Successfully created server", logger.Field("address", cfg.Server.Address))
 
@@ -147,17 +261,44 @@ func (a *App) Run() {
 		return a.httpServer.Start()
 	})
 
-	// start broker consumer
+	// start webhooks delivery workers
+	g.Go(func() error {
+		// run will block until application is exiting
+		a.webhooks.Run(ctx)
+		return nil
+	})
+
+	// start outbox relay
+	g.Go(func() error {
+		// run will block until application is exiting
+		a.outbox.Run(ctx)
+		return nil
+	})
+
+	// start rejections purge
 	g.Go(func() error {
-		// using single instance of validator for all messages
-		// because it caches information about structs and validations
-		validate := validator.New(validator.WithRequiredStructEnabled())
+		// run will block until application is exiting
+		a.rejections.Run(ctx)
+		return nil
+	})
 
+	// start broker consumer
+	g.Go(func() error {
 		// subscribe will block until something goes wrong or application is exiting.
 		// given handler will be called on every successfully received message.
 		// handler must return error if something is wrong with the message handling.
 		// on error, broker will NOT commit message and there could be retries.
-		a.broker.Subscribe(brokerHandlers.OrdersHandler(a.log, a.storage, validate))
+		a.broker.Subscribe(broker.StreamOrders, a.handler(string(broker.StreamOrders), a.ordersHandler))
+		return nil
+	})
+
+	// start broker status changes consumer
+	g.Go(func() error {
+		validate := models.NewValidator()
+		// subscribe will block the same way as orders subscription
+		statuses := brokerHandlers.StatusesHandler(a.log, a.storage, validate, a.notifier(), a.orders)
+		a.broker.Subscribe(broker.StreamStatuses, a.handler(string(broker.StreamStatuses),
+			brokerHandlers.SignedHandler(a.log, a.verifier, a.producer, broker.StreamStatuses, statuses)))
 		return nil
 	})
 
@@ -170,6 +311,25 @@ func (a *App) Run() {
 	}
 }
 
+// handler wraps broker messages handler with middlewares. name is a handler name in logs and metrics.
+// tracing goes first to have trace in logs, metrics count recovered panics
+// and timeout is applied to handler only
+func (a *App) handler(name string, handler broker.Handler) broker.Handler {
+	return broker.Chain(handler,
+		brokerMiddlewares.Tracing(),
+		brokerMiddlewares.Logging(a.log, name),
+		brokerMiddlewares.Metrics(name),
+		brokerMiddlewares.Recovery(a.log),
+		brokerMiddlewares.Timeout(a.cfg.Handler.Timeout),
+	)
+}
+
+// notifier returns notifier of all in-process subscribers of saved orders.
+// Cache is invalidated first, so subscribers reading order get the new one
+func (a *App) notifier() events.Notifier {
+	return events.Multi(a.orders, a.hub, a.waiters)
+}
+
 // Shutdown performs graceful shutdown of all services.
 // It tries to gracefully close all service connections within the timeout.
 // All services are closing concurrently
@@ -221,6 +381,19 @@ func (a *App) Shutdown() {
 		}()
 	}
 
+	// closing producer client
+	if a.producer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.producer.Close(); err != nil {
+				a.log.Error("Could not close producer client", logger.Field("broker", a.cfg.BrokerType), logger.Error(err))
+				return
+			}
+			a.log.Info("Successfully closed producer client", logger.Field("broker", a.cfg.BrokerType))
+		}()
+	}
+
 	// closing cache client
 	if a.cache != nil {
 		wg.Add(1)
@@ -234,6 +407,13 @@ func (a *App) Shutdown() {
 		}()
 	}
 
+	// closing payload store directory
+	if a.payloads != nil {
+		if err := a.payloads.Close(); err != nil {
+			a.log.Warn("Could not close payload store", logger.Error(err))
+		}
+	}
+
 	// done is closed when all services are closed
 	done := make(chan struct{})
 	go func() {
@@ -272,6 +452,15 @@ func (a *App) registerServices() {
 		return kafka.New(a.ctx, cfg, a.log.With(logger.Field("broker", "kafka")))
 	})
 
+	a.producerRegistry.Register("kafka", func() (broker.Producer, error) {
+		cfg, err := kafka.LoadConfig()
+		if err != nil {
+			return nil, fmt.Errorf("could not load kafka broker config: %w", err)
+		}
+		// add broker type to log
+		return kafka.NewProducer(cfg, a.log.With(logger.Field("broker", "kafka")))
+	})
+
 	a.cacheRegistry.Register("local", func() (cache.Cache, error) {
 		cfg, err := local.LoadConfig()
 		if err != nil {
@@ -321,6 +510,19 @@ func (a *App) createClients() error {
 		return nil
 	})
 
+	// creating producerClient concurrently
+	g.Go(func() error {
+		// producer has the same type as broker, so events
+		// are published to the broker orders are consumed from
+		producerClient, err := a.producerRegistry.Create(a.cfg.BrokerType)
+		if err != nil {
+			return fmt.Errorf("could not create producer client: %w", err)
+		}
+		a.producer = producerClient
+		a.log.Info("Successfully created producer client", logger.Field("broker", a.cfg.BrokerType))
+		return nil
+	})
+
 	// creating cacheClient concurrently
 	g.Go(func() error {
 		// creating cache client with provided CacheType.
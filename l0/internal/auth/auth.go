@@ -0,0 +1,208 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"wb-tech-l0/internal/config"
+)
+
+// Role is a caller role used for access control
+type Role string
+
+const (
+	// RoleSupport can read orders
+	RoleSupport Role = "support"
+	// RoleAdmin can read orders and use admin endpoints
+	RoleAdmin Role = "admin"
+)
+
+// allRoles are all known roles. Anonymous principal
+// has them when authentication is disabled
+var allRoles = []Role{RoleSupport, RoleAdmin}
+
+// authentication methods for Principal.Method
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+	MethodNone   = "none"
+)
+
+// authentication errors. All of them must be answered with 401
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is an authenticated caller
+type Principal struct {
+	// Subject identifies caller: JWT subject or API key fingerprint
+	Subject string
+	// Roles are caller roles
+	Roles []Role
+	// Method is authentication method used by caller
+	Method string
+}
+
+// HasAnyRole reports whether principal has at least one of given roles
+func (p *Principal) HasAnyRole(roles ...Role) bool {
+	for _, have := range p.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SA1029: should not use built-in type string as key for value; define your own type to avoid collisions
+type contextKey string
+
+// key for setting principal in request context
+const principalKey contextKey = "principal"
+
+// WithPrincipal returns copy of ctx with principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// PrincipalFrom gets principal from context.
+// It returns nil if request was not authenticated
+func PrincipalFrom(ctx context.Context) *Principal {
+	if p, ok := ctx.Value(principalKey).(*Principal); ok {
+		return p
+	}
+	return nil
+}
+
+// Authenticator authenticates requests with static API keys
+// (X-API-Key header) or signed JWTs (Authorization: Bearer header)
+type Authenticator struct {
+	// disabled authenticator accepts every request as anonymous principal with all roles
+	disabled bool
+	// apiKeys maps SHA-256 of API key to its role.
+	// keys are stored hashed to not keep them in memory in plain
+	// and to make lookup independent of key prefix
+	apiKeys map[[sha256.Size]byte]Role
+	jwt     *jwtVerifier
+}
+
+// New creates and returns Authenticator from auth config.
+// It loads RSA public key from file if it is configured
+func New(cfg *config.AuthConfig) (*Authenticator, error) {
+	if !cfg.Enabled {
+		return &Authenticator{disabled: true}, nil
+	}
+
+	a := &Authenticator{
+		apiKeys: make(map[[sha256.Size]byte]Role, len(cfg.APIKeys)),
+		jwt: &jwtVerifier{
+			issuer:   cfg.JWTIssuer,
+			audience: cfg.JWTAudience,
+			leeway:   cfg.JWTLeeway,
+			now:      time.Now,
+		},
+	}
+
+	for key, role := range cfg.APIKeys {
+		a.apiKeys[sha256.Sum256([]byte(key))] = Role(role)
+	}
+
+	if cfg.JWTHMACSecret != "" {
+		a.jwt.hmacSecret = []byte(cfg.JWTHMACSecret)
+	}
+
+	if cfg.JWTRSAPublicKeyFile != "" {
+		key, err := loadRSAPublicKey(cfg.JWTRSAPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load JWT RSA public key: %w", err)
+		}
+		a.jwt.rsaKey = key
+	}
+
+	return a, nil
+}
+
+// Authenticate authenticates request and returns its Principal.
+// It returns ErrNoCredentials if request has no credentials
+// and ErrInvalidCredentials (wrapped) if they are wrong
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if a.disabled {
+		return &Principal{Subject: "anonymous", Roles: allRoles, Method: MethodNone}, nil
+	}
+
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		role, ok := a.apiKeys[sum]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+		}
+		return &Principal{
+			// short fingerprint is enough to distinguish keys in logs
+			Subject: fmt.Sprintf("key:%x", sum[:4]),
+			Roles:   []Role{role},
+			Method:  MethodAPIKey,
+		}, nil
+	}
+
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, ErrNoCredentials
+	}
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, fmt.Errorf("%w: unsupported authorization scheme", ErrInvalidCredentials)
+	}
+
+	claims, err := a.jwt.verify(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	roles := make([]Role, 0, len(claims.Roles))
+	for _, role := range claims.Roles {
+		roles = append(roles, Role(role))
+	}
+	return &Principal{
+		Subject: claims.Subject,
+		Roles:   roles,
+		Method:  MethodJWT,
+	}, nil
+}
+
+// loadRSAPublicKey reads PEM encoded RSA public key (PKIX or PKCS1) from file
+func loadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is set by operator in config
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse public key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not RSA")
+	}
+	return key, nil
+}
@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/config"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// signToken builds compact JWT with given alg and claims.
+// key is []byte for HS256 and *rsa.PrivateKey for RS256
+func signToken(t *testing.T, alg string, claims map[string]interface{}, key interface{}) string {
+	t.Helper()
+
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("json.Marshal() error = %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	signed := encode(map[string]string{"alg": alg, "typ": "JWT"}) + "." + encode(claims)
+
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("rsa.SignPKCS1v15() error = %v", err)
+		}
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestAuthenticate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() error = %v", err)
+	}
+
+	authenticator, err := New(&config.AuthConfig{
+		Enabled:       true,
+		APIKeys:       map[string]string{"support-key-0123456789": "support"},
+		JWTHMACSecret: testSecret,
+		JWTIssuer:     "orders",
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	authenticator.jwt.rsaKey = &rsaKey.PublicKey
+
+	valid := map[string]interface{}{
+		"sub":   "alice",
+		"iss":   "orders",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{"admin"},
+	}
+	expired := map[string]interface{}{
+		"sub": "alice",
+		"iss": "orders",
+		"exp": time.Now().Add(-time.Hour).Unix(),
+	}
+	wrongIssuer := map[string]interface{}{
+		"sub": "alice",
+		"iss": "other",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		wantErr  error
+		wantRole Role
+	}{
+		{
+			name:    "No credentials",
+			wantErr: ErrNoCredentials,
+		},
+		{
+			name:     "Valid API key",
+			headers:  map[string]string{"X-API-Key": "support-key-0123456789"},
+			wantRole: RoleSupport,
+		},
+		{
+			name:    "Unknown API key",
+			headers: map[string]string{"X-API-Key": "unknown-key-0123456789"},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:     "Valid HS256 token",
+			headers:  map[string]string{"Authorization": "Bearer " + signToken(t, "HS256", valid, []byte(testSecret))},
+			wantRole: RoleAdmin,
+		},
+		{
+			name:     "Valid RS256 token",
+			headers:  map[string]string{"Authorization": "Bearer " + signToken(t, "RS256", valid, rsaKey)},
+			wantRole: RoleAdmin,
+		},
+		{
+			name:    "Wrong HS256 secret",
+			headers: map[string]string{"Authorization": "Bearer " + signToken(t, "HS256", valid, []byte("wrong"))},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "Unsigned token",
+			headers: map[string]string{"Authorization": "Bearer " + signToken(t, "none", valid, nil)},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "Expired token",
+			headers: map[string]string{"Authorization": "Bearer " + signToken(t, "HS256", expired, []byte(testSecret))},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "Wrong issuer",
+			headers: map[string]string{"Authorization": "Bearer " + signToken(t, "HS256", wrongIssuer, []byte(testSecret))},
+			wantErr: ErrInvalidCredentials,
+		},
+		{
+			name:    "Basic scheme",
+			headers: map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+			wantErr: ErrInvalidCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+
+			principal, err := authenticator.Authenticate(r)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Authenticate() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() unexpected error = %v", err)
+			}
+			if !principal.HasAnyRole(tt.wantRole) {
+				t.Errorf("Authenticate() roles = %v, want %v", principal.Roles, tt.wantRole)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// jwtHeader is a JOSE header of JWT
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+// jwtClaims are JWT claims used by application.
+// Roles are taken from "roles" array or single "role" claim
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *int64   `json:"exp"`
+	NotBefore *int64   `json:"nbf"`
+	Roles     []string `json:"roles"`
+	Role      string   `json:"role"`
+}
+
+// audience is "aud" claim which can be either string or array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("aud must be string or array of strings: %w", err)
+	}
+	*a = many
+	return nil
+}
+
+// jwtVerifier verifies compact serialized JWTs signed with HS256
+// (shared secret) or RS256 (RSA public key). Algorithm is accepted
+// only if its key is configured, so "none" and algorithm confusion
+// attacks are rejected
+type jwtVerifier struct {
+	hmacSecret []byte
+	rsaKey     *rsa.PublicKey
+	// issuer and audience are checked only if set
+	issuer   string
+	audience string
+	// leeway is allowed clock skew for exp and nbf
+	leeway time.Duration
+	now    func() time.Time
+}
+
+// verify checks token signature and registered claims and returns its claims
+func (v *jwtVerifier) verify(token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	switch header.Alg {
+	case "HS256":
+		if v.hmacSecret == nil {
+			return nil, errors.New("HS256 tokens are not accepted")
+		}
+		mac := hmac.New(sha256.New, v.hmacSecret)
+		mac.Write(signed)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return nil, errors.New("invalid token signature")
+		}
+	case "RS256":
+		if v.rsaKey == nil {
+			return nil, errors.New("RS256 tokens are not accepted")
+		}
+		digest := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(v.rsaKey, crypto.SHA256, digest[:], signature); err != nil {
+			return nil, errors.New("invalid token signature")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported token algorithm %q", header.Alg)
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+
+	now := v.now()
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("token has no expiration")
+	}
+	if now.After(time.Unix(*claims.ExpiresAt, 0).Add(v.leeway)) {
+		return nil, errors.New("token is expired")
+	}
+	if claims.NotBefore != nil && now.Add(v.leeway).Before(time.Unix(*claims.NotBefore, 0)) {
+		return nil, errors.New("token is not valid yet")
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return nil, errors.New("unexpected token issuer")
+	}
+	if v.audience != "" && !claims.Audience.contains(v.audience) {
+		return nil, errors.New("unexpected token audience")
+	}
+
+	if claims.Role != "" {
+		claims.Roles = append(claims.Roles, claims.Role)
+	}
+	return &claims, nil
+}
+
+func (a audience) contains(value string) bool {
+	for _, v := range a {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeSegment decodes base64url JSON token segment into v
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,40 @@
+package broker
+
+import "strconv"
+
+// Dead letter message headers. Original message headers are kept as is
+const (
+	// HeaderDeadLetterReason is a stable reason of rejection, for example bad_signature
+	HeaderDeadLetterReason = "dead-letter-reason"
+	// HeaderDeadLetterError is a human-readable rejection error
+	HeaderDeadLetterError = "dead-letter-error"
+	// HeaderSourceTopic is a topic rejected message was consumed from
+	HeaderSourceTopic = "source-topic"
+	// HeaderSourcePartition is a partition rejected message was consumed from
+	HeaderSourcePartition = "source-partition"
+	// HeaderSourceOffset is an offset of rejected message
+	HeaderSourceOffset = "source-offset"
+)
+
+// DeadLetter returns copy of consumed message for StreamDeadLetters
+// with rejection reason and source position headers
+func DeadLetter(message *Message, reason string, err error) *Message {
+	headers := make(map[string][]byte, len(message.Headers)+5)
+	for k, v := range message.Headers {
+		headers[k] = v
+	}
+	headers[HeaderDeadLetterReason] = []byte(reason)
+	if err != nil {
+		headers[HeaderDeadLetterError] = []byte(err.Error())
+	}
+	headers[HeaderSourceTopic] = []byte(message.Topic)
+	headers[HeaderSourcePartition] = []byte(strconv.Itoa(message.Partition))
+	headers[HeaderSourceOffset] = []byte(strconv.FormatInt(message.Offset, 10))
+
+	return &Message{
+		Key:       message.Key,
+		Value:     message.Value,
+		Timestamp: message.Timestamp,
+		Headers:   headers,
+	}
+}
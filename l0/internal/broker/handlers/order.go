@@ -1,56 +1,129 @@
 package brokerhandlers
 
 import (
-	"encoding/json"
 	"errors"
 
 	"github.com/go-playground/validator/v10"
 
 	"wb-tech-l0/internal/broker"
+	brokermiddlewares "wb-tech-l0/internal/broker/middlewares"
+	"wb-tech-l0/internal/codec"
+	"wb-tech-l0/internal/events"
 	"wb-tech-l0/internal/logger"
 	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/payload"
+	"wb-tech-l0/internal/rules"
+	"wb-tech-l0/internal/schema"
 	"wb-tech-l0/internal/storage"
 )
 
 // OrdersHandler returns a handler function for broker.Subscribe for handling orders messages.
 // handler must return error if something is wrong with the message handling.
 // on error, broker will NOT commit message and there could be retries.
-func OrdersHandler(log logger.Logger, store storage.Storage, validate *validator.Validate) func(message *broker.Message) error {
+// message payload is claimed from object store and decompressed by its headers first, then
+// it is decoded with codec of content-type header, JSON and Avro values are validated
+// with schema of schema-version header and upcasted to current order.
+// orders violating rejecting business rules are skipped.
+// order version is required (v1 orders are upcasted with version 1), existing order
+// is replaced only by greater version, the same or smaller one is ignored.
+// skipped invalid messages are saved to rejections store with their raw payload,
+// if rejection can't be saved, message is NOT committed.
+// notifier is notified about every successfully saved order.
+func OrdersHandler(log logger.Logger, store storage.Storage, validate *validator.Validate, payloads *payload.Resolver, codecs *codec.Registry, orderRules *rules.Validator, notifier events.Notifier) func(message *broker.Message) error {
 	return func(message *broker.Message) error {
 		// add message key to log
 		log := log.With(logger.Field("message_key", string(message.Key)))
 
+		// resolving claim-check and compressed payloads
+		value, err := payloads.Resolve(message.Context(), message.Headers, message.Value)
+		if err != nil {
+			if errors.Is(err, payload.ErrInvalid) {
+				log.Warn("Invalid order message payload. Handler skipping message", logger.Error(err))
+				// returning nil to commit message in Subscribe after saving rejection
+				return saveRejection(log, store, broker.StreamOrders, message, models.RejectionPayload, err)
+			}
+			log.Warn("Failed to resolve order message payload", logger.Error(err))
+			// returning error to NOT commit message in broker, object store can recover
+			return err
+		}
+
+		contentType := string(message.Headers[codec.HeaderContentType])
+		c, err := codecs.Get(contentType)
+		if err != nil {
+			log.Debug("Unsupported order message content type. Handler skipping message", logger.Error(err))
+			// returning nil to commit message in Subscribe after saving rejection
+			return saveRejection(log, store, broker.StreamOrders, message, models.RejectionContentType, err)
+		}
+
 		var order models.Order
-		// parsing message value in order struct
-		if err := json.Unmarshal(message.Value, &order); err != nil {
-			log.Debug("Invalid JSON message. Handler skipping message", logger.Error(err))
-			// returning nil to commit message in Subscribe
-			return nil
+		// parsing message value in order struct with schema of its version
+		version := string(message.Headers[schema.HeaderVersion])
+		if err := c.Decode(value, version, &order); err != nil {
+			log.Debug("Invalid order message. Handler skipping message",
+				logger.Field("content_type", contentType), logger.Field("schema_version", version), logger.Error(err))
+			// returning nil to commit message in Subscribe after saving rejection
+			return saveRejection(log, store, broker.StreamOrders, message, models.RejectionDecode, err)
 		}
 
 		// validating
 		if err := validate.Struct(order); err != nil {
 			log.Debug("Invalid order schema. Handler skipping message", logger.Error(err))
-			// returning nil to commit message in Subscribe
-			return nil
+			// returning nil to commit message in Subscribe after saving rejection
+			return saveRejection(log, store, broker.StreamOrders, message, models.RejectionValidation, err)
 		}
 
 		// adding order uid to logger for chaining storage logs with handler logs
 		log = log.With(logger.Field("order_uid", order.OrderUID))
 
-		// saving message
-		err := store.SaveOrder(&order)
+		// checking consistency of order fields
+		warnings, err := orderRules.Validate(&order)
+		if err != nil {
+			log.Warn("Order violates business rules. Handler skipping message", logger.Error(err))
+			// returning nil to commit message in Subscribe because of invalid data
+			return saveRejection(log, store, broker.StreamOrders, message, models.RejectionRules, err)
+		}
+		if len(warnings) > 0 {
+			log.Warn("Order violates business rules. Order is accepted", logger.Field("rules", rules.IDs(warnings)))
+		}
+
+		// saving message with its position as revision source.
+		// consumer position is saved with order if broker provides it
+		result, err := store.SaveOrder(message.Context(), &order, messageSource(message), message.Position)
 		if err != nil {
+			if errors.Is(err, storage.ErrAlreadyProcessed) {
+				log.Debug("Skipping already processed order message")
+				return nil
+			}
 			log.Warn("Failed to save order", logger.Error(err))
 			if errors.Is(err, storage.ErrUniqueViolation) {
-				log.Warn("Skipping order because it already exists")
+				log.Warn("Skipping order because it conflicts with another order")
 				// returning nil to commit message in Subscribe because of invalid data
-				return nil
+				return saveRejection(log, store, broker.StreamOrders, message, models.RejectionConflict, err)
 			}
 			// returning error to NOT commit message in broker
 			return err
 		}
 
+		log.Debug("Order message handled", logger.Field("result", result), logger.Field("version", order.Version))
+		if result == storage.SaveIgnored {
+			// stored version is the same or newer, nothing changed
+			return nil
+		}
+
+		// notifying in-process subscribers (cache, streams, waiters).
+		// webhooks and producer are fed by outbox message saved with order
+		notifier.OrderSaved(&order)
+
 		return nil
 	}
 }
+
+// messageSource returns mutation source of message with its position
+// and traceparent of handling span, so saved events continue message trace
+func messageSource(message *broker.Message) models.Source {
+	source := models.BrokerSource(message.Topic, message.Partition, message.Offset)
+	if trace, ok := brokermiddlewares.GetTrace(message.Context()); ok {
+		source.TraceParent = trace.TraceParent()
+	}
+	return source
+}
@@ -0,0 +1,83 @@
+package brokerhandlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"wb-tech-l0/internal/broker"
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/rules"
+	"wb-tech-l0/internal/schema"
+	"wb-tech-l0/internal/storage"
+)
+
+var storedRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "broker_messages_stored_rejections_total",
+	Help: "Consumed messages rejected by handlers and saved to rejections store.",
+}, []string{"stream", "reason"})
+
+// saveRejection saves rejected message with its raw payload and structured errors of err.
+// handlers must return its error to NOT commit message, so rejections are never lost
+func saveRejection(log logger.Logger, store storage.Storage, stream broker.Stream, message *broker.Message, reason string, err error) error {
+	headers := make(map[string]string, len(message.Headers))
+	for k, v := range message.Headers {
+		headers[sanitize(k)] = sanitize(string(v))
+	}
+
+	rejection := &models.Rejection{
+		Stream:  string(stream),
+		Reason:  reason,
+		Error:   sanitize(err.Error()),
+		Errors:  rejectionErrors(err),
+		Key:     sanitize(string(message.Key)),
+		Payload: message.Value,
+		Headers: headers,
+		Source:  messageSource(message),
+	}
+	if err := store.SaveRejection(message.Context(), rejection); err != nil {
+		log.Error("Failed to save rejected message", logger.Field("reason", reason), logger.Error(err))
+		return err
+	}
+
+	storedRejections.WithLabelValues(string(stream), reason).Inc()
+	log.Debug("Rejected message saved", logger.Field("reason", reason), logger.Field("rejection_id", rejection.ID))
+	return nil
+}
+
+// rejectionErrors returns structured field errors of validator, schema and business rules errors.
+// other errors have no field errors, their message is kept in rejection error
+func rejectionErrors(err error) []models.RejectionError {
+	var validationErrs validator.ValidationErrors
+	var schemaErr *schema.ValidationError
+	var rulesErr *rules.Error
+
+	var result []models.RejectionError
+	switch {
+	case errors.As(err, &validationErrs):
+		for _, fe := range validationErrs {
+			result = append(result, models.RejectionError{Field: fe.Namespace(), Rule: fe.Tag(), Message: sanitize(fe.Error())})
+		}
+	case errors.As(err, &schemaErr):
+		for _, fe := range schemaErr.Errors {
+			result = append(result, models.RejectionError{Field: fe.Path, Rule: fe.Keyword, Message: sanitize(fe.Message)})
+		}
+	case errors.As(err, &rulesErr):
+		for _, v := range rulesErr.Violations {
+			result = append(result, models.RejectionError{Field: v.Field, Rule: v.Rule, Message: v.Message})
+		}
+	}
+	if result == nil {
+		result = []models.RejectionError{}
+	}
+	return result
+}
+
+// sanitize makes message data storable as text: invalid UTF-8 and NUL bytes are dropped
+func sanitize(s string) string {
+	return strings.ReplaceAll(strings.ToValidUTF8(s, ""), "\x00", "")
+}
@@ -0,0 +1,249 @@
+package brokerhandlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"wb-tech-l0/internal/broker"
+	brokermiddlewares "wb-tech-l0/internal/broker/middlewares"
+	"wb-tech-l0/internal/codec"
+	"wb-tech-l0/internal/config"
+	noplogger "wb-tech-l0/internal/logger/nop"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/payload"
+	"wb-tech-l0/internal/rules"
+	"wb-tech-l0/internal/schema"
+	"wb-tech-l0/internal/storage"
+)
+
+// order is a valid order message of current schema version
+const order = `{
+	"order_uid": "b563feb7b2b84b6test", "track_number": "WBILMTESTTRACK", "entry": "WBIL",
+	"delivery": {
+		"name": "Test Testov", "phone": "+9720000000", "zip": "2639809", "city": "Kiryat Mozkin",
+		"address": "Ploshad Mira 15", "region": "Kraiot", "email": "test@gmail.com"
+	},
+	"payment": {
+		"transaction": "b563feb7b2b84b6test", "request_id": "", "currency": "USD", "provider": "wbpay",
+		"amount": 1817, "payment_dt": 1637907727, "bank": "alpha", "delivery_cost": 1500, "goods_total": 317, "custom_fee": 0
+	},
+	"items": [{
+		"chrt_id": 9934930, "track_number": "WBILMTESTTRACK", "price": 453, "rid": "ab4219087a764ae0btest",
+		"name": "Mascaras", "sale": 30, "size": "0", "total_price": 317, "nm_id": 2389212, "brand": "Vivienne Sabo", "status": 202
+	}],
+	"locale": "en", "internal_signature": "", "customer_id": "test", "delivery_service": "meest",
+	"shardkey": "9", "sm_id": 99, "date_created": "2021-11-26T06:22:19Z", "oof_shard": "1"
+}`
+
+func TestRejectionErrors(t *testing.T) {
+	validationErr := models.NewValidator().Struct(models.Order{})
+	schemaErr := &schema.ValidationError{Subject: schema.Order, Version: 2, Errors: []schema.FieldError{
+		{Path: "payment.amount", Keyword: "required", Message: "missing property\x00"},
+	}}
+	rulesErr := &rules.Error{Violations: []rules.Violation{
+		{Rule: rules.RuleAmount, Field: "payment.amount", Message: "amount is not a sum"},
+	}}
+
+	tests := []struct {
+		name      string
+		err       error
+		wantField string
+		wantRule  string
+		wantLen   int
+	}{
+		{name: "validator", err: validationErr, wantField: "Order.OrderUID", wantRule: "required", wantLen: -1},
+		{name: "schema", err: fmt.Errorf("decode: %w", schemaErr), wantField: "payment.amount", wantRule: "required", wantLen: 1},
+		{name: "rules", err: rulesErr, wantField: "payment.amount", wantRule: rules.RuleAmount, wantLen: 1},
+		{name: "other", err: errors.New("unexpected EOF"), wantLen: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rejectionErrors(tt.err)
+			if got == nil {
+				t.Fatal("rejectionErrors() = nil, want not nil slice")
+			}
+			if tt.wantLen >= 0 && len(got) != tt.wantLen {
+				t.Fatalf("rejectionErrors() = %v, want %d errors", got, tt.wantLen)
+			}
+			if tt.wantField == "" {
+				return
+			}
+			for _, e := range got {
+				if e.Field == tt.wantField && e.Rule == tt.wantRule {
+					if strings.ContainsRune(e.Message, 0) {
+						t.Errorf("error message %q is not sanitized", e.Message)
+					}
+					return
+				}
+			}
+			t.Errorf("rejectionErrors() = %v, want %s %s error", got, tt.wantField, tt.wantRule)
+		})
+	}
+}
+
+func TestSaveRejection(t *testing.T) {
+	message := &broker.Message{
+		Key:       []byte("order\x00-1"),
+		Value:     []byte("\xff{}"),
+		Headers:   map[string][]byte{"content-type": []byte("application/json\xff")},
+		Topic:     "orders",
+		Partition: 2,
+		Offset:    42,
+	}
+
+	t.Run("saved", func(t *testing.T) {
+		store := &fakeStorage{}
+		var trace brokermiddlewares.Trace
+		handler := brokermiddlewares.Tracing()(func(message *broker.Message) error {
+			trace, _ = brokermiddlewares.GetTrace(message.Context())
+			return saveRejection(noplogger.New(), store, broker.StreamOrders, message, models.RejectionDecode, errors.New("bad\x00 json"))
+		})
+		if err := handler(message); err != nil {
+			t.Fatalf("saveRejection() error = %v", err)
+		}
+		if len(store.rejections) != 1 {
+			t.Fatalf("saved %d rejections, want 1", len(store.rejections))
+		}
+
+		got := store.rejections[0]
+		if got.Reason != models.RejectionDecode || got.Stream != string(broker.StreamOrders) {
+			t.Errorf("rejection reason, stream = %s, %s", got.Reason, got.Stream)
+		}
+		if got.Key != "order-1" || got.Error != "bad json" || got.Headers["content-type"] != "application/json" {
+			t.Errorf("rejection is not sanitized: key %q, error %q, headers %v", got.Key, got.Error, got.Headers)
+		}
+		// payload is kept as consumed
+		if string(got.Payload) != string(message.Value) {
+			t.Errorf("rejection payload = %q, want %q", got.Payload, message.Value)
+		}
+		if got.Source.Topic != "orders" || *got.Source.Partition != 2 || *got.Source.Offset != 42 {
+			t.Errorf("rejection source = %+v", got.Source)
+		}
+		if got.Source.TraceParent != trace.TraceParent() {
+			t.Errorf("rejection source traceparent = %s, want %s", got.Source.TraceParent, trace.TraceParent())
+		}
+	})
+
+	t.Run("storage error is returned", func(t *testing.T) {
+		store := &fakeStorage{rejectionErr: errors.New("connection refused")}
+		if err := saveRejection(noplogger.New(), store, broker.StreamOrders, message, models.RejectionDecode, errors.New("bad json")); err == nil {
+			t.Error("saveRejection() error = nil, want storage error")
+		}
+	})
+}
+
+func TestOrdersHandlerRejections(t *testing.T) {
+	schemas, err := schema.New()
+	if err != nil {
+		t.Fatalf("schema.New() error = %v", err)
+	}
+	dir := t.TempDir()
+	payloads, err := payload.New(&config.PayloadConfig{StoreDir: dir, MaxSize: 1 << 16})
+	if err != nil {
+		t.Fatalf("payload.New() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if err := payloads.Close(); err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+	})
+	codecs := codec.NewRegistry(codec.NewJSON(schemas))
+	orderRules := rules.New(&config.RulesConfig{DefaultAction: rules.ActionReject})
+
+	tests := []struct {
+		name         string
+		value        string
+		headers      map[string]string
+		result       storage.SaveResult
+		saveErr      error
+		rejectionErr error
+		wantErr      bool
+		wantReason   string
+		saved        bool
+	}{
+		{name: "saved", value: order, saved: true},
+		{name: "updated", value: order, result: storage.SaveUpdated, saved: true},
+		{name: "ignored is not notified", value: order, result: storage.SaveIgnored, saved: true},
+		{
+			name:       "invalid payload",
+			value:      order,
+			headers:    map[string]string{payload.HeaderContentEncoding: "br"},
+			wantReason: models.RejectionPayload,
+		},
+		{
+			name:    "missing claim-check object is retried",
+			headers: map[string]string{payload.HeaderClaimCheck: "missing.json", payload.HeaderClaimCheckDigest: "sha256:" + strings.Repeat("0", 64)},
+			wantErr: true,
+		},
+		{
+			name:       "unknown content type",
+			value:      order,
+			headers:    map[string]string{codec.HeaderContentType: "text/plain"},
+			wantReason: models.RejectionContentType,
+		},
+		{name: "invalid JSON", value: `{"order_uid":`, wantReason: models.RejectionDecode},
+		{
+			name:       "schema violation",
+			value:      strings.Replace(order, `"entry": "WBIL",`, "", 1),
+			wantReason: models.RejectionDecode,
+		},
+		{
+			name:       "business rules violation",
+			value:      strings.Replace(order, `"amount": 1817`, `"amount": 1000`, 1),
+			wantReason: models.RejectionRules,
+		},
+		{
+			name:       "conflict",
+			value:      order,
+			saveErr:    fmt.Errorf("%w: orders_track_number_key", storage.ErrUniqueViolation),
+			wantReason: models.RejectionConflict,
+			saved:      true,
+		},
+		{name: "already processed", value: order, saveErr: storage.ErrAlreadyProcessed, saved: true},
+		{name: "storage error is returned", value: order, saveErr: errors.New("connection refused"), wantErr: true, saved: true},
+		{
+			name:         "rejection storage error is returned",
+			value:        `{"order_uid":`,
+			rejectionErr: errors.New("connection refused"),
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.result
+			if result == "" {
+				result = storage.SaveCreated
+			}
+			store := &fakeStorage{result: result, err: tt.saveErr, rejectionErr: tt.rejectionErr}
+			notifier := &fakeNotifier{}
+			handler := OrdersHandler(noplogger.New(), store, models.NewValidator(), payloads, codecs, orderRules, notifier)
+
+			headers := make(map[string][]byte, len(tt.headers))
+			for k, v := range tt.headers {
+				headers[k] = []byte(v)
+			}
+			err := handler(&broker.Message{Topic: "orders", Key: []byte("b563feb7b2b84b6test"), Value: []byte(tt.value), Headers: headers})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("handler() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if saved := len(store.orders) > 0; saved != tt.saved {
+				t.Errorf("order saved = %v, want %v", saved, tt.saved)
+			}
+
+			var reason string
+			if len(store.rejections) > 0 {
+				reason = store.rejections[0].Reason
+			}
+			if reason != tt.wantReason {
+				t.Errorf("rejection reason = %q, want %q", reason, tt.wantReason)
+			}
+			if notified := len(notifier.orders) > 0; notified != (tt.saved && tt.saveErr == nil && result != storage.SaveIgnored) {
+				t.Errorf("order notified = %v", notified)
+			}
+		})
+	}
+}
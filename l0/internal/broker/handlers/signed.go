@@ -0,0 +1,58 @@
+package brokerhandlers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"wb-tech-l0/internal/broker"
+	brokermiddlewares "wb-tech-l0/internal/broker/middlewares"
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/signing"
+)
+
+var (
+	rejectedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "broker_messages_rejected_total",
+		Help: "Consumed messages rejected by signature verification.",
+	}, []string{"stream", "reason"})
+	deadLetterFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "broker_dead_letter_failures_total",
+		Help: "Failed publishings of rejected messages to dead letters stream.",
+	}, []string{"stream"})
+)
+
+// SignedHandler returns a handler function for broker.Subscribe verifying signatures
+// of stream messages before passing them to next handler.
+// rejected messages are published to broker.StreamDeadLetters with traceparent
+// of handling span and committed.
+// if dead letter can't be published, error is returned to NOT commit message,
+// so rejected messages are never lost.
+func SignedHandler(log logger.Logger, verifier *signing.Verifier, producer broker.Producer, stream broker.Stream, next func(message *broker.Message) error) func(message *broker.Message) error {
+	return func(message *broker.Message) error {
+		err := verifier.Verify(message)
+		if err == nil {
+			return next(message)
+		}
+
+		reason := signing.Reason(err)
+		log := log.With(logger.Field("message_key", string(message.Key)), logger.Field("stream", string(stream)),
+			logger.Field("reason", reason), logger.Field("key_id", string(message.Headers[signing.HeaderKeyID])))
+
+		deadLetter := broker.DeadLetter(message, reason, err)
+		// dead letter continues trace of handling span
+		if trace, ok := brokermiddlewares.GetTrace(message.Context()); ok {
+			deadLetter.Headers[brokermiddlewares.HeaderTraceParent] = []byte(trace.TraceParent())
+		}
+		if err := producer.Publish(message.Context(), broker.StreamDeadLetters, deadLetter); err != nil {
+			deadLetterFailures.WithLabelValues(string(stream)).Inc()
+			log.Error("Failed to dead letter rejected message", logger.Error(err))
+			// returning error to NOT commit message in broker
+			return err
+		}
+
+		rejectedMessages.WithLabelValues(string(stream), reason).Inc()
+		log.Warn("Message signature verification failed. Message is dead lettered", logger.Error(err))
+		// returning nil to commit message in Subscribe
+		return nil
+	}
+}
@@ -0,0 +1,93 @@
+package brokerhandlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/broker"
+	brokermiddlewares "wb-tech-l0/internal/broker/middlewares"
+	"wb-tech-l0/internal/config"
+	noplogger "wb-tech-l0/internal/logger/nop"
+	"wb-tech-l0/internal/signing"
+)
+
+// fakeProducer records published dead letters
+type fakeProducer struct {
+	err       error
+	published []*broker.Message
+}
+
+func (p *fakeProducer) Close() error { return nil }
+
+func (p *fakeProducer) Publish(_ context.Context, _ broker.Stream, messages ...*broker.Message) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, messages...)
+	return nil
+}
+
+func TestSignedHandler(t *testing.T) {
+	const secret = "new-secret-new-secret-new-secret"
+	verifier := signing.New(&config.SigningConfig{Keys: map[string]string{"new": secret}, MaxAge: time.Minute})
+
+	signed := func(age time.Duration, reread bool) *broker.Message {
+		m := &broker.Message{
+			Key: []byte("order-1"), Value: []byte("{}"), Timestamp: time.Now().Add(-age),
+			Headers: map[string][]byte{brokermiddlewares.HeaderTraceParent: []byte("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")},
+		}
+		signing.Sign("new", []byte(secret), m)
+		m.Reread = reread
+		return m
+	}
+
+	tests := []struct {
+		name        string
+		message     *broker.Message
+		producerErr error
+		wantErr     bool
+		handled     bool
+		deadLetter  bool
+	}{
+		{name: "valid", message: signed(time.Second, false), handled: true},
+		{name: "expired is dead lettered", message: signed(time.Hour, false), deadLetter: true},
+		{name: "expired reread is handled", message: signed(time.Hour, true), handled: true},
+		{
+			name:        "dead letter failure is returned",
+			message:     signed(time.Hour, false),
+			producerErr: errors.New("broker is down"),
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer := &fakeProducer{err: tt.producerErr}
+			handled := false
+			handler := brokermiddlewares.Tracing()(SignedHandler(noplogger.New(), verifier, producer, broker.StreamOrders, func(*broker.Message) error {
+				handled = true
+				return nil
+			}))
+
+			if err := handler(tt.message); (err != nil) != tt.wantErr {
+				t.Fatalf("handler() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if handled != tt.handled {
+				t.Errorf("message handled = %v, want %v", handled, tt.handled)
+			}
+			if deadLetter := len(producer.published) > 0; deadLetter != tt.deadLetter {
+				t.Errorf("message dead lettered = %v, want %v", deadLetter, tt.deadLetter)
+			}
+			// dead letter continues trace of message
+			if tt.deadLetter {
+				traceParent := string(producer.published[0].Headers[brokermiddlewares.HeaderTraceParent])
+				if !strings.HasPrefix(traceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || traceParent == string(tt.message.Headers[brokermiddlewares.HeaderTraceParent]) {
+					t.Errorf("dead letter traceparent = %s, want handling span", traceParent)
+				}
+			}
+		})
+	}
+}
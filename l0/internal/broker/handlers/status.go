@@ -0,0 +1,83 @@
+package brokerhandlers
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+
+	"wb-tech-l0/internal/broker"
+	"wb-tech-l0/internal/events"
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/storage"
+)
+
+// StatusesHandler returns a handler function for broker.Subscribe for handling order status changes messages.
+// handler must return error if something is wrong with the message handling.
+// on error, broker will NOT commit message and there could be retries.
+// Not allowed transitions are skipped, changes of missing orders are parked by storage.
+// changed orders are invalidated by uid first, so they are not served stale even if they
+// can't be loaded, then notifier is notified about them with new status and timeline.
+func StatusesHandler(log logger.Logger, store storage.Storage, validate *validator.Validate, notifier events.Notifier, invalidator events.Invalidator) func(message *broker.Message) error {
+	return func(message *broker.Message) error {
+		// add message key to log
+		log := log.With(logger.Field("message_key", string(message.Key)))
+
+		var change models.StatusChange
+		// parsing message value in status change struct
+		if err := json.Unmarshal(message.Value, &change); err != nil {
+			log.Debug("Invalid JSON message. Handler skipping message", logger.Error(err))
+			// returning nil to commit message in Subscribe
+			return nil
+		}
+
+		// validating
+		if err := validate.Struct(change); err != nil {
+			log.Debug("Invalid status change schema. Handler skipping message", logger.Error(err))
+			// returning nil to commit message in Subscribe
+			return nil
+		}
+
+		// adding order uid to logger for chaining storage logs with handler logs
+		log = log.With(logger.Field("order_uid", change.OrderUID), logger.Field("status", change.Status))
+
+		// saving status change
+		result, err := store.ChangeOrderStatus(message.Context(), &change, messageSource(message))
+		if err != nil {
+			if errors.Is(err, storage.ErrInvalidTransition) {
+				log.Warn("Skipping not allowed status change", logger.Error(err))
+				return nil
+			}
+			log.Warn("Failed to change order status", logger.Error(err))
+			// returning error to NOT commit message in broker
+			return err
+		}
+
+		log.Debug("Status message handled", logger.Field("result", result))
+		switch result {
+		case storage.SaveIgnored:
+			// order already has this status
+			return nil
+		case storage.SavePending:
+			// order is not saved yet, change is applied and notified with order
+			log.Info("Status change of unknown order is parked")
+			return nil
+		}
+
+		// invalidating cache without loading order, loading can fail
+		invalidator.InvalidateOrder(change.OrderUID)
+
+		// loading changed order to notify in-process subscribers (streams, waiters).
+		// webhooks and producer are fed by outbox message saved with change
+		order, err := store.GetOrder(message.Context(), change.OrderUID)
+		if err != nil {
+			// status is saved and cache is invalidated, streams just miss this change
+			log.Warn("Failed to load order with changed status", logger.Error(err))
+			return nil
+		}
+		notifier.OrderSaved(order)
+
+		return nil
+	}
+}
@@ -0,0 +1,156 @@
+package brokerhandlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"wb-tech-l0/internal/broker"
+	noplogger "wb-tech-l0/internal/logger/nop"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/storage"
+)
+
+// fakeStorage is a storage returning configured save and status change result.
+// Not implemented methods panic on nil embedded interface
+type fakeStorage struct {
+	storage.Storage
+
+	result  storage.SaveResult
+	err     error
+	getErr  error
+	changes []models.StatusChange
+	orders  []models.Order
+
+	rejectionErr error
+	rejections   []models.Rejection
+}
+
+func (s *fakeStorage) SaveOrder(_ context.Context, order *models.Order, _ models.Source, _ *models.Position) (storage.SaveResult, error) {
+	s.orders = append(s.orders, *order)
+	return s.result, s.err
+}
+
+func (s *fakeStorage) SaveRejection(_ context.Context, rejection *models.Rejection) error {
+	if s.rejectionErr != nil {
+		return s.rejectionErr
+	}
+	rejection.ID = "rejection-1"
+	s.rejections = append(s.rejections, *rejection)
+	return nil
+}
+
+func (s *fakeStorage) ChangeOrderStatus(_ context.Context, change *models.StatusChange, _ models.Source) (storage.SaveResult, error) {
+	s.changes = append(s.changes, *change)
+	return s.result, s.err
+}
+
+func (s *fakeStorage) GetOrder(_ context.Context, uid string) (*models.Order, error) {
+	if s.getErr != nil {
+		return nil, s.getErr
+	}
+	return &models.Order{OrderUID: uid, Status: s.changes[len(s.changes)-1].Status}, nil
+}
+
+// fakeNotifier records notified and invalidated orders
+type fakeNotifier struct {
+	orders      []*models.Order
+	invalidated []string
+}
+
+func (n *fakeNotifier) OrderSaved(order *models.Order) {
+	n.orders = append(n.orders, order)
+}
+
+func (n *fakeNotifier) InvalidateOrder(uid string) {
+	n.invalidated = append(n.invalidated, uid)
+}
+
+func TestStatusesHandler(t *testing.T) {
+	errDB := errors.New("connection refused")
+
+	tests := []struct {
+		name        string
+		value       string
+		result      storage.SaveResult
+		err         error
+		getErr      error
+		wantErr     bool
+		saved       bool
+		notified    bool
+		invalidated bool
+	}{
+		{
+			name:   "unknown order is parked",
+			value:  `{"order_uid":"b563feb7b2b84b6test","status":"paid","changed_at":"2021-11-26T06:22:19Z"}`,
+			result: storage.SavePending,
+			saved:  true,
+		},
+		{
+			name:  "illegal transition is skipped",
+			value: `{"order_uid":"b563feb7b2b84b6test","status":"delivered","changed_at":"2021-11-26T06:22:19Z"}`,
+			err:   fmt.Errorf("%w: created to delivered", storage.ErrInvalidTransition),
+			saved: true,
+		},
+		{
+			name:   "duplicate is ignored",
+			value:  `{"order_uid":"b563feb7b2b84b6test","status":"paid","changed_at":"2021-11-26T06:22:19Z"}`,
+			result: storage.SaveIgnored,
+			saved:  true,
+		},
+		{
+			name:        "applied change is notified",
+			value:       `{"order_uid":"b563feb7b2b84b6test","status":"paid","changed_at":"2021-11-26T06:22:19Z"}`,
+			result:      storage.SaveUpdated,
+			saved:       true,
+			notified:    true,
+			invalidated: true,
+		},
+		{
+			name:        "applied change is invalidated if order can't be loaded",
+			value:       `{"order_uid":"b563feb7b2b84b6test","status":"paid","changed_at":"2021-11-26T06:22:19Z"}`,
+			result:      storage.SaveUpdated,
+			getErr:      errDB,
+			saved:       true,
+			invalidated: true,
+		},
+		{
+			name:    "storage error is returned",
+			value:   `{"order_uid":"b563feb7b2b84b6test","status":"paid","changed_at":"2021-11-26T06:22:19Z"}`,
+			err:     errDB,
+			wantErr: true,
+			saved:   true,
+		},
+		{
+			name:  "invalid status is skipped",
+			value: `{"order_uid":"b563feb7b2b84b6test","status":"lost","changed_at":"2021-11-26T06:22:19Z"}`,
+		},
+		{
+			name:  "invalid JSON is skipped",
+			value: `{"order_uid":`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStorage{result: tt.result, err: tt.err, getErr: tt.getErr}
+			notifier := &fakeNotifier{}
+			handler := StatusesHandler(noplogger.New(), store, models.NewValidator(), notifier, notifier)
+
+			err := handler(&broker.Message{Topic: "statuses", Key: []byte("b563feb7b2b84b6test"), Value: []byte(tt.value)})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("handler() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if saved := len(store.changes) > 0; saved != tt.saved {
+				t.Errorf("status change saved = %v, want %v", saved, tt.saved)
+			}
+			if notified := len(notifier.orders) > 0; notified != tt.notified {
+				t.Errorf("order notified = %v, want %v", notified, tt.notified)
+			}
+			if invalidated := len(notifier.invalidated) > 0; invalidated != tt.invalidated {
+				t.Errorf("order invalidated = %v, want %v", invalidated, tt.invalidated)
+			}
+		})
+	}
+}
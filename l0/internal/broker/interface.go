@@ -1,22 +1,85 @@
 package broker
 
-import "time"
+import (
+	"context"
+	"errors"
+	"time"
+
+	"wb-tech-l0/internal/models"
+)
 
 // Broker interface
 type Broker interface {
 	// Close closes the Broker connection
 	Close() error
-	// Subscribe starts main subscription loop
+	// Subscribe starts subscription loop of stream
 	// and blocks until something goes wrong or
 	// application is exiting. It takes handler which
 	// will be called on every fetched message.
+	// Every stream is subscribed by separate call.
 	// It must handle retries of message consumptions.
 	// It takes MaxWorkers amount of messages and handles them concurrently.
 	// Given handler must return error if something is wrong with actually message handling.
 	// On handler error method will NOT commit message.
 	// If something is wrong with the message itself (for example, invalid data)
 	// handler must skip message and return nil to commit it
-	Subscribe(handler func(message *Message) error)
+	Subscribe(stream Stream, handler func(message *Message) error)
+	// Pause stops fetching messages of all subscriptions until Resume.
+	// In-flight handlers are not interrupted. Subscriptions started
+	// while Broker is paused wait for Resume
+	Pause(ctx context.Context) error
+	// Resume resumes paused subscriptions. It is no-op if Broker is not paused
+	Resume()
+	// Drain pauses subscriptions and blocks until their in-flight
+	// handlers exit or ctx is done
+	Drain(ctx context.Context) error
+	// State returns consumer state
+	State() State
+}
+
+// State is a consumer state of Broker
+type State string
+
+// Consumer states
+const (
+	// StateRunning means that subscriptions fetch messages
+	StateRunning State = "running"
+	// StateDraining means that Broker is paused, but handlers are still running
+	StateDraining State = "draining"
+	// StatePaused means that Broker is paused and no handlers are running
+	StatePaused State = "paused"
+)
+
+// Stream is a logical stream of messages.
+// Brokers map streams to their topics (queues) in their configuration
+type Stream string
+
+// Streams consumed by application
+const (
+	// StreamOrders is a stream of created and updated orders
+	StreamOrders Stream = "orders"
+	// StreamStatuses is a stream of order status changes
+	StreamStatuses Stream = "statuses"
+)
+
+// Streams published by application
+const (
+	// StreamEvents is a stream of order events relayed from outbox
+	StreamEvents Stream = "events"
+	// StreamDeadLetters is a stream of rejected consumed messages
+	StreamDeadLetters Stream = "dead-letters"
+)
+
+// Producer interface is a publishing side of broker
+type Producer interface {
+	// Close flushes pending messages and closes the Producer connection
+	Close() error
+	// Publish publishes messages to stream and blocks until
+	// all of them are acknowledged by broker or ctx is done.
+	// It must handle retries of publishing.
+	// On error some of messages could be already published,
+	// so callers retrying Publish must tolerate duplicates
+	Publish(ctx context.Context, stream Stream, messages ...*Message) error
 }
 
 // Message is a universal struct for all brokers messages.
@@ -30,4 +93,102 @@ type Message struct {
 	Timestamp time.Time
 	// Headers is a message headers
 	Headers map[string][]byte
+
+	// Topic is a topic message was consumed from
+	Topic string
+	// Partition is a topic partition message was consumed from
+	Partition int
+	// Offset is a message offset in partition
+	Offset int64
+	// Reread is set if message is read again: by replay or after offsets
+	// were reset backwards. Such messages were accepted when they were live,
+	// so checks of their age are skipped
+	Reread bool
+	// Position is a consumer position after the message. It is set only
+	// if broker keeps offsets in OffsetStore, handler must store it
+	// atomically with handling result to consume message exactly once
+	Position *models.Position
+
+	// ctx is a handling context set by middlewares
+	ctx context.Context
+}
+
+// Context returns message handling context. It is never nil,
+// message without context set by middlewares has background context
+func (m *Message) Context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+// WithContext returns shallow copy of message with handling context changed to ctx
+func (m *Message) WithContext(ctx context.Context) *Message {
+	c := *m
+	c.ctx = ctx
+	return &c
+}
+
+// OffsetStore keeps consumer positions outside of broker,
+// so they can be saved in the same transaction as consumed data
+type OffsetStore interface {
+	// Offsets returns stored next offsets of group topic partitions
+	Offsets(ctx context.Context, group, topic string) (map[int]int64, error)
+	// CommitOffset stores position if it is after stored one
+	CommitOffset(ctx context.Context, position models.Position) error
+	// ResetOffsets replaces stored next offsets of group topic partitions
+	ResetOffsets(ctx context.Context, group, topic string, offsets map[int]int64) error
+}
+
+// OffsetStoreUser is implemented by brokers able to resume
+// consuming from positions kept in OffsetStore
+type OffsetStoreUser interface {
+	// UseOffsetStore sets store of consumer positions.
+	// It must be called before Subscribe
+	UseOffsetStore(store OffsetStore)
+}
+
+// Errors of consumer group offsets tools
+var (
+	// ErrGroupActive is returned if consumer group offsets can't be moved
+	// because group has active members of other application instances
+	ErrGroupActive = errors.New("consumer group has active members")
+	// ErrUnknownPartition is returned if offset target has partition missing in topic
+	ErrUnknownPartition = errors.New("unknown topic partition")
+	// ErrOffsetOutOfRange is returned if offset target has offset outside of partition messages
+	ErrOffsetOutOfRange = errors.New("offset is out of range")
+)
+
+// OffsetTarget is a position in stream partitions: either the first
+// messages at or after Timestamp or explicit Offsets of partitions
+type OffsetTarget struct {
+	// Timestamp selects offsets of the first messages at or after it in every partition.
+	// Partitions without such messages are selected at their ends
+	Timestamp time.Time
+	// Offsets are explicit offsets of partitions. Missing partitions are not selected
+	Offsets map[int]int64
+}
+
+// IsZero reports whether target selects nothing
+func (t OffsetTarget) IsZero() bool {
+	return t.Timestamp.IsZero() && len(t.Offsets) == 0
+}
+
+// OffsetResetter is implemented by brokers able to move consumer group offsets
+type OffsetResetter interface {
+	// ResetOffsets moves consumer group offsets of stream partitions to target
+	// and returns new next offsets. Subscriptions of the group are stopped while
+	// offsets are moved and continue from new offsets. It returns ErrGroupActive
+	// if group has members outside of this Broker
+	ResetOffsets(ctx context.Context, stream Stream, target OffsetTarget) (map[int]int64, error)
+}
+
+// Replayer is implemented by brokers able to read stream range without consumer group
+type Replayer interface {
+	// Replay reads stream messages from offsets of from target to offsets of to target
+	// (exclusive) by separate consumer and calls handler on every message.
+	// Zero to target means partitions ends at replay start. Consumer group
+	// offsets are not changed. Failed messages are retried until success
+	// or ctx is done, Replay blocks until range is handled
+	Replay(ctx context.Context, stream Stream, from, to OffsetTarget, handler func(message *Message) error) error
 }
@@ -26,6 +26,20 @@ type Config struct {
 	// ReadTimeOut is a timeout for reading from Kafka.
 	ReadTimeOut time.Duration `env:"KAFKA_READ_TIMEOUT" envDefault:"5s" validate:"gte=100ms"`
 
+	// StatusTopic is a Kafka topic to consume order status changes from.
+	StatusTopic string `env:"KAFKA_STATUS_TOPIC" envDefault:"order-statuses" validate:"required,nefield=Topic"`
+	// ProducerTopic is a Kafka topic to publish events to.
+	ProducerTopic string `env:"KAFKA_PRODUCER_TOPIC" envDefault:"order-events" validate:"required"`
+	// DeadLetterTopic is a Kafka topic to publish rejected messages to.
+	DeadLetterTopic string `env:"KAFKA_DEAD_LETTER_TOPIC" envDefault:"orders-dlq" validate:"required,nefield=Topic,nefield=StatusTopic"`
+	// WriteTimeOut is a timeout for writing to Kafka.
+	WriteTimeOut time.Duration `env:"KAFKA_WRITE_TIMEOUT" envDefault:"10s" validate:"gte=100ms"`
+
+	// ExternalOffsets turns on keeping consumer offsets in external store (storage)
+	// instead of Kafka. Offsets are saved atomically with orders, so every message
+	// is handled exactly once. Partitions are handled sequentially, MaxWorkers is not used
+	ExternalOffsets bool `env:"KAFKA_EXTERNAL_OFFSETS" envDefault:"false"`
+
 	// MaxWorkers is a maximum number of concurrent workers for messages handling
 	MaxWorkers int `env:"MAX_WORKERS" envDefault:"1" validate:"gte=1"`
 
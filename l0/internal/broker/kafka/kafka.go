@@ -13,12 +13,29 @@ import (
 
 // Kafka is a Broker interface implementation for Kafka
 type Kafka struct {
-	reader       *kafkago.Reader
+	cfg          *Config
+	offsets      broker.OffsetStore
 	readTimeout  time.Duration
 	retryTimeout time.Duration
 	maxRetries   int
 	maxWorkers   int
 
+	// control serializes pausing, resuming and stopping of loops
+	control sync.Mutex
+
+	// mu guards running subscription loops of streams and pause state
+	mu    sync.Mutex
+	loops map[broker.Stream]*loop
+	// resume is closed on Resume, it is nil if subscriptions are not paused
+	resume chan struct{}
+	// consuming is a number of running consume calls
+	consuming int
+	// idle is closed when consuming is 0
+	idle chan struct{}
+	// rereadUntil is partitions ends of topics at their last offsets reset,
+	// messages before them are read again
+	rereadUntil map[string]map[int]int64
+
 	ctx context.Context
 	log logger.Logger
 }
@@ -27,38 +44,61 @@ type Kafka struct {
 func New(ctx context.Context, cfg *Config, log logger.Logger) (*Kafka, error) {
 	log.Debug("Creating broker connection")
 
-	kafkaCfg := kafkago.ReaderConfig{
-		Brokers:          cfg.Brokers,
-		Topic:            cfg.Topic,
-		GroupID:          cfg.GroupID,
-		StartOffset:      cfg.StartOffset,
-		MinBytes:         cfg.MinBytes,
-		MaxBytes:         cfg.MaxBytes,
-		ReadBatchTimeout: cfg.ReadTimeOut,
-		Logger:           nil,
-		ErrorLogger:      nil,
-		MaxAttempts:      cfg.MaxRetries,
-	}
-
-	reader := kafkago.NewReader(kafkaCfg)
-
-	return &Kafka{
-		reader:       reader,
+	k := &Kafka{
+		cfg:          cfg,
 		readTimeout:  cfg.ReadTimeOut,
 		retryTimeout: cfg.RetryTimeOut,
 		maxRetries:   cfg.MaxRetries,
 		maxWorkers:   cfg.MaxWorkers,
+		loops:        make(map[broker.Stream]*loop),
+		idle:         make(chan struct{}),
+		rereadUntil:  make(map[string]map[int]int64),
 		log:          log,
 		ctx:          ctx,
-	}, nil
+	}
+	// nothing is consumed yet
+	close(k.idle)
+	k.updateState()
+
+	return k, nil
 }
 
-// Close closes the Kafka broker connection
+// Close closes the Kafka broker connection.
+// Readers are closed by subscription loops when application is exiting
 func (k *Kafka) Close() error {
-	return k.reader.Close()
+	return nil
+}
+
+// topic returns Kafka topic of consumed stream
+func (k *Kafka) topic(stream broker.Stream) (string, bool) {
+	switch stream {
+	case broker.StreamOrders:
+		return k.cfg.Topic, true
+	case broker.StreamStatuses:
+		return k.cfg.StatusTopic, true
+	default:
+		return "", false
+	}
 }
 
-// Subscribe starts main Kafka broker subscription loop
+// newReader creates consumer group reader of topic.
+// every stream topic has own reader in the same consumer group
+func (k *Kafka) newReader(topic string) *kafkago.Reader {
+	return kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers:          k.cfg.Brokers,
+		Topic:            topic,
+		GroupID:          k.cfg.GroupID,
+		StartOffset:      k.cfg.StartOffset,
+		MinBytes:         k.cfg.MinBytes,
+		MaxBytes:         k.cfg.MaxBytes,
+		ReadBatchTimeout: k.cfg.ReadTimeOut,
+		Logger:           nil,
+		ErrorLogger:      nil,
+		MaxAttempts:      k.cfg.MaxRetries,
+	})
+}
+
+// Subscribe starts Kafka broker subscription loop of stream topic
 // and blocks until something goes wrong or application is exiting.
 // It takes handler which will be called on every fetched message.
 // It handles the retries of message consumption.
@@ -66,39 +106,72 @@ func (k *Kafka) Close() error {
 // Given handler must return error if something is wrong with actually message handling.
 // On handler error method will NOT commit message.
 // If something is wrong with the message itself (for example, bad json)
-// handler must skip message and return nil to commit it
-func (k *Kafka) Subscribe(handler func(message *broker.Message) error) {
+// handler must skip message and return nil to commit it.
+// Subscription leaves consumer group while it is stopped by offsets tools
+// and joins it again after them
+func (k *Kafka) Subscribe(stream broker.Stream, handler func(message *broker.Message) error) {
+	topic, ok := k.topic(stream)
+	if !ok {
+		k.log.Error("Unknown broker stream", logger.Field("stream", stream))
+		return
+	}
+
+	consume := func(ctx context.Context) {
+		k.consume(ctx, stream, topic, handler)
+	}
+	// orders consumer group is joined by consumeExternal itself if offsets are external
+	if stream == broker.StreamOrders && k.cfg.ExternalOffsets {
+		consume = func(ctx context.Context) {
+			k.consumeExternal(ctx, handler)
+		}
+	}
+
+	k.runLoop(stream, consume)
+}
+
+// consume is a subscription loop of consumer group reader.
+// It returns when ctx is done and all handlers exited
+func (k *Kafka) consume(ctx context.Context, stream broker.Stream, topic string, handler func(message *broker.Message) error) {
+	reader := k.newReader(topic)
+
 	// add stats to log
-	stats := k.reader.Stats()
-	log := k.log.With(logger.Field("client_id", stats.ClientID), logger.Field("topic", stats.Topic))
+	stats := reader.Stats()
+	log := k.log.With(logger.Field("client_id", stats.ClientID), logger.Field("topic", stats.Topic), logger.Field("stream", stream))
 
 	log.Debug("Starting broker subscription loop")
 	defer log.Debug("Broker subscription loop exited")
 
+	// closing reader leaves consumer group
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Warn("Failed to close reader", logger.Error(err))
+		}
+	}()
+
 	// creating semaphore to limit the number of maximum concurrent workers
 	semaphore := make(chan struct{}, k.maxWorkers)
 	var wg sync.WaitGroup
 
-	// wait for all message handlers to exit
+	// wait for all message handlers to exit before closing reader
 	defer func() {
 		wg.Wait()
 	}()
 
 	// main loop
 	for {
-		// select on ctx to return when application is exiting
+		// select on ctx to return when application is exiting or loop is stopped
 		select {
-		case <-k.ctx.Done():
+		case <-ctx.Done():
 			log.Debug("Context cancelled during subscription loop")
 			return
 		default:
 		}
 		// fetching message. this call will block until
 		// we got message or error or context is cancelled
-		msg, err := k.reader.FetchMessage(k.ctx)
+		msg, err := reader.FetchMessage(ctx)
 		if err != nil {
 			// if error is about context cancelling
-			if k.ctx.Err() != nil {
+			if ctx.Err() != nil {
 				log.Debug("Context cancelled during message reading")
 				return
 			}
@@ -108,12 +181,9 @@ func (k *Kafka) Subscribe(handler func(message *broker.Message) error) {
 
 			// waiting retry timeout and try to fetch message again
 			// select on ctx to return when application is exiting
-			select {
-			case <-k.ctx.Done():
+			if !k.wait(ctx) {
 				log.Debug("Context cancelled during retrying message reading")
 				return
-			case <-time.After(k.retryTimeout):
-				// continue fetching
 			}
 			// to the start of for loop to fetch message again
 			continue
@@ -121,7 +191,7 @@ func (k *Kafka) Subscribe(handler func(message *broker.Message) error) {
 
 		// taking the semaphore slot and waiting for context cancellation if we block here
 		select {
-		case <-k.ctx.Done():
+		case <-ctx.Done():
 			log.Debug("Context cancelled during waiting for semaphore slot")
 			return
 		case semaphore <- struct{}{}:
@@ -130,17 +200,8 @@ func (k *Kafka) Subscribe(handler func(message *broker.Message) error) {
 		}
 
 		// making default Message struct from received message
-		headers := make(map[string][]byte, len(msg.Headers))
-		for _, h := range msg.Headers {
-			headers[h.Key] = h.Value
-		}
-
-		message := &broker.Message{
-			Key:       msg.Key,
-			Value:     msg.Value,
-			Timestamp: msg.Time,
-			Headers:   headers,
-		}
+		message := newMessage(msg)
+		message.Reread = k.reread(msg.Topic, msg.Partition, msg.Offset)
 
 		// handling message concurrently
 		go func(message *broker.Message) {
@@ -152,19 +213,19 @@ func (k *Kafka) Subscribe(handler func(message *broker.Message) error) {
 
 			// add message key to log (this is goroutine's local logger)
 			log := log.With(logger.Field("message_key", string(msg.Key)))
-			// logging message value
-			log.Debug("Message received", logger.Field("message_value", string(msg.Value)))
 
 			// now when we got message we need to handle it.
-			// retries of handling must be handled in handler
-			if err = handler(message); err != nil {
-				log.Warn("Message handler returned error. Not commiting message", logger.Error(err))
+			// retries of handling must be handled in handler,
+			// received messages and errors are logged by handler middlewares
+			if err := handler(message); err != nil {
+				log.Debug("Message handler returned error. Not commiting message", logger.Error(err))
 				// NOT COMMITING MESSAGE ON HANDLER ERROR
 				return
 			}
 
-			// COMMIT ONLY IF MESSAGE HANDLED SUCCESSFULLY
-			if err = k.reader.CommitMessages(k.ctx, msg); err != nil {
+			// COMMIT ONLY IF MESSAGE HANDLED SUCCESSFULLY.
+			// application context is used, so handled messages are committed even if loop is stopped
+			if err := reader.CommitMessages(k.ctx, msg); err != nil {
 				log.Warn("Failed to commit broker message", logger.Error(err))
 			} else {
 				log.Debug("Message committed")
@@ -172,3 +233,21 @@ func (k *Kafka) Subscribe(handler func(message *broker.Message) error) {
 		}(message)
 	}
 }
+
+// newMessage makes default Message struct from Kafka message
+func newMessage(msg kafkago.Message) *broker.Message {
+	headers := make(map[string][]byte, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[h.Key] = h.Value
+	}
+
+	return &broker.Message{
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Timestamp: msg.Time,
+		Headers:   headers,
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+	}
+}
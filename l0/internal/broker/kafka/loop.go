@@ -0,0 +1,299 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"wb-tech-l0/internal/broker"
+	"wb-tech-l0/internal/logger"
+)
+
+var consumerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "broker_consumer_state",
+	Help: "Consumer state, 1 for current state: running, draining or paused.",
+}, []string{"state"})
+
+// loop controls running subscription loop of stream
+type loop struct {
+	// stops receives stop requests, loop is stopped until request resume is closed
+	stops chan stop
+	// exited is closed when loop exits
+	exited chan struct{}
+}
+
+// stop is a request to stop subscription loop
+type stop struct {
+	// stopped receives value when loop consuming is stopped, it can be nil
+	stopped chan<- struct{}
+	// resume is closed when loop must continue consuming
+	resume <-chan struct{}
+}
+
+// runLoop runs consume until application is exiting.
+// consume is restarted with new context after every stop request
+func (k *Kafka) runLoop(stream broker.Stream, consume func(ctx context.Context)) {
+	l := &loop{stops: make(chan stop), exited: make(chan struct{})}
+
+	k.mu.Lock()
+	k.loops[stream] = l
+	k.mu.Unlock()
+	defer func() {
+		k.mu.Lock()
+		delete(k.loops, stream)
+		k.mu.Unlock()
+		close(l.exited)
+	}()
+
+	log := k.log.With(logger.Field("stream", stream))
+	for {
+		// loops started or resumed while subscriptions are paused wait for resume
+		k.mu.Lock()
+		resume := k.resume
+		k.mu.Unlock()
+		if resume != nil {
+			select {
+			case <-resume:
+			case <-k.ctx.Done():
+				return
+			}
+		}
+
+		req, ok := k.runUntilStop(l, consume)
+		if !ok {
+			return
+		}
+
+		log.Info("Broker subscription stopped")
+		if req.stopped != nil {
+			req.stopped <- struct{}{}
+		}
+		select {
+		case <-req.resume:
+			log.Info("Broker subscription resumed")
+		case <-k.ctx.Done():
+			return
+		}
+	}
+}
+
+// runUntilStop runs consume until application is exiting or loop receives stop request.
+// It returns stop request if consume is stopped by it
+func (k *Kafka) runUntilStop(l *loop, consume func(ctx context.Context)) (stop, bool) {
+	ctx, cancel := context.WithCancel(k.ctx)
+	defer cancel()
+
+	requested := make(chan stop, 1)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		select {
+		case req := <-l.stops:
+			requested <- req
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	k.setConsuming(1)
+	consume(ctx)
+	k.setConsuming(-1)
+	cancel()
+	// waiting for stop requests receiver, so received request is never lost
+	<-finished
+
+	select {
+	case req := <-requested:
+		return req, true
+	default:
+		return stop{}, false
+	}
+}
+
+// setConsuming changes number of running consume calls. consume returns only
+// after its in-flight handlers exited, so paused broker is drained when it is 0
+func (k *Kafka) setConsuming(delta int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.consuming += delta
+	if k.consuming == 0 {
+		close(k.idle)
+	} else if delta > 0 && k.consuming == 1 {
+		k.idle = make(chan struct{})
+	}
+	k.updateState()
+}
+
+// stopLoops sends stop requests with resume channel to all running loops.
+// stopped receives value from every stopped loop, it returns number of stopped loops
+func (k *Kafka) stopLoops(ctx context.Context, resume <-chan struct{}, stopped chan<- struct{}) (int, error) {
+	k.mu.Lock()
+	loops := make([]*loop, 0, len(k.loops))
+	for _, l := range k.loops {
+		loops = append(loops, l)
+	}
+	k.mu.Unlock()
+
+	for i, l := range loops {
+		select {
+		case l.stops <- stop{stopped: stopped, resume: resume}:
+		case <-l.exited:
+			if stopped != nil {
+				stopped <- struct{}{}
+			}
+		case <-ctx.Done():
+			return i, ctx.Err()
+		}
+	}
+	return len(loops), nil
+}
+
+// whileStopped stops all running subscription loops, calls action and resumes loops.
+// Stopped loops leave consumer group, so action can change group offsets.
+// If subscriptions are paused, action is called after they are drained
+// and subscriptions stay paused
+func (k *Kafka) whileStopped(ctx context.Context, action func(ctx context.Context) error) error {
+	k.control.Lock()
+	defer k.control.Unlock()
+
+	k.mu.Lock()
+	paused := k.resume != nil
+	k.mu.Unlock()
+	if paused {
+		if err := k.waitIdle(ctx); err != nil {
+			return err
+		}
+		return action(ctx)
+	}
+
+	resume := make(chan struct{})
+	// resuming loops even if action fails or ctx is done
+	defer close(resume)
+
+	k.mu.Lock()
+	stopped := make(chan struct{}, len(k.loops))
+	k.mu.Unlock()
+	n, err := k.stopLoops(ctx, resume, stopped)
+	if err != nil {
+		return err
+	}
+	for range n {
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return action(ctx)
+}
+
+// Pause stops fetching messages of all subscriptions. In-flight handlers
+// are not interrupted, paused subscriptions leave consumer group after them,
+// so partitions can be assigned to other instances until Resume
+func (k *Kafka) Pause(ctx context.Context) error {
+	k.control.Lock()
+	defer k.control.Unlock()
+
+	k.mu.Lock()
+	if k.resume != nil {
+		k.mu.Unlock()
+		return nil
+	}
+	resume := make(chan struct{})
+	k.resume = resume
+	k.updateState()
+	k.mu.Unlock()
+
+	if _, err := k.stopLoops(ctx, resume, nil); err != nil {
+		// loops stopped before ctx is done are resumed
+		k.resumeLocked()
+		return err
+	}
+	k.log.Info("Broker subscriptions paused")
+	return nil
+}
+
+// Resume resumes paused subscriptions. It is no-op if they are not paused
+func (k *Kafka) Resume() {
+	k.control.Lock()
+	defer k.control.Unlock()
+
+	if k.resumeLocked() {
+		k.log.Info("Broker subscriptions resumed")
+	}
+}
+
+// resumeLocked resumes paused subscriptions. k.control must be held.
+// It returns false if subscriptions are not paused
+func (k *Kafka) resumeLocked() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.resume == nil {
+		return false
+	}
+	close(k.resume)
+	k.resume = nil
+	k.updateState()
+	return true
+}
+
+// Drain pauses subscriptions and waits for their in-flight handlers to exit
+func (k *Kafka) Drain(ctx context.Context) error {
+	if err := k.Pause(ctx); err != nil {
+		return err
+	}
+	if err := k.waitIdle(ctx); err != nil {
+		return err
+	}
+	k.log.Info("Broker subscriptions drained")
+	return nil
+}
+
+// waitIdle waits until there are no running consume calls
+func (k *Kafka) waitIdle(ctx context.Context) error {
+	k.mu.Lock()
+	idle := k.idle
+	k.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// State returns consumer state
+func (k *Kafka) State() broker.State {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.state()
+}
+
+// state returns consumer state. k.mu must be held
+func (k *Kafka) state() broker.State {
+	switch {
+	case k.resume == nil:
+		return broker.StateRunning
+	case k.consuming > 0:
+		return broker.StateDraining
+	default:
+		return broker.StatePaused
+	}
+}
+
+// updateState updates consumer state metric. k.mu must be held
+func (k *Kafka) updateState() {
+	current := k.state()
+	for _, state := range []broker.State{broker.StateRunning, broker.StateDraining, broker.StatePaused} {
+		value := 0.0
+		if state == current {
+			value = 1
+		}
+		consumerState.WithLabelValues(string(state)).Set(value)
+	}
+}
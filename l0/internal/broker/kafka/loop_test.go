@@ -0,0 +1,176 @@
+package kafka
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"wb-tech-l0/internal/broker"
+	noplogger "wb-tech-l0/internal/logger/nop"
+)
+
+// newTestKafka returns Kafka without connections for loops tests
+func newTestKafka(ctx context.Context) *Kafka {
+	k := &Kafka{loops: make(map[broker.Stream]*loop), idle: make(chan struct{}), rereadUntil: make(map[string]map[int]int64), ctx: ctx, log: noplogger.New()}
+	close(k.idle)
+	return k
+}
+
+func TestWhileStopped(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := newTestKafka(ctx)
+
+	// consuming counts running consume calls of both loops
+	var consuming, starts atomic.Int32
+	consume := func(ctx context.Context) {
+		consuming.Add(1)
+		starts.Add(1)
+		<-ctx.Done()
+		consuming.Add(-1)
+	}
+	exited := make(chan struct{}, 2)
+	for _, stream := range []broker.Stream{broker.StreamOrders, broker.StreamStatuses} {
+		go func() {
+			k.runLoop(stream, consume)
+			exited <- struct{}{}
+		}()
+	}
+
+	waitFor := func(cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatal("condition is not met in time")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFor(func() bool {
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		return len(k.loops) == 2 && consuming.Load() == 2
+	})
+
+	err := k.whileStopped(context.Background(), func(context.Context) error {
+		if n := consuming.Load(); n != 0 {
+			t.Errorf("action is called with %d running loops", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("whileStopped() error = %v", err)
+	}
+
+	// loops are resumed with new consume calls
+	waitFor(func() bool { return consuming.Load() == 2 && starts.Load() == 4 })
+
+	cancel()
+	<-exited
+	<-exited
+
+	// action is called immediately without running loops
+	called := false
+	if err := k.whileStopped(context.Background(), func(context.Context) error {
+		called = true
+		return nil
+	}); err != nil || !called {
+		t.Fatalf("whileStopped() without loops error = %v, called = %v", err, called)
+	}
+}
+
+func TestPauseResumeDrain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	k := newTestKafka(ctx)
+
+	// consume simulates in-flight handler finishing after release
+	release := make(chan struct{})
+	var starts atomic.Int32
+	consume := func(ctx context.Context) {
+		starts.Add(1)
+		<-ctx.Done()
+		<-release
+	}
+	go k.runLoop(broker.StreamOrders, consume)
+
+	waitFor := func(cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatal("condition is not met in time")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitFor(func() bool { return starts.Load() == 1 })
+	if state := k.State(); state != broker.StateRunning {
+		t.Fatalf("State() = %q, want %q", state, broker.StateRunning)
+	}
+
+	// handler is still running, so drain times out
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer drainCancel()
+	if err := k.Drain(drainCtx); err == nil {
+		t.Fatal("Drain() with running handler error = nil")
+	}
+	if state := k.State(); state != broker.StateDraining {
+		t.Fatalf("State() = %q, want %q", state, broker.StateDraining)
+	}
+	if got := testutil.ToFloat64(consumerState.WithLabelValues(string(broker.StateDraining))); got != 1 {
+		t.Fatalf("draining state metric = %v, want 1", got)
+	}
+
+	close(release)
+	if err := k.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+	if state := k.State(); state != broker.StatePaused {
+		t.Fatalf("State() = %q, want %q", state, broker.StatePaused)
+	}
+	// pausing paused broker is no-op
+	if err := k.Pause(context.Background()); err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+
+	k.Resume()
+	waitFor(func() bool { return starts.Load() == 2 })
+	if state := k.State(); state != broker.StateRunning {
+		t.Fatalf("State() = %q, want %q", state, broker.StateRunning)
+	}
+}
+
+func TestReread(t *testing.T) {
+	k := newTestKafka(context.Background())
+	k.markReread("orders", map[int]int64{0: 10, 1: 5})
+	k.markReread("orders", map[int]int64{1: 7})
+
+	tests := []struct {
+		name      string
+		topic     string
+		partition int
+		offset    int64
+		want      bool
+	}{
+		{name: "before end", topic: "orders", partition: 0, offset: 9, want: true},
+		{name: "at end", topic: "orders", partition: 0, offset: 10},
+		{name: "last reset end", topic: "orders", partition: 1, offset: 6, want: true},
+		{name: "unknown partition", topic: "orders", partition: 2, offset: 0},
+		{name: "not reset topic", topic: "statuses", partition: 0, offset: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := k.reread(tt.topic, tt.partition, tt.offset); got != tt.want {
+				t.Errorf("reread() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+
+	"wb-tech-l0/internal/broker"
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/models"
+)
+
+// UseOffsetStore sets store of consumer positions.
+// It is used only if ExternalOffsets is turned on
+func (k *Kafka) UseOffsetStore(store broker.OffsetStore) {
+	k.offsets = store
+}
+
+// consumeExternal is a orders subscription loop for offsets kept in external store.
+// It joins consumer group and on every partitions assignment resumes assigned
+// partitions from stored offsets (or group start offset if nothing is stored).
+// Every partition is handled sequentially by own reader. Failed messages are
+// retried until success, so stored offset never skips unhandled message.
+// It returns when ctx is done, closing consumer group stops partitions readers
+func (k *Kafka) consumeExternal(ctx context.Context, handler func(message *broker.Message) error) {
+	log := k.log.With(logger.Field("group_id", k.cfg.GroupID), logger.Field("topic", k.cfg.Topic))
+
+	if k.offsets == nil {
+		log.Error("External offsets are turned on, but offset store is not set")
+		return
+	}
+
+	log.Debug("Starting broker subscription loop with external offsets")
+	defer log.Debug("Broker subscription loop exited")
+
+	group, err := kafkago.NewConsumerGroup(kafkago.ConsumerGroupConfig{
+		ID:          k.cfg.GroupID,
+		Brokers:     k.cfg.Brokers,
+		Topics:      []string{k.cfg.Topic},
+		StartOffset: k.cfg.StartOffset,
+	})
+	if err != nil {
+		log.Error("Could not create consumer group", logger.Error(err))
+		return
+	}
+	defer func() {
+		if err := group.Close(); err != nil {
+			log.Warn("Failed to close consumer group", logger.Error(err))
+		}
+	}()
+
+	for {
+		// waiting for next generation (partitions assignment).
+		// previous generation readers are stopped before it
+		gen, err := group.Next(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, kafkago.ErrGroupClosed) {
+				return
+			}
+			log.Warn("Error joining consumer group", logger.Error(err))
+			if !k.wait(ctx) {
+				return
+			}
+			continue
+		}
+
+		stored, ok := k.storedOffsets(ctx, log)
+		if !ok {
+			return
+		}
+
+		assignments := gen.Assignments[k.cfg.Topic]
+		log.Info("Partitions assigned", logger.Field("generation_id", gen.ID), logger.Field("partitions", len(assignments)))
+
+		for _, assignment := range assignments {
+			// stored offset has priority over group committed offset
+			offset := assignment.Offset
+			if o, ok := stored[assignment.ID]; ok {
+				offset = o
+			}
+			partition := assignment.ID
+			gen.Start(func(ctx context.Context) {
+				k.consumePartition(ctx, partition, offset, handler)
+			})
+		}
+	}
+}
+
+// storedOffsets loads stored offsets retrying until success.
+// It returns false if ctx is done
+func (k *Kafka) storedOffsets(ctx context.Context, log logger.Logger) (map[int]int64, bool) {
+	for {
+		stored, err := k.offsets.Offsets(ctx, k.cfg.GroupID, k.cfg.Topic)
+		if err == nil {
+			return stored, true
+		}
+		log.Warn("Failed to load stored offsets", logger.Error(err))
+		if !k.wait(ctx) {
+			return nil, false
+		}
+	}
+}
+
+// consumePartition reads and handles partition messages starting from offset
+// until generation ctx is done. Handler stores message position atomically with
+// its result, skipped messages positions are stored after handling
+func (k *Kafka) consumePartition(ctx context.Context, partition int, offset int64, handler func(message *broker.Message) error) {
+	log := k.log.With(logger.Field("topic", k.cfg.Topic), logger.Field("partition", partition))
+	log.Debug("Starting partition consuming", logger.Field("offset", offset))
+	defer log.Debug("Partition consuming stopped")
+
+	reader := kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers:          k.cfg.Brokers,
+		Topic:            k.cfg.Topic,
+		Partition:        partition,
+		MinBytes:         k.cfg.MinBytes,
+		MaxBytes:         k.cfg.MaxBytes,
+		ReadBatchTimeout: k.cfg.ReadTimeOut,
+		MaxAttempts:      k.cfg.MaxRetries,
+	})
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Warn("Failed to close partition reader", logger.Error(err))
+		}
+	}()
+
+	// offset can be absolute or FirstOffset/LastOffset
+	if err := reader.SetOffset(offset); err != nil {
+		log.Error("Could not set partition offset", logger.Error(err))
+		return
+	}
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warn("Error fetching broker message", logger.Error(err))
+			if !k.wait(ctx) {
+				return
+			}
+			continue
+		}
+
+		message := newMessage(msg)
+		message.Position = &models.Position{
+			Group:     k.cfg.GroupID,
+			Topic:     msg.Topic,
+			Partition: msg.Partition,
+			Offset:    msg.Offset + 1,
+		}
+		log := log.With(logger.Field("message_key", string(msg.Key)), logger.Field("offset", msg.Offset))
+
+		// retrying message until success, next messages can't be handled before it
+		for {
+			err := handler(message)
+			if err == nil {
+				break
+			}
+			log.Debug("Message handler returned error. Retrying message", logger.Error(err))
+			if !k.wait(ctx) {
+				return
+			}
+		}
+
+		// storing position of skipped messages. it is no-op
+		// if handler already stored it with handling result
+		if err := k.offsets.CommitOffset(ctx, *message.Position); err != nil {
+			// message will be handled again after restart or rebalance
+			log.Warn("Failed to store offset", logger.Error(err))
+		}
+	}
+}
+
+// wait waits retry timeout. It returns false if ctx is done before
+func (k *Kafka) wait(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(k.retryTimeout):
+		return true
+	}
+}
@@ -0,0 +1,84 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+
+	kafkago "github.com/segmentio/kafka-go"
+
+	"wb-tech-l0/internal/broker"
+	"wb-tech-l0/internal/logger"
+)
+
+// Producer is a broker.Producer interface implementation for Kafka
+type Producer struct {
+	writer *kafkago.Writer
+	// topics maps published streams to their topics
+	topics map[broker.Stream]string
+
+	log logger.Logger
+}
+
+// NewProducer creates and returns initialized Kafka implementation of broker.Producer interface.
+// Events are published to cfg.ProducerTopic and dead letters to cfg.DeadLetterTopic.
+// Messages are partitioned by key, so events of one order keep their order
+func NewProducer(cfg *Config, log logger.Logger) (*Producer, error) {
+	log.Debug("Creating producer connection")
+
+	writer := &kafkago.Writer{
+		Addr:            kafkago.TCP(cfg.Brokers...),
+		Balancer:        &kafkago.Hash{},
+		MaxAttempts:     cfg.MaxRetries,
+		WriteBackoffMin: cfg.RetryTimeOut,
+		WriteBackoffMax: cfg.RetryTimeOut,
+		WriteTimeout:    cfg.WriteTimeOut,
+		// waiting for all in-sync replicas, published message must not be lost
+		RequiredAcks:           kafkago.RequireAll,
+		AllowAutoTopicCreation: true,
+	}
+
+	return &Producer{
+		writer: writer,
+		topics: map[broker.Stream]string{
+			broker.StreamEvents:      cfg.ProducerTopic,
+			broker.StreamDeadLetters: cfg.DeadLetterTopic,
+		},
+		log: log,
+	}, nil
+}
+
+// Close flushes pending messages and closes the Kafka producer connection
+func (p *Producer) Close() error {
+	return p.writer.Close()
+}
+
+// Publish writes messages to stream topic synchronously. Writer retries
+// failed writes itself up to MaxRetries times
+func (p *Producer) Publish(ctx context.Context, stream broker.Stream, messages ...*broker.Message) error {
+	topic, ok := p.topics[stream]
+	if !ok {
+		return fmt.Errorf("unknown producer stream %q", stream)
+	}
+
+	msgs := make([]kafkago.Message, 0, len(messages))
+	for _, m := range messages {
+		headers := make([]kafkago.Header, 0, len(m.Headers))
+		for k, v := range m.Headers {
+			headers = append(headers, kafkago.Header{Key: k, Value: v})
+		}
+		msgs = append(msgs, kafkago.Message{
+			Topic:   topic,
+			Key:     m.Key,
+			Value:   m.Value,
+			Time:    m.Timestamp,
+			Headers: headers,
+		})
+	}
+
+	if err := p.writer.WriteMessages(ctx, msgs...); err != nil {
+		return fmt.Errorf("failed to write messages: %w", err)
+	}
+
+	p.log.Debug("Messages published", logger.Field("topic", topic), logger.Field("count", len(msgs)))
+	return nil
+}
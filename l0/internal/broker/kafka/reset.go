@@ -0,0 +1,337 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"golang.org/x/sync/errgroup"
+
+	"wb-tech-l0/internal/broker"
+	"wb-tech-l0/internal/logger"
+)
+
+// client returns Kafka client for offsets tools requests
+func (k *Kafka) client() *kafkago.Client {
+	return &kafkago.Client{Addr: kafkago.TCP(k.cfg.Brokers...), Timeout: k.cfg.WriteTimeOut}
+}
+
+// ResetOffsets moves KAFKA_GROUP_ID offsets of stream topic partitions to target.
+// All subscriptions of the group are stopped, so group has no members of this
+// application while offsets are committed. Orders offsets are reset in offset
+// store if they are external
+func (k *Kafka) ResetOffsets(ctx context.Context, stream broker.Stream, target broker.OffsetTarget) (map[int]int64, error) {
+	topic, ok := k.topic(stream)
+	if !ok {
+		return nil, fmt.Errorf("unknown broker stream %q", stream)
+	}
+	log := k.log.With(logger.Field("group_id", k.cfg.GroupID), logger.Field("topic", topic))
+
+	client := k.client()
+	offsets, err := k.resolve(ctx, client, topic, target)
+	if err != nil {
+		return nil, err
+	}
+	// messages before current ends were consumed already
+	ends, err := k.listOffsets(ctx, client, topic, slices.Collect(maps.Keys(offsets)), kafkago.LastOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	err = k.whileStopped(ctx, func(ctx context.Context) error {
+		if err := k.checkGroupEmpty(ctx, client); err != nil {
+			return err
+		}
+
+		if stream == broker.StreamOrders && k.cfg.ExternalOffsets {
+			if k.offsets == nil {
+				return errors.New("offset store is not set")
+			}
+			return k.offsets.ResetOffsets(ctx, k.cfg.GroupID, topic, offsets)
+		}
+		return k.commitOffsets(ctx, client, topic, offsets)
+	})
+	if err != nil {
+		log.Warn("Failed to reset consumer group offsets", logger.Error(err))
+		return nil, err
+	}
+
+	k.markReread(topic, ends)
+
+	log.Info("Consumer group offsets reset", logger.Field("offsets", offsets))
+	return offsets, nil
+}
+
+// markReread marks messages of topic partitions before ends as read again
+func (k *Kafka) markReread(topic string, ends map[int]int64) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.rereadUntil[topic] == nil {
+		k.rereadUntil[topic] = make(map[int]int64, len(ends))
+	}
+	maps.Copy(k.rereadUntil[topic], ends)
+}
+
+// reread reports whether message at offset of topic partition is read
+// again after offsets reset
+func (k *Kafka) reread(topic string, partition int, offset int64) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	end, ok := k.rereadUntil[topic][partition]
+	return ok && offset < end
+}
+
+// checkGroupEmpty returns broker.ErrGroupActive if consumer group has members
+func (k *Kafka) checkGroupEmpty(ctx context.Context, client *kafkago.Client) error {
+	resp, err := client.DescribeGroups(ctx, &kafkago.DescribeGroupsRequest{GroupIDs: []string{k.cfg.GroupID}})
+	if err != nil {
+		return fmt.Errorf("could not describe consumer group: %w", err)
+	}
+	for _, group := range resp.Groups {
+		if group.Error != nil {
+			return fmt.Errorf("could not describe consumer group: %w", group.Error)
+		}
+		if len(group.Members) > 0 {
+			return fmt.Errorf("%w: %d members", broker.ErrGroupActive, len(group.Members))
+		}
+	}
+	return nil
+}
+
+// commitOffsets commits group offsets without group generation.
+// Kafka accepts such commits only if group is empty
+func (k *Kafka) commitOffsets(ctx context.Context, client *kafkago.Client, topic string, offsets map[int]int64) error {
+	commits := make([]kafkago.OffsetCommit, 0, len(offsets))
+	for partition, offset := range offsets {
+		commits = append(commits, kafkago.OffsetCommit{Partition: partition, Offset: offset})
+	}
+
+	resp, err := client.OffsetCommit(ctx, &kafkago.OffsetCommitRequest{
+		GroupID:      k.cfg.GroupID,
+		GenerationID: -1,
+		Topics:       map[string][]kafkago.OffsetCommit{topic: commits},
+	})
+	if err != nil {
+		return fmt.Errorf("could not commit offsets: %w", err)
+	}
+
+	var errs []error
+	for _, partition := range resp.Topics[topic] {
+		switch {
+		case partition.Error == nil:
+		case errors.Is(partition.Error, kafkago.UnknownMemberId),
+			errors.Is(partition.Error, kafkago.IllegalGeneration),
+			errors.Is(partition.Error, kafkago.RebalanceInProgress):
+			// group was joined by another instance after check
+			return broker.ErrGroupActive
+		default:
+			errs = append(errs, fmt.Errorf("partition %d: %w", partition.Partition, partition.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// resolve returns next offsets of target in topic partitions.
+// Explicit offsets are checked to be in partitions ranges,
+// zero target selects partitions ends
+func (k *Kafka) resolve(ctx context.Context, client *kafkago.Client, topic string, target broker.OffsetTarget) (map[int]int64, error) {
+	partitions, err := k.partitions(ctx, client, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	first, err := k.listOffsets(ctx, client, topic, partitions, kafkago.FirstOffset)
+	if err != nil {
+		return nil, err
+	}
+	last, err := k.listOffsets(ctx, client, topic, partitions, kafkago.LastOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	if target.IsZero() {
+		return last, nil
+	}
+	if len(target.Offsets) > 0 {
+		for partition, offset := range target.Offsets {
+			if !slices.Contains(partitions, partition) {
+				return nil, fmt.Errorf("%w: %d", broker.ErrUnknownPartition, partition)
+			}
+			if offset < first[partition] || offset > last[partition] {
+				return nil, fmt.Errorf("%w: partition %d offset %d is out of range [%d, %d]",
+					broker.ErrOffsetOutOfRange, partition, offset, first[partition], last[partition])
+			}
+		}
+		return maps.Clone(target.Offsets), nil
+	}
+
+	offsets, err := k.listOffsets(ctx, client, topic, partitions, target.Timestamp.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	for partition, offset := range offsets {
+		// there is no message at or after timestamp
+		if offset < 0 {
+			offsets[partition] = last[partition]
+		}
+	}
+	return offsets, nil
+}
+
+// partitions returns IDs of topic partitions
+func (k *Kafka) partitions(ctx context.Context, client *kafkago.Client, topic string) ([]int, error) {
+	resp, err := client.Metadata(ctx, &kafkago.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, fmt.Errorf("could not get topic metadata: %w", err)
+	}
+	for _, t := range resp.Topics {
+		if t.Name != topic {
+			continue
+		}
+		if t.Error != nil {
+			return nil, fmt.Errorf("could not get topic metadata: %w", t.Error)
+		}
+		partitions := make([]int, 0, len(t.Partitions))
+		for _, p := range t.Partitions {
+			partitions = append(partitions, p.ID)
+		}
+		return partitions, nil
+	}
+	return nil, fmt.Errorf("topic %q not found", topic)
+}
+
+// listOffsets returns offsets of partitions at timestamp. Timestamp
+// can be kafkago.FirstOffset or kafkago.LastOffset for partitions ranges
+func (k *Kafka) listOffsets(ctx context.Context, client *kafkago.Client, topic string, partitions []int, timestamp int64) (map[int]int64, error) {
+	requests := make([]kafkago.OffsetRequest, 0, len(partitions))
+	for _, partition := range partitions {
+		requests = append(requests, kafkago.OffsetRequest{Partition: partition, Timestamp: timestamp})
+	}
+
+	resp, err := client.ListOffsets(ctx, &kafkago.ListOffsetsRequest{Topics: map[string][]kafkago.OffsetRequest{topic: requests}})
+	if err != nil {
+		return nil, fmt.Errorf("could not list offsets: %w", err)
+	}
+
+	offsets := make(map[int]int64, len(partitions))
+	for _, p := range resp.Topics[topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("could not list partition %d offsets: %w", p.Partition, p.Error)
+		}
+		switch timestamp {
+		case kafkago.FirstOffset:
+			offsets[p.Partition] = p.FirstOffset
+		case kafkago.LastOffset:
+			offsets[p.Partition] = p.LastOffset
+		default:
+			// response has single offset of timestamp, -1 if there is no such message
+			offsets[p.Partition] = -1
+			for offset := range p.Offsets {
+				offsets[p.Partition] = offset
+			}
+		}
+	}
+	return offsets, nil
+}
+
+// Replay reads stream topic range by partition readers without consumer group.
+// Partitions are read concurrently, messages of partition are handled sequentially
+// and retried until success, so range is handled completely unless ctx is done
+func (k *Kafka) Replay(ctx context.Context, stream broker.Stream, from, to broker.OffsetTarget, handler func(message *broker.Message) error) error {
+	topic, ok := k.topic(stream)
+	if !ok {
+		return fmt.Errorf("unknown broker stream %q", stream)
+	}
+
+	client := k.client()
+	start, err := k.resolve(ctx, client, topic, from)
+	if err != nil {
+		return fmt.Errorf("invalid replay start: %w", err)
+	}
+	// zero end target selects partitions ends at replay start
+	stop, err := k.resolve(ctx, client, topic, to)
+	if err != nil {
+		return fmt.Errorf("invalid replay end: %w", err)
+	}
+
+	log := k.log.With(logger.Field("topic", topic), logger.Field("stream", stream))
+	log.Info("Starting replay", logger.Field("from", start), logger.Field("to", stop))
+
+	g, ctx := errgroup.WithContext(ctx)
+	for partition, offset := range start {
+		endOffset, ok := stop[partition]
+		if !ok || offset >= endOffset {
+			continue
+		}
+		g.Go(func() error {
+			return k.replayPartition(ctx, log.With(logger.Field("partition", partition)), topic, partition, offset, endOffset, handler)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	log.Info("Replay finished")
+	return nil
+}
+
+// replayPartition reads and handles partition messages in [offset, end)
+func (k *Kafka) replayPartition(ctx context.Context, log logger.Logger, topic string, partition int, offset, end int64, handler func(message *broker.Message) error) error {
+	reader := kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers:          k.cfg.Brokers,
+		Topic:            topic,
+		Partition:        partition,
+		MinBytes:         k.cfg.MinBytes,
+		MaxBytes:         k.cfg.MaxBytes,
+		ReadBatchTimeout: k.cfg.ReadTimeOut,
+		MaxAttempts:      k.cfg.MaxRetries,
+	})
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Debug("Failed to close replay reader", logger.Error(err))
+		}
+	}()
+
+	if err := reader.SetOffset(offset); err != nil {
+		return fmt.Errorf("could not set partition %d offset: %w", partition, err)
+	}
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Warn("Error fetching replayed message", logger.Error(err))
+			if !k.wait(ctx) {
+				return ctx.Err()
+			}
+			continue
+		}
+		if msg.Offset >= end {
+			return nil
+		}
+
+		message := newMessage(msg)
+		message.Reread = true
+		for {
+			err := handler(message)
+			if err == nil {
+				break
+			}
+			log.Debug("Message handler returned error. Retrying replayed message", logger.Field("offset", msg.Offset), logger.Error(err))
+			if !k.wait(ctx) {
+				return ctx.Err()
+			}
+		}
+
+		if msg.Offset+1 >= end {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package broker
+
+// Handler handles consumed message. It must return error
+// to NOT commit message and nil to commit or skip it
+type Handler func(message *Message) error
+
+// Middleware wraps Handler with common message handling logic,
+// the same way as HTTP middlewares wrap http.Handler
+type Middleware func(next Handler) Handler
+
+// Chain wraps handler with middlewares. The first middleware
+// is the outermost one and sees message first
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
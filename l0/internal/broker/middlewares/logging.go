@@ -0,0 +1,40 @@
+package brokermiddlewares
+
+import (
+	"time"
+
+	"wb-tech-l0/internal/broker"
+	"wb-tech-l0/internal/logger"
+)
+
+// Logging logs received messages and handling results with duration.
+// Handler errors are logged as warnings, message values are logged on debug level.
+// name is a handler name added to logs
+func Logging(log logger.Logger, name string) broker.Middleware {
+	return func(next broker.Handler) broker.Handler {
+		return func(message *broker.Message) error {
+			log := log.With(
+				logger.Field("handler", name),
+				logger.Field("message_key", string(message.Key)),
+				logger.Field("topic", message.Topic),
+				logger.Field("partition", message.Partition),
+				logger.Field("offset", message.Offset),
+			)
+			if trace, ok := GetTrace(message.Context()); ok {
+				log = log.With(logger.Field("trace_id", trace.TraceID), logger.Field("span_id", trace.SpanID))
+			}
+			log.Debug("Message received", logger.Field("message_value", string(message.Value)))
+
+			start := time.Now()
+			err := next(message)
+			duration := time.Since(start)
+
+			if err != nil {
+				log.Warn("Message handler returned error", logger.Field("duration", duration), logger.Error(err))
+				return err
+			}
+			log.Debug("Message handled", logger.Field("duration", duration))
+			return nil
+		}
+	}
+}
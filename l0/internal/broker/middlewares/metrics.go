@@ -0,0 +1,61 @@
+package brokermiddlewares
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"wb-tech-l0/internal/broker"
+)
+
+// Handling results of broker_messages_handled_total
+const (
+	resultOK    = "ok"
+	resultError = "error"
+	resultPanic = "panic"
+)
+
+var (
+	handledMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "broker_messages_handled_total",
+		Help: "Messages handled by broker handlers by result: ok, error or panic.",
+	}, []string{"handler", "result"})
+	handlingSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "broker_messages_handling_seconds",
+		Help:    "Duration of messages handling in seconds.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"handler"})
+	inFlightMessages = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "broker_messages_in_flight",
+		Help: "Messages being handled by broker handlers.",
+	}, []string{"handler"})
+)
+
+// Metrics counts handled messages by result, observes handling duration and
+// tracks in-flight messages. name is a handler name of metrics labels.
+// It must be placed before Recovery to count panics
+func Metrics(name string) broker.Middleware {
+	return func(next broker.Handler) broker.Handler {
+		return func(message *broker.Message) error {
+			inFlight := inFlightMessages.WithLabelValues(name)
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			start := time.Now()
+			err := next(message)
+			handlingSeconds.WithLabelValues(name).Observe(time.Since(start).Seconds())
+
+			switch {
+			case err == nil:
+				handledMessages.WithLabelValues(name, resultOK).Inc()
+			case errors.Is(err, ErrPanic):
+				handledMessages.WithLabelValues(name, resultPanic).Inc()
+			default:
+				handledMessages.WithLabelValues(name, resultError).Inc()
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package brokermiddlewares
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"wb-tech-l0/internal/broker"
+	noplogger "wb-tech-l0/internal/logger/nop"
+)
+
+func TestChain(t *testing.T) {
+	var calls []string
+	middleware := func(name string) broker.Middleware {
+		return func(next broker.Handler) broker.Handler {
+			return func(message *broker.Message) error {
+				calls = append(calls, name)
+				return next(message)
+			}
+		}
+	}
+
+	handler := broker.Chain(func(*broker.Message) error {
+		calls = append(calls, "handler")
+		return nil
+	}, middleware("first"), middleware("second"))
+
+	if err := handler(&broker.Message{}); err != nil {
+		t.Fatalf("handler() error = %v", err)
+	}
+	want := []string{"first", "second", "handler"}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Fatalf("calls = %v, want %v", calls, want)
+		}
+	}
+}
+
+func TestRecoveryAndMetrics(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    broker.Handler
+		wantPanic  bool
+		wantResult string
+	}{
+		{
+			name:       "Handled",
+			handler:    func(*broker.Message) error { return nil },
+			wantResult: resultOK,
+		},
+		{
+			name:       "Failed",
+			handler:    func(*broker.Message) error { return errors.New("storage is down") },
+			wantResult: resultError,
+		},
+		{
+			name:       "Panicked",
+			handler:    func(*broker.Message) error { panic("nil order") },
+			wantPanic:  true,
+			wantResult: resultPanic,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := "test_" + tt.name
+			handler := broker.Chain(tt.handler, Metrics(name), Recovery(noplogger.New()))
+			histograms := testutil.CollectAndCount(handlingSeconds)
+
+			err := handler(&broker.Message{})
+			if got := errors.Is(err, ErrPanic); got != tt.wantPanic {
+				t.Errorf("handler() error = %v, want panic %v", err, tt.wantPanic)
+			}
+			if got := testutil.ToFloat64(handledMessages.WithLabelValues(name, tt.wantResult)); got != 1 {
+				t.Errorf("handled messages with result %s = %v, want 1", tt.wantResult, got)
+			}
+			if got := testutil.ToFloat64(inFlightMessages.WithLabelValues(name)); got != 0 {
+				t.Errorf("in-flight messages = %v, want 0", got)
+			}
+			// every handler has own duration histogram
+			if got := testutil.CollectAndCount(handlingSeconds); got != histograms+1 {
+				t.Errorf("duration histograms = %d, want %d", got, histograms+1)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	handler := broker.Chain(func(message *broker.Message) error {
+		<-message.Context().Done()
+		return message.Context().Err()
+	}, Timeout(10*time.Millisecond))
+
+	err := handler(&broker.Message{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("handler() error = %v, want deadline exceeded", err)
+	}
+
+	// no timeout keeps background context
+	handler = Timeout(0)(func(message *broker.Message) error {
+		if _, ok := message.Context().Deadline(); ok {
+			return errors.New("unexpected deadline")
+		}
+		return nil
+	})
+	if err := handler(&broker.Message{}); err != nil {
+		t.Errorf("handler() error = %v", err)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	const (
+		traceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentID = "00f067aa0ba902b7"
+	)
+
+	tests := []struct {
+		name         string
+		traceParent  string
+		wantContinue bool
+	}{
+		{name: "Continued", traceParent: "00-" + traceID + "-" + parentID + "-01", wantContinue: true},
+		{name: "Missing"},
+		{name: "Invalid trace ID", traceParent: "00-" + traceID[:31] + "-" + parentID + "-01"},
+		{name: "Zero trace ID", traceParent: "00-00000000000000000000000000000000-" + parentID + "-01"},
+		{name: "Upper case", traceParent: "00-4BF92F3577B34DA6A3CE929D0E0E4736-" + parentID + "-01"},
+		{name: "Invalid version", traceParent: "ff-" + traceID + "-" + parentID + "-01"},
+		{name: "Future version", traceParent: "01-" + traceID + "-" + parentID + "-01-extra", wantContinue: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var trace Trace
+			handler := Tracing()(func(message *broker.Message) error {
+				var ok bool
+				trace, ok = GetTrace(message.Context())
+				if !ok {
+					return errors.New("no trace in context")
+				}
+				return nil
+			})
+
+			message := &broker.Message{Headers: map[string][]byte{}}
+			if tt.traceParent != "" {
+				message.Headers[HeaderTraceParent] = []byte(tt.traceParent)
+			}
+			if err := handler(message); err != nil {
+				t.Fatalf("handler() error = %v", err)
+			}
+
+			if got := trace.TraceID == traceID && trace.ParentID == parentID; got != tt.wantContinue {
+				t.Errorf("trace = %+v, want continued %v", trace, tt.wantContinue)
+			}
+			if _, _, ok := parseTraceParent(trace.TraceParent()); !ok {
+				t.Errorf("TraceParent() = %q is invalid", trace.TraceParent())
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package brokermiddlewares
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"wb-tech-l0/internal/broker"
+	"wb-tech-l0/internal/logger"
+)
+
+// ErrPanic is wrapped by errors returned for recovered handler panics
+var ErrPanic = errors.New("message handler panicked")
+
+// Recovery recovers panics in next handlers, so one bad message
+// doesn't crash the whole process from handler goroutine.
+// It logs panic value and stack and returns error wrapping ErrPanic,
+// so message is NOT committed and handled again by broker retries
+func Recovery(log logger.Logger) broker.Middleware {
+	return func(next broker.Handler) broker.Handler {
+		return func(message *broker.Message) (err error) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+
+				log.Error("Recovered panic in message handler",
+					logger.Field("message_key", string(message.Key)),
+					logger.Field("topic", message.Topic),
+					logger.Field("partition", message.Partition),
+					logger.Field("offset", message.Offset),
+					logger.Field("panic", fmt.Sprint(rec)),
+					logger.Field("stack", string(debug.Stack())),
+				)
+				err = fmt.Errorf("%w: %v", ErrPanic, rec)
+			}()
+
+			return next(message)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package brokermiddlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"wb-tech-l0/internal/broker"
+)
+
+// Timeout sets deadline of message handling context, so context-aware calls
+// of next handlers (storage, publishing) are canceled when it is exceeded.
+// Handler is not abandoned while it runs, so broker keeps messages order.
+// Handler errors caused by exceeded deadline are wrapped with the timeout.
+// 0 timeout means no limit
+func Timeout(timeout time.Duration) broker.Middleware {
+	return func(next broker.Handler) broker.Handler {
+		if timeout <= 0 {
+			return next
+		}
+		return func(message *broker.Message) error {
+			ctx, cancel := context.WithTimeout(message.Context(), timeout)
+			defer cancel()
+
+			err := next(message.WithContext(ctx))
+			if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("message handling timed out after %s: %w", timeout, err)
+			}
+			return err
+		}
+	}
+}
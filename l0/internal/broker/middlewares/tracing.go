@@ -0,0 +1,102 @@
+package brokermiddlewares
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"wb-tech-l0/internal/broker"
+)
+
+// HeaderTraceParent is a W3C Trace Context header of messages
+const HeaderTraceParent = "traceparent"
+
+// Trace identifies message handling span in distributed trace
+type Trace struct {
+	// TraceID is a 32 hex digits trace identifier
+	TraceID string
+	// SpanID is a 16 hex digits identifier of handling span
+	SpanID string
+	// ParentID is a span identifier of producer, it is empty for new traces
+	ParentID string
+}
+
+// TraceParent returns W3C traceparent header value of handling span,
+// it must be set to messages published and events saved while handling to continue trace
+func (t Trace) TraceParent() string {
+	return "00-" + t.TraceID + "-" + t.SpanID + "-01"
+}
+
+// traceKey is a context key of Trace
+type traceKey struct{}
+
+// GetTrace returns Trace of message handling context
+func GetTrace(ctx context.Context) (Trace, bool) {
+	trace, ok := ctx.Value(traceKey{}).(Trace)
+	return trace, ok
+}
+
+// Tracing starts handling span of message. Trace is continued from message
+// traceparent header or started if it is missing or invalid.
+// Trace is set to message handling context and can be read with GetTrace.
+// It must be placed before Logging to have trace in logs
+func Tracing() broker.Middleware {
+	return func(next broker.Handler) broker.Handler {
+		return func(message *broker.Message) error {
+			trace := Trace{SpanID: randomHex(8)}
+			if traceID, parentID, ok := parseTraceParent(string(message.Headers[HeaderTraceParent])); ok {
+				trace.TraceID, trace.ParentID = traceID, parentID
+			} else {
+				trace.TraceID = randomHex(16)
+			}
+
+			return next(message.WithContext(context.WithValue(message.Context(), traceKey{}, trace)))
+		}
+	}
+}
+
+// parseTraceParent returns trace and parent span IDs of traceparent header value
+// (version-traceid-parentid-flags). All zeros IDs are invalid
+func parseTraceParent(value string) (string, string, bool) {
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return "", "", false
+	}
+	// version 00 has exactly 4 parts, future versions can append fields
+	if parts[0] == "00" && len(parts) != 4 {
+		return "", "", false
+	}
+	traceID, parentID := parts[1], parts[2]
+	if !validID(traceID, 32) || !validID(parentID, 16) || !validID(parts[3], 2) {
+		return "", "", false
+	}
+	return traceID, parentID, true
+}
+
+// validID checks that id is n lower case hex digits and not all zeros
+func validID(id string, n int) bool {
+	if len(id) != n {
+		return false
+	}
+	zeros := true
+	for _, c := range id {
+		switch {
+		case c == '0':
+		case '1' <= c && c <= '9', 'a' <= c && c <= 'f':
+			zeros = false
+		default:
+			return false
+		}
+	}
+	// flags can be zero
+	return !zeros || n == 2
+}
+
+// randomHex returns n random bytes in hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	// crypto/rand Read never returns error
+	rand.Read(b) // nolint: errcheck
+	return hex.EncodeToString(b)
+}
@@ -8,4 +8,6 @@ type Cache interface {
 	GetOrder(key string) (interface{}, bool)
 	// SaveOrder saves order to cache
 	SaveOrder(key string, value interface{})
+	// DeleteOrder deletes order from cache if exists
+	DeleteOrder(key string)
 }
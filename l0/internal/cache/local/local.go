@@ -98,6 +98,15 @@ func (l *Local) SaveOrder(key string, value interface{}) {
 	}
 }
 
+// DeleteOrder deletes order from cache if exists
+func (l *Local) DeleteOrder(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.log.Debug("Attempting to delete order", logger.Field("key", key))
+	delete(l.items, key)
+}
+
 // cleanExpired removes all expired items from cache
 func (l *Local) cleanExpired() {
 	now := time.Now()
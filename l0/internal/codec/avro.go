@@ -0,0 +1,218 @@
+package codec
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hamba/avro/v2"
+
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/schema"
+)
+
+// avroFiles are writer schemas of orders named order.v<version>.avsc.
+// Versions are the same as JSON Schema versions of orders
+//
+//go:embed schemas/*.avsc
+var avroFiles embed.FS
+
+// Avro is a codec of orders in Avro binary encoding with local writer schemas.
+// Message schema version selects writer schema, decoded message is validated
+// and upcasted with JSON Schema of the same version, so Avro and JSON orders
+// are checked the same way
+type Avro struct {
+	schemas *schema.Registry
+	writers map[int]avro.Schema
+}
+
+// NewAvro creates and returns Avro codec with embedded schemas.
+// It returns error if any schema is invalid or has no JSON Schema of its version
+func NewAvro(schemas *schema.Registry) (*Avro, error) {
+	c := &Avro{schemas: schemas, writers: make(map[int]avro.Schema)}
+
+	entries, err := fs.Glob(avroFiles, "schemas/order.v*.avsc")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range entries {
+		version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "schemas/order.v"), ".avsc"))
+		if err != nil {
+			return nil, fmt.Errorf("avro schema file name %q has invalid version", name)
+		}
+		if _, ok := schemas.Schema(schema.Order, version); !ok {
+			return nil, fmt.Errorf("avro schema %s has no JSON Schema of version %d", name, version)
+		}
+		raw, err := avroFiles.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		// schemas of every version are parsed separately, they define the same names
+		writer, err := avro.ParseBytesWithCache(raw, "", &avro.SchemaCache{})
+		if err != nil {
+			return nil, fmt.Errorf("invalid avro schema %s: %w", name, err)
+		}
+		c.writers[version] = writer
+	}
+	if len(c.writers) == 0 {
+		return nil, fmt.Errorf("no avro schemas of orders")
+	}
+
+	return c, nil
+}
+
+// ContentTypes returns Avro binary media types
+func (c *Avro) ContentTypes() []string {
+	return []string{"avro/binary", "application/avro"}
+}
+
+// Encode encodes order with the latest writer schema
+func (c *Avro) Encode(o *models.Order) ([]byte, error) {
+	// order is converted to JSON tree, so schema field names are JSON ones
+	data, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	writer := c.writers[slices.Max(slices.Collect(maps.Keys(c.writers)))]
+	value, err := avroValue(writer, doc)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert order to avro: %w", err)
+	}
+	return avro.Marshal(writer, value)
+}
+
+// Decode decodes data with writer schema of version.
+// Empty version means the oldest writer schema
+func (c *Avro) Decode(data []byte, schemaVersion string, o *models.Order) error {
+	version := slices.Min(slices.Collect(maps.Keys(c.writers)))
+	if schemaVersion != "" {
+		n, err := strconv.Atoi(schemaVersion)
+		if err != nil || c.writers[n] == nil {
+			return fmt.Errorf("%w: avro order v%s", schema.ErrUnknownVersion, schemaVersion)
+		}
+		version = n
+	}
+
+	var doc any
+	if err := avro.Unmarshal(c.writers[version], data, &doc); err != nil {
+		return fmt.Errorf("invalid avro data: %w", err)
+	}
+
+	decoded, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return c.schemas.Decode(schema.Order, strconv.Itoa(version), decoded, o)
+}
+
+// avroValue converts JSON tree value to value of Avro schema:
+// numbers to int64 or float64 and RFC 3339 timestamps to time.Time.
+// Missing record fields are encoded as null or their defaults
+func avroValue(s avro.Schema, value any) (any, error) {
+	switch s := s.(type) {
+	case *avro.RecordSchema:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%v is not %s record", value, s.Name())
+		}
+		record := make(map[string]any, len(s.Fields()))
+		for _, f := range s.Fields() {
+			v, ok := object[f.Name()]
+			if !ok && f.HasDefault() {
+				continue
+			}
+			converted, err := avroValue(f.Type(), v)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Name(), err)
+			}
+			record[f.Name()] = converted
+		}
+		return record, nil
+	case *avro.ArraySchema:
+		array, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%v is not array", value)
+		}
+		items := make([]any, 0, len(array))
+		for i, item := range array {
+			converted, err := avroValue(s.Items(), item)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			items = append(items, converted)
+		}
+		return items, nil
+	case *avro.UnionSchema:
+		// only nullable unions are used by order schemas
+		if value == nil {
+			return nil, nil
+		}
+		for _, branch := range s.Types() {
+			if branch.Type() != avro.Null {
+				return avroValue(branch, value)
+			}
+		}
+		return nil, fmt.Errorf("%v is not null", value)
+	case *avro.PrimitiveSchema:
+		return avroPrimitive(s, value)
+	default:
+		return nil, fmt.Errorf("unsupported avro type %s", s.Type())
+	}
+}
+
+// avroPrimitive converts JSON tree value to value of primitive Avro schema
+func avroPrimitive(s *avro.PrimitiveSchema, value any) (any, error) {
+	if logical := s.Logical(); logical != nil && logical.Type() == avro.TimestampMillis {
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%v is not RFC 3339 timestamp", value)
+		}
+		return time.Parse(time.RFC3339Nano, text)
+	}
+
+	switch s.Type() {
+	case avro.Long, avro.Int, avro.Double, avro.Float:
+		number, ok := value.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("%v is not %s", value, s.Type())
+		}
+		switch s.Type() {
+		case avro.Long:
+			return number.Int64()
+		case avro.Int:
+			n, err := number.Int64()
+			return int(n), err
+		case avro.Double:
+			return number.Float64()
+		default:
+			f, err := number.Float64()
+			return float32(f), err
+		}
+	case avro.String:
+		if _, ok := value.(string); !ok {
+			return nil, fmt.Errorf("%v is not string", value)
+		}
+		return value, nil
+	case avro.Boolean:
+		if _, ok := value.(bool); !ok {
+			return nil, fmt.Errorf("%v is not boolean", value)
+		}
+		return value, nil
+	default:
+		return nil, fmt.Errorf("unsupported avro type %s", s.Type())
+	}
+}
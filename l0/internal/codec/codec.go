@@ -0,0 +1,63 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+
+	"wb-tech-l0/internal/models"
+)
+
+// HeaderContentType is a message header with media type of message value.
+// Messages without it are JSON
+const HeaderContentType = "content-type"
+
+// ErrUnknownContentType is returned for content types without codec
+var ErrUnknownContentType = errors.New("unknown content type")
+
+// Codec encodes and decodes orders messages of its content types
+type Codec interface {
+	// ContentTypes returns media types of codec, canonical one first
+	ContentTypes() []string
+	// Encode encodes order in current schema version
+	Encode(order *models.Order) ([]byte, error)
+	// Decode decodes data of schema version into order.
+	// Empty version means the oldest one supported by codec
+	Decode(data []byte, schemaVersion string, order *models.Order) error
+}
+
+// Registry keeps codecs by their content types
+type Registry struct {
+	codecs map[string]Codec
+	// fallback is a codec of messages without content type
+	fallback Codec
+}
+
+// NewRegistry creates and returns Registry of codecs.
+// The first codec is used for messages without content type
+func NewRegistry(fallback Codec, codecs ...Codec) *Registry {
+	r := &Registry{codecs: make(map[string]Codec), fallback: fallback}
+	for _, c := range append([]Codec{fallback}, codecs...) {
+		for _, contentType := range c.ContentTypes() {
+			r.codecs[contentType] = c
+		}
+	}
+	return r
+}
+
+// Get returns codec of content type. Media type parameters
+// (for example, charset) are ignored. Empty content type means fallback codec
+func (r *Registry) Get(contentType string) (Codec, error) {
+	if contentType == "" {
+		return r.fallback, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownContentType, contentType)
+	}
+	c, ok := r.codecs[mediaType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownContentType, mediaType)
+	}
+	return c, nil
+}
@@ -0,0 +1,183 @@
+package codec
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/schema"
+)
+
+func intPtr(v int) *int       { return &v }
+func int64Ptr(v int64) *int64 { return &v }
+
+// newOrder returns valid order with all fields set
+func newOrder() *models.Order {
+	return &models.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction: "b563feb7b2b84b6test", Currency: "USD", Provider: "wbpay", Amount: intPtr(1817),
+			PaymentDT: int64Ptr(1637907727), Bank: "alpha", DeliveryCost: intPtr(1500), GoodsTotal: intPtr(317), CustomFee: intPtr(0),
+		},
+		Items: []models.Item{{
+			ChrtID: int64Ptr(9934930), TrackNumber: "WBILMTESTTRACK", Price: intPtr(453), RID: "ab4219087a764ae0btest",
+			Name: "Mascaras", Sale: intPtr(30), Size: "0", TotalPrice: intPtr(317), NmID: int64Ptr(2389212),
+			Brand: "Vivienne Sabo", Status: intPtr(202),
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SmID:            intPtr(99),
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+		Version:         3,
+	}
+}
+
+func newRegistry(t *testing.T) *Registry {
+	t.Helper()
+	schemas, err := schema.New()
+	if err != nil {
+		t.Fatalf("schema.New() error = %v", err)
+	}
+	avro, err := NewAvro(schemas)
+	if err != nil {
+		t.Fatalf("NewAvro() error = %v", err)
+	}
+	return NewRegistry(NewJSON(schemas), NewProtobuf(schemas), avro)
+}
+
+func TestRoundTrip(t *testing.T) {
+	registry := newRegistry(t)
+	validate := models.NewValidator()
+
+	for _, contentType := range []string{"application/json", "application/x-protobuf", "avro/binary"} {
+		t.Run(contentType, func(t *testing.T) {
+			c, err := registry.Get(contentType)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+
+			order := newOrder()
+			data, err := c.Encode(order)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			var decoded models.Order
+			if err := c.Decode(data, "2", &decoded); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(&decoded, order) {
+				t.Errorf("Decode(Encode()) = %+v, want %+v", decoded, *order)
+			}
+			if err := validate.Struct(decoded); err != nil {
+				t.Errorf("decoded order is invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestRoundTripMissingFields(t *testing.T) {
+	registry := newRegistry(t)
+
+	for _, contentType := range []string{"application/x-protobuf", "avro/binary"} {
+		t.Run(contentType, func(t *testing.T) {
+			c, err := registry.Get(contentType)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+
+			// missing required amount must stay missing instead of becoming zero
+			order := newOrder()
+			order.Payment.Amount = nil
+			data, err := c.Encode(order)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			// orders are validated with JSON Schema while decoding
+			err = c.Decode(data, "", &models.Order{})
+			var validationErr *schema.ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Decode() error = %v, want validation error", err)
+			}
+			if len(validationErr.Errors) != 1 || validationErr.Errors[0].Path != "payment.amount" {
+				t.Errorf("Decode() errors = %+v, want error of payment.amount", validationErr.Errors)
+			}
+		})
+	}
+}
+
+func TestProtobufUnknownFields(t *testing.T) {
+	c := newRegistry(t).codecs["application/x-protobuf"]
+	data, err := c.Encode(newOrder())
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	// field 100 of newer producer: varint and string
+	extended := protowire.AppendTag(slices.Clone(data), 100, protowire.VarintType)
+	extended = protowire.AppendVarint(extended, 42)
+	extended = protowire.AppendTag(extended, 101, protowire.BytesType)
+	extended = protowire.AppendString(extended, "unknown")
+
+	var decoded models.Order
+	if err := c.Decode(extended, "", &decoded); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(&decoded, newOrder()) {
+		t.Errorf("Decode() = %+v, want %+v", decoded, *newOrder())
+	}
+
+	if err := c.Decode(data[:len(data)-1], "", &models.Order{}); err == nil {
+		t.Error("Decode() of truncated message error = nil, want error")
+	}
+}
+
+func TestRegistryGet(t *testing.T) {
+	registry := newRegistry(t)
+
+	tests := []struct {
+		name        string
+		contentType string
+		want        string
+		wantErr     bool
+	}{
+		{name: "Without content type", contentType: "", want: "application/json"},
+		{name: "With parameters", contentType: "application/json; charset=utf-8", want: "application/json"},
+		{name: "Alias", contentType: "application/protobuf", want: "application/x-protobuf"},
+		{name: "Avro", contentType: "avro/binary", want: "avro/binary"},
+		{name: "Unknown", contentType: "text/xml", wantErr: true},
+		{name: "Invalid", contentType: "/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := registry.Get(tt.contentType)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnknownContentType) {
+					t.Errorf("Get() error = %v, want %v", err, ErrUnknownContentType)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got := c.ContentTypes()[0]; got != tt.want {
+				t.Errorf("Get() codec = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
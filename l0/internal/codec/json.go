@@ -0,0 +1,34 @@
+package codec
+
+import (
+	"encoding/json"
+
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/schema"
+)
+
+// JSON is a codec of JSON orders validated with versioned JSON Schemas
+type JSON struct {
+	schemas *schema.Registry
+}
+
+// NewJSON creates and returns JSON codec
+func NewJSON(schemas *schema.Registry) *JSON {
+	return &JSON{schemas: schemas}
+}
+
+// ContentTypes returns JSON media type
+func (c *JSON) ContentTypes() []string {
+	return []string{"application/json"}
+}
+
+// Encode encodes order as JSON
+func (c *JSON) Encode(order *models.Order) ([]byte, error) {
+	return json.Marshal(order)
+}
+
+// Decode validates data with order schema of its version
+// and upcasts it to current order
+func (c *JSON) Decode(data []byte, schemaVersion string, order *models.Order) error {
+	return c.schemas.Decode(schema.Order, schemaVersion, data, order)
+}
@@ -0,0 +1,173 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"wb-tech-l0/internal/codec/orderpb"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/schema"
+)
+
+//go:generate buf generate schemas
+
+// Protobuf is a codec of orders in protobuf wire format described by schemas/order.proto.
+// Messages are compatible between versions by field numbers, so decoded order is
+// validated with JSON Schema of current version, the same way as JSON and Avro orders
+type Protobuf struct {
+	schemas *schema.Registry
+}
+
+// NewProtobuf creates and returns Protobuf codec
+func NewProtobuf(schemas *schema.Registry) *Protobuf {
+	return &Protobuf{schemas: schemas}
+}
+
+// ContentTypes returns protobuf media types
+func (c *Protobuf) ContentTypes() []string {
+	return []string{"application/x-protobuf", "application/protobuf"}
+}
+
+// Encode encodes order as order.proto Order message
+func (c *Protobuf) Encode(o *models.Order) ([]byte, error) {
+	d, p := o.Delivery, o.Payment
+	message := &orderpb.Order{
+		OrderUid:    o.OrderUID,
+		TrackNumber: o.TrackNumber,
+		Entry:       o.Entry,
+		Delivery: &orderpb.Delivery{
+			Name: d.Name, Phone: d.Phone, Zip: d.Zip, City: d.City,
+			Address: d.Address, Region: d.Region, Email: d.Email,
+		},
+		Payment: &orderpb.Payment{
+			Transaction:  p.Transaction,
+			RequestId:    p.RequestID,
+			Currency:     p.Currency,
+			Provider:     p.Provider,
+			Amount:       toProtoInt(p.Amount),
+			PaymentDt:    p.PaymentDT,
+			Bank:         p.Bank,
+			DeliveryCost: toProtoInt(p.DeliveryCost),
+			GoodsTotal:   toProtoInt(p.GoodsTotal),
+			CustomFee:    toProtoInt(p.CustomFee),
+		},
+		Items:             make([]*orderpb.Item, 0, len(o.Items)),
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerId:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.ShardKey,
+		SmId:              toProtoInt(o.SmID),
+		OofShard:          o.OofShard,
+		Version:           o.Version,
+	}
+	for _, item := range o.Items {
+		message.Items = append(message.Items, &orderpb.Item{
+			ChrtId:      item.ChrtID,
+			TrackNumber: item.TrackNumber,
+			Price:       toProtoInt(item.Price),
+			Rid:         item.RID,
+			Name:        item.Name,
+			Sale:        toProtoInt(item.Sale),
+			Size:        item.Size,
+			TotalPrice:  toProtoInt(item.TotalPrice),
+			NmId:        item.NmID,
+			Brand:       item.Brand,
+			Status:      toProtoInt(item.Status),
+		})
+	}
+	if !o.DateCreated.IsZero() {
+		message.DateCreated = timestamppb.New(o.DateCreated)
+	}
+	return proto.Marshal(message)
+}
+
+// Decode decodes order.proto Order message and validates it with JSON Schema
+// of current order version. Schema version of message is not used
+func (c *Protobuf) Decode(data []byte, _ string, o *models.Order) error {
+	var message orderpb.Order
+	if err := proto.Unmarshal(data, &message); err != nil {
+		return fmt.Errorf("invalid protobuf data: %w", err)
+	}
+
+	d, p := message.GetDelivery(), message.GetPayment()
+	// optional fields are read directly, so missing payment is empty one
+	if p == nil {
+		p = &orderpb.Payment{}
+	}
+	decoded := models.Order{
+		OrderUID:    message.GetOrderUid(),
+		TrackNumber: message.GetTrackNumber(),
+		Entry:       message.GetEntry(),
+		Delivery: models.Delivery{
+			Name: d.GetName(), Phone: d.GetPhone(), Zip: d.GetZip(), City: d.GetCity(),
+			Address: d.GetAddress(), Region: d.GetRegion(), Email: d.GetEmail(),
+		},
+		Payment: models.Payment{
+			Transaction:  p.GetTransaction(),
+			RequestID:    p.GetRequestId(),
+			Currency:     p.GetCurrency(),
+			Provider:     p.GetProvider(),
+			Amount:       fromProtoInt(p.Amount),
+			PaymentDT:    p.PaymentDt,
+			Bank:         p.GetBank(),
+			DeliveryCost: fromProtoInt(p.DeliveryCost),
+			GoodsTotal:   fromProtoInt(p.GoodsTotal),
+			CustomFee:    fromProtoInt(p.CustomFee),
+		},
+		Locale:            message.GetLocale(),
+		InternalSignature: message.GetInternalSignature(),
+		CustomerID:        message.GetCustomerId(),
+		DeliveryService:   message.GetDeliveryService(),
+		ShardKey:          message.GetShardkey(),
+		SmID:              fromProtoInt(message.SmId),
+		OofShard:          message.GetOofShard(),
+		Version:           message.GetVersion(),
+	}
+	for _, item := range message.GetItems() {
+		decoded.Items = append(decoded.Items, models.Item{
+			ChrtID:      item.ChrtId,
+			TrackNumber: item.GetTrackNumber(),
+			Price:       fromProtoInt(item.Price),
+			RID:         item.GetRid(),
+			Name:        item.GetName(),
+			Sale:        fromProtoInt(item.Sale),
+			Size:        item.GetSize(),
+			TotalPrice:  fromProtoInt(item.TotalPrice),
+			NmID:        item.NmId,
+			Brand:       item.GetBrand(),
+			Status:      fromProtoInt(item.Status),
+		})
+	}
+	if message.DateCreated != nil {
+		decoded.DateCreated = message.GetDateCreated().AsTime()
+	}
+
+	// decoded order is checked by JSON Schema as JSON tree, so missing
+	// required values are reported the same way as missing JSON fields
+	encoded, err := json.Marshal(&decoded)
+	if err != nil {
+		return err
+	}
+	return c.schemas.Decode(schema.Order, strconv.Itoa(c.schemas.Current(schema.Order)), encoded, o)
+}
+
+func toProtoInt(v *int) *int64 {
+	if v == nil {
+		return nil
+	}
+	n := int64(*v)
+	return &n
+}
+
+func fromProtoInt(v *int64) *int {
+	if v == nil {
+		return nil
+	}
+	n := int(*v)
+	return &n
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"net/netip"
 	"time"
 
 	"github.com/caarlos0/env/v11"
@@ -23,6 +24,20 @@ type Config struct {
 
 	// Server is the HTTP server configuration
 	Server ServerConfig
+	// Webhook is the webhooks delivery configuration
+	Webhook WebhookConfig
+	// Outbox is the transactional outbox relay configuration
+	Outbox OutboxConfig
+	// Rejections is the rejected broker messages store configuration
+	Rejections RejectionsConfig
+	// Rules is the orders business rules validation configuration
+	Rules RulesConfig
+	// Payload is the broker messages payloads resolving configuration
+	Payload PayloadConfig
+	// Signing is the broker messages signatures verification configuration
+	Signing SigningConfig
+	// Handler is the broker messages handlers middlewares configuration
+	Handler HandlerConfig
 	// ShutdownTimeout is a timeout for application graceful shutdown
 	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"10s" validate:"gte=1s"`
 }
@@ -39,6 +54,196 @@ type ServerConfig struct {
 	WriteTimeout time.Duration `env:"HTTP_WRITE_TIMEOUT" envDefault:"10s" validate:"gte=1s"`
 	// IdleTimeout is the maximum amount of time to wait for the next request
 	IdleTimeout time.Duration `env:"HTTP_IDLE_TIMEOUT" envDefault:"120s" validate:"gte=1s"`
+	// LegacyDeprecation is the date since which unversioned API paths are deprecated
+	LegacyDeprecation time.Time `env:"HTTP_LEGACY_DEPRECATION" envDefault:"2026-10-19T00:00:00Z" validate:"ltfield=LegacySunset"`
+	// LegacySunset is the date after which deprecated unversioned API paths can be removed
+	LegacySunset time.Time `env:"HTTP_LEGACY_SUNSET" envDefault:"2027-01-01T00:00:00Z"`
+
+	// Auth is the HTTP API authentication configuration
+	Auth AuthConfig
+	// Masking is the PII masking configuration of HTTP API responses
+	Masking MaskingConfig
+	// RateLimit is the HTTP API rate limiting configuration
+	RateLimit RateLimitConfig
+	// Compression is the HTTP responses compression configuration
+	Compression CompressionConfig
+	// Stream is the orders stream (SSE) configuration
+	Stream StreamConfig
+	// Wait is the long-poll order waiting configuration
+	Wait WaitConfig
+	// Metrics is the Prometheus metrics endpoint configuration
+	Metrics MetricsConfig
+}
+
+// MetricsConfig describes Prometheus metrics endpoint configuration
+type MetricsConfig struct {
+	// Public exposes /metrics without authentication to scrapers of trusted network,
+	// otherwise metrics require admin role
+	Public bool `env:"HTTP_METRICS_PUBLIC" envDefault:"false"`
+}
+
+// AuthConfig describes HTTP API authentication configuration.
+// Callers are authenticated with static API keys or JWTs
+// signed with HMAC secret or RSA key
+type AuthConfig struct {
+	// Enabled turns authentication on. It must be disabled only for local development
+	Enabled bool `env:"AUTH_ENABLED" envDefault:"true"`
+	// APIKeys maps static API keys to their roles (key1:support,key2:admin)
+	APIKeys map[string]string `env:"AUTH_API_KEYS" envSeparator:"," envKeyValSeparator:":" validate:"dive,keys,min=16,endkeys,oneof=support admin"`
+	// JWTHMACSecret is a secret for HS256 signed JWTs. HS256 tokens are rejected if empty
+	JWTHMACSecret string `env:"AUTH_JWT_HMAC_SECRET" validate:"omitempty,min=32"`
+	// JWTRSAPublicKeyFile is a path to PEM RSA public key for RS256 signed JWTs. RS256 tokens are rejected if empty
+	JWTRSAPublicKeyFile string `env:"AUTH_JWT_RSA_PUBLIC_KEY_FILE" validate:"omitempty,file"`
+	// JWTIssuer is a required JWT "iss" claim. Not checked if empty
+	JWTIssuer string `env:"AUTH_JWT_ISSUER"`
+	// JWTAudience is a required JWT "aud" claim. Not checked if empty
+	JWTAudience string `env:"AUTH_JWT_AUDIENCE"`
+	// JWTLeeway is an allowed clock skew for JWT "exp" and "nbf" claims
+	JWTLeeway time.Duration `env:"AUTH_JWT_LEEWAY" envDefault:"30s" validate:"gte=0"`
+}
+
+// MaskingConfig describes masking of order delivery PII fields
+// for callers without full access role
+type MaskingConfig struct {
+	// Fields maps delivery fields to masking strategies (phone:phone,email:email,address:partial)
+	Fields map[string]string `env:"PII_MASK_FIELDS" envSeparator:"," envKeyValSeparator:":" envDefault:"name:partial,phone:phone,email:email,address:partial" validate:"dive,keys,oneof=name phone zip city address region email,endkeys,oneof=none full partial phone email"`
+	// FullAccessRoles are roles that see unmasked fields
+	FullAccessRoles []string `env:"PII_FULL_ACCESS_ROLES" envSeparator:"," envDefault:"admin" validate:"dive,oneof=support admin"`
+}
+
+// RateLimitConfig describes per-client token bucket rate limiting of HTTP API.
+// Route limits are set by route name (for example, orders)
+// and default limits are used for routes without them
+type RateLimitConfig struct {
+	// Enabled turns rate limiting on
+	Enabled bool `env:"RATE_LIMIT_ENABLED" envDefault:"true"`
+	// RPS is a default number of requests per second refilled to client bucket
+	RPS float64 `env:"RATE_LIMIT_RPS" envDefault:"10" validate:"gt=0"`
+	// Burst is a default client bucket capacity
+	Burst int `env:"RATE_LIMIT_BURST" envDefault:"20" validate:"gte=1"`
+	// RouteRPS overrides RPS per route (orders:5,docs:1)
+	RouteRPS map[string]float64 `env:"RATE_LIMIT_ROUTE_RPS" envSeparator:"," envKeyValSeparator:":" validate:"dive,gt=0"`
+	// RouteBurst overrides Burst per route (orders:10,docs:5)
+	RouteBurst map[string]int `env:"RATE_LIMIT_ROUTE_BURST" envSeparator:"," envKeyValSeparator:":" validate:"dive,gte=1"`
+	// IPRPS is a number of requests per second refilled to client IP bucket
+	// checked before authentication of protected routes
+	IPRPS float64 `env:"RATE_LIMIT_IP_RPS" envDefault:"50" validate:"gt=0"`
+	// IPBurst is a client IP bucket capacity checked before authentication
+	IPBurst int `env:"RATE_LIMIT_IP_BURST" envDefault:"100" validate:"gte=1"`
+	// TrustedProxies are proxies networks (CIDR) whose X-Forwarded-For is trusted
+	TrustedProxies []netip.Prefix `env:"RATE_LIMIT_TRUSTED_PROXIES" envSeparator:","`
+	// BucketTTL is a time after which unused client bucket is evicted
+	BucketTTL time.Duration `env:"RATE_LIMIT_BUCKET_TTL" envDefault:"10m" validate:"gte=1s"`
+	// MaxBuckets is a maximum number of client buckets per route
+	MaxBuckets int `env:"RATE_LIMIT_MAX_BUCKETS" envDefault:"10000" validate:"gte=1"`
+}
+
+// CompressionConfig describes negotiated compression of HTTP responses
+type CompressionConfig struct {
+	// Enabled turns compression on
+	Enabled bool `env:"HTTP_COMPRESSION_ENABLED" envDefault:"true"`
+	// Encodings are supported encodings in server preference order
+	Encodings []string `env:"HTTP_COMPRESSION_ENCODINGS" envSeparator:"," envDefault:"zstd,gzip" validate:"dive,oneof=zstd gzip"`
+	// MinSize is a minimum response body size in bytes worth compressing
+	MinSize int `env:"HTTP_COMPRESSION_MIN_SIZE" envDefault:"1024" validate:"gte=0"`
+	// ContentTypes are media types allowed to be compressed
+	ContentTypes []string `env:"HTTP_COMPRESSION_CONTENT_TYPES" envSeparator:"," envDefault:"application/json,application/problem+json,text/html,text/plain,text/css,application/javascript"`
+}
+
+// StreamConfig describes orders stream (Server-Sent Events) configuration
+type StreamConfig struct {
+	// BufferSize is a number of last events kept for resuming with Last-Event-ID
+	BufferSize int `env:"STREAM_BUFFER_SIZE" envDefault:"1000" validate:"gte=1"`
+	// ClientBuffer is a number of events buffered per client before it is dropped as slow
+	ClientBuffer int `env:"STREAM_CLIENT_BUFFER" envDefault:"64" validate:"gte=1"`
+	// MaxClients is a maximum number of concurrently connected clients
+	MaxClients int `env:"STREAM_MAX_CLIENTS" envDefault:"100" validate:"gte=1"`
+	// Heartbeat is an interval of keep-alive comments
+	Heartbeat time.Duration `env:"STREAM_HEARTBEAT" envDefault:"15s" validate:"gte=1s"`
+}
+
+// WaitConfig describes long-poll waiting for orders not saved yet
+type WaitConfig struct {
+	// MaxWait is a maximum wait duration, longer waits are clamped to it
+	MaxWait time.Duration `env:"ORDER_WAIT_MAX" envDefault:"30s" validate:"gte=1s"`
+	// MaxWaiters is a maximum number of concurrently waiting requests
+	MaxWaiters int `env:"ORDER_WAIT_MAX_WAITERS" envDefault:"1000" validate:"gte=1"`
+}
+
+// WebhookConfig describes delivery of order events to webhooks
+type WebhookConfig struct {
+	// Workers is a number of concurrent delivery workers
+	Workers int `env:"WEBHOOK_WORKERS" envDefault:"4" validate:"gte=1"`
+	// PollInterval is an interval of checking outbox for new events and jobs for due deliveries
+	PollInterval time.Duration `env:"WEBHOOK_POLL_INTERVAL" envDefault:"1s" validate:"gte=10ms"`
+	// BatchSize is a maximum number of outbox events fanned out to webhooks at once
+	BatchSize int `env:"WEBHOOK_BATCH_SIZE" envDefault:"100" validate:"gte=1"`
+	// Timeout is a timeout of single delivery request
+	Timeout time.Duration `env:"WEBHOOK_TIMEOUT" envDefault:"5s" validate:"gte=100ms"`
+	// MaxAttempts is a maximum number of delivery attempts of single event
+	MaxAttempts int `env:"WEBHOOK_MAX_ATTEMPTS" envDefault:"5" validate:"gte=1"`
+	// BackoffBase is a delay before second attempt, it is doubled for every next attempt
+	BackoffBase time.Duration `env:"WEBHOOK_BACKOFF_BASE" envDefault:"1s" validate:"gte=10ms"`
+	// BackoffMax is a maximum delay between attempts
+	BackoffMax time.Duration `env:"WEBHOOK_BACKOFF_MAX" envDefault:"1m" validate:"gtefield=BackoffBase"`
+	// DisableAfter is a number of consecutive failed events after which webhook is disabled. 0 means never
+	DisableAfter int `env:"WEBHOOK_DISABLE_AFTER" envDefault:"10" validate:"gte=0"`
+}
+
+// OutboxConfig describes relaying of transactional outbox messages to broker
+type OutboxConfig struct {
+	// PollInterval is an interval of checking outbox for pending messages
+	PollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" envDefault:"1s" validate:"gte=10ms"`
+	// BatchSize is a maximum number of messages published at once
+	BatchSize int `env:"OUTBOX_BATCH_SIZE" envDefault:"100" validate:"gte=1"`
+	// PublishTimeout is a timeout of publishing single batch to broker
+	PublishTimeout time.Duration `env:"OUTBOX_PUBLISH_TIMEOUT" envDefault:"30s" validate:"gte=100ms"`
+	// Retention is a time sent messages are kept in outbox before purging
+	Retention time.Duration `env:"OUTBOX_RETENTION" envDefault:"24h" validate:"gte=1m"`
+}
+
+// RejectionsConfig describes keeping of rejected broker messages.
+// Rejections have raw payloads with PII, so they are purged after Retention
+type RejectionsConfig struct {
+	// Retention is a time rejections are kept before purging
+	Retention time.Duration `env:"REJECTIONS_RETENTION" envDefault:"720h" validate:"gte=1h"`
+}
+
+// RulesConfig describes actions of orders business rules.
+// Every rule can reject order, only warn about violation or be ignored
+type RulesConfig struct {
+	// DefaultAction is an action of rules missing in Actions
+	DefaultAction string `env:"ORDER_RULES_DEFAULT_ACTION" envDefault:"reject" validate:"oneof=reject warn ignore"`
+	// Actions maps rule IDs to their actions (goods_total:reject,item_total:warn)
+	Actions map[string]string `env:"ORDER_RULES" envSeparator:"," envKeyValSeparator:":" validate:"dive,keys,oneof=goods_total amount item_total,endkeys,oneof=reject warn ignore"`
+}
+
+// PayloadConfig describes resolving of compressed and claim-check broker messages payloads
+type PayloadConfig struct {
+	// StoreDir is a local object store directory of claim-check payloads.
+	// Claim-check messages are skipped if it is empty
+	StoreDir string `env:"PAYLOAD_STORE_DIR" validate:"omitempty,dir"`
+	// MaxSize is a maximum size in bytes of decompressed or claimed payload
+	MaxSize int64 `env:"PAYLOAD_MAX_SIZE" envDefault:"67108864" validate:"gte=1024"`
+}
+
+// SigningConfig describes verification of orders messages HMAC signatures.
+// Keys have IDs, so they can be rotated: new key is added,
+// producers switch to it and old key is removed
+type SigningConfig struct {
+	// Required turns rejecting of unsigned messages on.
+	// Signed messages are verified if any key is set even if it is off
+	Required bool `env:"BROKER_SIGNATURE_REQUIRED" envDefault:"false"`
+	// Keys maps key IDs to HMAC-SHA256 secrets (k2025a:secret1,k2025b:secret2)
+	Keys map[string]string `env:"BROKER_SIGNATURE_KEYS" envSeparator:"," envKeyValSeparator:":" validate:"required_if=Required true,dive,keys,min=1,endkeys,min=32"`
+	// MaxAge is a maximum age of signed message by its timestamp. 0 means no limit
+	MaxAge time.Duration `env:"BROKER_SIGNATURE_MAX_AGE" envDefault:"10m" validate:"gte=0"`
+}
+
+// HandlerConfig describes broker messages handlers middlewares configuration
+type HandlerConfig struct {
+	// Timeout is a deadline of single message handling context. 0 means no limit
+	Timeout time.Duration `env:"BROKER_HANDLER_TIMEOUT" envDefault:"30s" validate:"gte=0"`
 }
 
 // LoadConfig loads application Config from environment variables.
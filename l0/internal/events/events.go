@@ -0,0 +1,31 @@
+package events
+
+import "wb-tech-l0/internal/models"
+
+// Notifier is notified about orders saved by broker handlers.
+// Implementations must not block, as they are called in message handling path
+type Notifier interface {
+	// OrderSaved is called after order was successfully saved to storage
+	OrderSaved(order *models.Order)
+}
+
+// Invalidator removes stale copies of changed orders by their uids.
+// It is used when changed order can't be loaded to notify Notifier
+type Invalidator interface {
+	// InvalidateOrder is called after order was successfully changed in storage
+	InvalidateOrder(uid string)
+}
+
+// multi is a Notifier that notifies all its notifiers
+type multi []Notifier
+
+// Multi returns Notifier that notifies all given notifiers in order
+func Multi(notifiers ...Notifier) Notifier {
+	return multi(notifiers)
+}
+
+func (m multi) OrderSaved(order *models.Order) {
+	for _, n := range m {
+		n.OrderSaved(order)
+	}
+}
@@ -0,0 +1,160 @@
+package events
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"wb-tech-l0/internal/models"
+)
+
+// ErrTooManySubscribers is returned by Hub.Subscribe when subscribers limit is reached
+var ErrTooManySubscribers = errors.New("too many subscribers")
+
+// Event is a saved order event
+type Event struct {
+	// ID is a unique event id used for resuming with Last-Event-ID
+	ID string
+	// Order is a saved order
+	Order *models.Order
+
+	seq uint64
+}
+
+// Hub fans out saved orders to subscribers (stream clients).
+// It keeps last events in bounded ring buffer, so reconnecting clients
+// can resume from their last event id. Slow subscribers, whose buffer
+// is full, are dropped instead of blocking publishers.
+// It's methods are safe for concurrent use
+type Hub struct {
+	// epoch distinguishes event ids of different process runs,
+	// as ring buffer is in-memory and sequence restarts with process
+	epoch string
+	seq   uint64
+
+	ring  []Event
+	start int
+	size  int
+
+	subscribers      map[*Subscription]struct{}
+	subscriberBuffer int
+	maxSubscribers   int
+
+	mu sync.Mutex
+}
+
+// Subscription is a single hub subscriber.
+// C is closed when subscriber is dropped as slow or closed
+type Subscription struct {
+	C <-chan Event
+
+	c   chan Event
+	hub *Hub
+}
+
+// NewHub creates and returns Hub with ring buffer of ringSize events,
+// subscriber channel buffer of subscriberBuffer events and at most maxSubscribers subscribers
+func NewHub(ringSize, subscriberBuffer, maxSubscribers int) *Hub {
+	return &Hub{
+		epoch:            strconv.FormatInt(time.Now().UnixMilli(), 36),
+		ring:             make([]Event, ringSize),
+		subscribers:      make(map[*Subscription]struct{}),
+		subscriberBuffer: subscriberBuffer,
+		maxSubscribers:   maxSubscribers,
+	}
+}
+
+// OrderSaved implements Notifier. It appends event to ring buffer
+// and sends it to all subscribers without blocking. Subscribers
+// with full buffer are dropped
+func (h *Hub) OrderSaved(order *models.Order) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	event := Event{ID: h.epoch + "-" + strconv.FormatUint(h.seq, 10), Order: order, seq: h.seq}
+
+	// appending to ring, overwriting the oldest event if it is full
+	if h.size < len(h.ring) {
+		h.ring[(h.start+h.size)%len(h.ring)] = event
+		h.size++
+	} else if len(h.ring) > 0 {
+		h.ring[h.start] = event
+		h.start = (h.start + 1) % len(h.ring)
+	}
+
+	for sub := range h.subscribers {
+		select {
+		case sub.c <- event:
+		default:
+			// slow subscriber, dropping it to not block publishers
+			h.remove(sub)
+		}
+	}
+}
+
+// Subscribe registers new subscriber. If lastEventID is not empty,
+// it also returns buffered events after it (or all buffered events,
+// if lastEventID is unknown, for example, from previous process run)
+func (h *Hub) Subscribe(lastEventID string) ([]Event, *Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.subscribers) >= h.maxSubscribers {
+		return nil, nil, ErrTooManySubscribers
+	}
+
+	var backlog []Event
+	if lastEventID != "" {
+		backlog = h.since(lastEventID)
+	}
+
+	c := make(chan Event, h.subscriberBuffer)
+	sub := &Subscription{C: c, c: c, hub: h}
+	h.subscribers[sub] = struct{}{}
+	return backlog, sub, nil
+}
+
+// Close unsubscribes subscriber. It is safe to call multiple times
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// Subscribers returns current number of subscribers
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers)
+}
+
+// remove removes subscriber and closes its channel. Caller must hold mu
+func (h *Hub) remove(sub *Subscription) {
+	if _, ok := h.subscribers[sub]; !ok {
+		return
+	}
+	delete(h.subscribers, sub)
+	close(sub.c)
+}
+
+// since returns buffered events after event with lastEventID. Caller must hold mu
+func (h *Hub) since(lastEventID string) []Event {
+	after := uint64(0)
+	if epoch, seq, found := strings.Cut(lastEventID, "-"); found && epoch == h.epoch {
+		if parsed, err := strconv.ParseUint(seq, 10, 64); err == nil {
+			after = parsed
+		}
+	}
+
+	events := make([]Event, 0, h.size)
+	for i := 0; i < h.size; i++ {
+		event := h.ring[(h.start+i)%len(h.ring)]
+		if event.seq > after {
+			events = append(events, event)
+		}
+	}
+	return events
+}
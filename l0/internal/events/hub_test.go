@@ -0,0 +1,84 @@
+package events
+
+import (
+	"errors"
+	"testing"
+
+	"wb-tech-l0/internal/models"
+)
+
+func TestHubResume(t *testing.T) {
+	hub := NewHub(3, 10, 10)
+	for _, uid := range []string{"a", "b", "c", "d"} {
+		hub.OrderSaved(&models.Order{OrderUID: uid})
+	}
+
+	// ring keeps only last 3 events
+	all, sub, err := hub.Subscribe("unknown")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	sub.Close()
+	if len(all) != 3 || all[0].Order.OrderUID != "b" {
+		t.Fatalf("Subscribe() backlog = %d events starting with %s, want 3 starting with b", len(all), all[0].Order.OrderUID)
+	}
+
+	// resuming after second buffered event
+	backlog, sub, err := hub.Subscribe(all[1].ID)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer sub.Close()
+	if len(backlog) != 1 || backlog[0].Order.OrderUID != "d" {
+		t.Errorf("Subscribe() backlog = %v, want only d", backlog)
+	}
+
+	// new subscriber without Last-Event-ID gets no backlog
+	backlog, sub2, err := hub.Subscribe("")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer sub2.Close()
+	if len(backlog) != 0 {
+		t.Errorf("Subscribe() backlog = %d events, want 0", len(backlog))
+	}
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	hub := NewHub(10, 1, 10)
+
+	_, sub, err := hub.Subscribe("")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	hub.OrderSaved(&models.Order{OrderUID: "a"})
+	hub.OrderSaved(&models.Order{OrderUID: "b"})
+
+	if event := <-sub.C; event.Order.OrderUID != "a" {
+		t.Errorf("first event = %s, want a", event.Order.OrderUID)
+	}
+	if _, ok := <-sub.C; ok {
+		t.Errorf("channel of slow subscriber is not closed")
+	}
+	if got := hub.Subscribers(); got != 0 {
+		t.Errorf("Subscribers() = %d, want 0", got)
+	}
+
+	// closing dropped subscription is safe
+	sub.Close()
+}
+
+func TestHubMaxSubscribers(t *testing.T) {
+	hub := NewHub(10, 1, 1)
+
+	_, sub, err := hub.Subscribe("")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer sub.Close()
+
+	if _, _, err := hub.Subscribe(""); !errors.Is(err, ErrTooManySubscribers) {
+		t.Errorf("Subscribe() error = %v, want %v", err, ErrTooManySubscribers)
+	}
+}
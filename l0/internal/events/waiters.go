@@ -0,0 +1,91 @@
+package events
+
+import (
+	"errors"
+	"sync"
+
+	"wb-tech-l0/internal/models"
+)
+
+// ErrTooManyWaiters is returned by Waiters.Register when waiters limit is reached
+var ErrTooManyWaiters = errors.New("too many waiters")
+
+// Waiters is a registry of in-process waiters for orders not saved yet.
+// Waiters are woken when broker handler saves their order (see Notifier).
+// It's methods are safe for concurrent use
+type Waiters struct {
+	waiters    map[string]map[chan *models.Order]struct{}
+	count      int
+	maxWaiters int
+
+	mu sync.Mutex
+}
+
+// NewWaiters creates and returns Waiters with at most maxWaiters concurrent waiters
+func NewWaiters(maxWaiters int) *Waiters {
+	return &Waiters{
+		waiters:    make(map[string]map[chan *models.Order]struct{}),
+		maxWaiters: maxWaiters,
+	}
+}
+
+// Register registers waiter for order with uid. Returned channel receives
+// the order when it is saved. Returned cancel function must be called
+// when waiter is not needed anymore (it is safe to call after wake up).
+// Waiter must be registered before checking storage, so order saved
+// between the check and registration is not missed
+func (w *Waiters) Register(uid string) (<-chan *models.Order, func(), error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.count >= w.maxWaiters {
+		return nil, nil, ErrTooManyWaiters
+	}
+
+	// buffered, so OrderSaved never blocks on waiter
+	c := make(chan *models.Order, 1)
+	if w.waiters[uid] == nil {
+		w.waiters[uid] = make(map[chan *models.Order]struct{})
+	}
+	w.waiters[uid][c] = struct{}{}
+	w.count++
+
+	cancel := func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, ok := w.waiters[uid][c]; !ok {
+			// already woken
+			return
+		}
+		delete(w.waiters[uid], c)
+		if len(w.waiters[uid]) == 0 {
+			delete(w.waiters, uid)
+		}
+		w.count--
+	}
+
+	return c, cancel, nil
+}
+
+// OrderSaved implements Notifier. It wakes all waiters of order
+func (w *Waiters) OrderSaved(order *models.Order) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	waiters, ok := w.waiters[order.OrderUID]
+	if !ok {
+		return
+	}
+	for c := range waiters {
+		c <- order
+	}
+	delete(w.waiters, order.OrderUID)
+	w.count -= len(waiters)
+}
+
+// Len returns current number of waiters
+func (w *Waiters) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.count
+}
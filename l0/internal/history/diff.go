@@ -0,0 +1,122 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"wb-tech-l0/internal/models"
+)
+
+// Change is a single changed field of order.
+// @Description Changed order field.
+type Change struct {
+	// Field JSON path, for example delivery.phone or items[1].price
+	Field string `json:"field"`
+	// Previous value, null if field was added
+	Old any `json:"old"`
+	// New value, null if field was removed
+	New any `json:"new"`
+}
+
+// ignoredFields are top level fields changed by every mutation
+// or kept separately, they are not reported in diffs
+var ignoredFields = map[string]bool{
+	"updated_at": true,
+	"timeline":   true,
+}
+
+// Diff returns field-level changes between two order revisions.
+// Fields are named by their JSON paths, so changes are described in
+// the same terms as API responses. Items are compared by index,
+// added and removed items are reported as whole values
+func Diff(before, after *models.Order) ([]Change, error) {
+	a, err := toTree(before)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toTree(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []Change{}
+	diff("", a, b, &changes)
+	return changes, nil
+}
+
+// toTree converts order to generic JSON tree.
+// Numbers are kept as json.Number to not lose precision
+func toTree(o *models.Order) (any, error) {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode order: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var tree any
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, fmt.Errorf("could not decode order: %w", err)
+	}
+	return tree, nil
+}
+
+// diff appends changes between a and b at path to changes
+func diff(path string, a, b any, changes *[]Change) {
+	switch av := a.(type) {
+	case map[string]any:
+		if bv, ok := b.(map[string]any); ok {
+			for _, key := range keys(av, bv) {
+				if path == "" && ignoredFields[key] {
+					continue
+				}
+				diff(join(path, key), av[key], bv[key], changes)
+			}
+			return
+		}
+	case []any:
+		if bv, ok := b.([]any); ok {
+			for i := range max(len(av), len(bv)) {
+				var x, y any
+				if i < len(av) {
+					x = av[i]
+				}
+				if i < len(bv) {
+					y = bv[i]
+				}
+				diff(fmt.Sprintf("%s[%d]", path, i), x, y, changes)
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, Change{Field: path, Old: a, New: b})
+	}
+}
+
+// keys returns sorted union of maps keys
+func keys(a, b map[string]any) []string {
+	result := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		result = append(result, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			result = append(result, k)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+// join joins JSON path with field name
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,56 @@
+package history
+
+import (
+	"encoding/json"
+	"testing"
+
+	"wb-tech-l0/internal/models"
+)
+
+func TestDiff(t *testing.T) {
+	amount, newAmount := 100, 150
+	price, newPrice := 10, 20
+	before := &models.Order{
+		OrderUID: "a",
+		Version:  1,
+		Delivery: models.Delivery{Phone: "+100"},
+		Payment:  models.Payment{Amount: &amount},
+		Items:    []models.Item{{Name: "x", Price: &price}},
+		Status:   models.StatusCreated,
+	}
+	after := *before
+	after.Version = 2
+	after.Delivery.Phone = "+200"
+	after.Payment.Amount = &newAmount
+	after.Items = []models.Item{{Name: "x", Price: &newPrice}, {Name: "y"}}
+	after.Timeline = []models.StatusTransition{{To: models.StatusCreated}}
+
+	changes, err := Diff(before, &after)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+
+	got := make(map[string]Change, len(changes))
+	for _, c := range changes {
+		got[c.Field] = c
+	}
+	for _, field := range []string{"version", "delivery.phone", "payment.amount", "items[0].price", "items[1]"} {
+		if _, ok := got[field]; !ok {
+			t.Errorf("Diff() has no change of %s", field)
+		}
+	}
+	if len(changes) != 5 {
+		t.Errorf("Diff() = %d changes, want 5: %v", len(changes), changes)
+	}
+	if c := got["items[0].price"]; c.Old != json.Number("10") || c.New != json.Number("20") {
+		t.Errorf("items[0].price change = %v -> %v, want 10 -> 20", c.Old, c.New)
+	}
+	if c := got["items[1]"]; c.Old != nil || c.New == nil {
+		t.Errorf("items[1] change = %v -> %v, want added item", c.Old, c.New)
+	}
+
+	changes, err = Diff(before, before)
+	if err != nil || len(changes) != 0 {
+		t.Errorf("Diff() of the same order = %v, %v, want no changes", changes, err)
+	}
+}
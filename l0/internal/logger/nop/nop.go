@@ -0,0 +1,32 @@
+package noplogger
+
+import "wb-tech-l0/internal/logger"
+
+// Nop is a Logger interface implementation that discards all records.
+// It is useful for tests and for components that must be silent
+type Nop struct{}
+
+// New creates and returns Nop implementation of Logger interface
+func New() *Nop {
+	return &Nop{}
+}
+
+func (l *Nop) Debug(string, ...logger.LogField) {}
+
+func (l *Nop) Info(string, ...logger.LogField) {}
+
+func (l *Nop) Warn(string, ...logger.LogField) {}
+
+func (l *Nop) Error(string, ...logger.LogField) {}
+
+func (l *Nop) Fatal(string, ...logger.LogField) {}
+
+func (l *Nop) Panic(string, ...logger.LogField) {}
+
+func (l *Nop) With(...logger.LogField) logger.Logger {
+	return l
+}
+
+func (l *Nop) Sync() error {
+	return nil
+}
@@ -16,8 +16,8 @@ type Order struct {
 	Payment Payment `json:"payment" validate:"required"`
 	// List of items
 	Items []Item `json:"items" validate:"required,min=1"`
-	// Locale
-	Locale string `json:"locale" validate:"required,alpha"`
+	// Locale: ISO 639-1 language with optional region (en, en-US)
+	Locale string `json:"locale" validate:"required,locale"`
 	// Internal signature
 	InternalSignature string `json:"internal_signature"`
 	// Customer ID
@@ -29,4 +29,13 @@ type Order struct {
 	// Order creation date
 	DateCreated time.Time `json:"date_created" validate:"required"`
 	OofShard    string    `json:"oof_shard" validate:"required,numeric"`
+	// Order version, starts from 1. Messages with version not greater than stored one are ignored,
+	// so every correction must have greater version
+	Version int64 `json:"version" validate:"gte=1"`
+	// Last update date, set by storage
+	UpdatedAt time.Time `json:"updated_at"`
+	// Current lifecycle status, set by storage
+	Status OrderStatus `json:"status"`
+	// Status changes, oldest first. Set by storage
+	Timeline []StatusTransition `json:"timeline,omitempty"`
 }
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// EventOrderSaved is an event type of orders saved to storage
+const EventOrderSaved = "order.saved"
+
+// OutboxMessage is an event written to storage in the same transaction
+// as the change it describes. Outbox relay publishes pending messages
+// to broker and marks them sent
+type OutboxMessage struct {
+	// Sequential message identifier
+	ID int64
+	// Event type, for example order.saved
+	Event string
+	// Message key, aggregate identifier (order uid)
+	Key string
+	// Encoded event payload
+	Payload []byte
+	// Message creation date
+	CreatedAt time.Time
+	// W3C traceparent of span message was saved in, empty if it is unknown
+	TraceParent string
+}
@@ -1,14 +1,17 @@
 package models
 
+import "wb-tech-l0/internal/money"
+
 // Payment contains payment info.
+// Amounts are in minor units of payment currency (cents for USD).
 // @Description Payment details for the order.
 type Payment struct {
 	// Transaction ID
 	Transaction string `json:"transaction" validate:"required"`
 	// Request ID
 	RequestID string `json:"request_id"`
-	// Payment currency
-	Currency string `json:"currency" validate:"required,alpha"`
+	// Payment currency, ISO 4217 code
+	Currency string `json:"currency" validate:"required,currency"`
 	// Payment provider
 	Provider string `json:"provider" validate:"required"`
 	// Payment amount
@@ -24,3 +27,45 @@ type Payment struct {
 	// Custom fee
 	CustomFee *int `json:"custom_fee" validate:"required,gte=0"`
 }
+
+// PaymentAmounts are payment amounts as money in payment currency
+type PaymentAmounts struct {
+	Amount       money.Money
+	DeliveryCost money.Money
+	GoodsTotal   money.Money
+	CustomFee    money.Money
+}
+
+// ItemAmounts are item prices as money in payment currency
+type ItemAmounts struct {
+	Price      money.Money
+	TotalPrice money.Money
+}
+
+// Amounts returns payment amounts as money in payment currency.
+// Missing amounts are zero
+func (p *Payment) Amounts() PaymentAmounts {
+	return PaymentAmounts{
+		Amount:       p.money(p.Amount),
+		DeliveryCost: p.money(p.DeliveryCost),
+		GoodsTotal:   p.money(p.GoodsTotal),
+		CustomFee:    p.money(p.CustomFee),
+	}
+}
+
+// ItemAmounts returns item prices as money in payment currency.
+// Missing prices are zero
+func (p *Payment) ItemAmounts(item *Item) ItemAmounts {
+	return ItemAmounts{
+		Price:      p.money(item.Price),
+		TotalPrice: p.money(item.TotalPrice),
+	}
+}
+
+// money returns amount in minor units as money in payment currency
+func (p *Payment) money(amount *int) money.Money {
+	if amount == nil {
+		return money.New(0, p.Currency)
+	}
+	return money.New(int64(*amount), p.Currency)
+}
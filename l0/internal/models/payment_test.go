@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+
+	"wb-tech-l0/internal/money"
+)
+
+func TestPaymentAmounts(t *testing.T) {
+	amount, deliveryCost, price := 1817, 1500, 453
+	p := Payment{Currency: "USD", Amount: &amount, DeliveryCost: &deliveryCost}
+
+	amounts := p.Amounts()
+	if want := money.New(1817, "USD"); amounts.Amount != want {
+		t.Errorf("Amount = %v, want %v", amounts.Amount, want)
+	}
+	if want := money.New(1500, "USD"); amounts.DeliveryCost != want {
+		t.Errorf("DeliveryCost = %v, want %v", amounts.DeliveryCost, want)
+	}
+	// missing amounts are zero
+	if want := money.New(0, "USD"); amounts.CustomFee != want {
+		t.Errorf("CustomFee = %v, want %v", amounts.CustomFee, want)
+	}
+
+	item := p.ItemAmounts(&Item{Price: &price})
+	if want := money.New(453, "USD"); item.Price != want {
+		t.Errorf("Price = %v, want %v", item.Price, want)
+	}
+}
@@ -0,0 +1,10 @@
+package models
+
+// Position is a consumer group position in topic partition.
+// Offset is the offset of the next message to consume
+type Position struct {
+	Group     string
+	Topic     string
+	Partition int
+	Offset    int64
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Rejection reasons of consumed orders messages
+const (
+	// RejectionPayload means that payload can't be resolved: corrupted compression or claim-check
+	RejectionPayload = "payload"
+	// RejectionContentType means that there is no codec for message content type
+	RejectionContentType = "content_type"
+	// RejectionDecode means that message can't be decoded or violates its schema
+	RejectionDecode = "decode"
+	// RejectionValidation means that decoded order fails struct validation
+	RejectionValidation = "validation"
+	// RejectionRules means that order violates rejecting business rules
+	RejectionRules = "rules"
+	// RejectionConflict means that order conflicts with another stored order
+	RejectionConflict = "conflict"
+)
+
+// RejectionError is a single structured error of rejected message.
+// @Description Field error of rejected message.
+type RejectionError struct {
+	// Field path, for example Order.Delivery.Phone or /payment/amount
+	Field string `json:"field,omitempty"`
+	// Failed validation rule, schema keyword or business rule ID
+	Rule string `json:"rule,omitempty"`
+	// Human-readable error message
+	Message string `json:"message,omitempty"`
+}
+
+// Rejection is a consumed message rejected by broker handler,
+// stored with its raw payload for inspection and replay.
+// @Description Rejected broker message with its raw payload and errors.
+type Rejection struct {
+	// Unique rejection identifier
+	ID string `json:"id"`
+	// Stream message was consumed from
+	Stream string `json:"stream"`
+	// Rejection reason: payload, content_type, decode, validation, rules or conflict
+	Reason string `json:"reason"`
+	// Rejection error
+	Error string `json:"error"`
+	// Structured field errors
+	Errors []RejectionError `json:"errors"`
+	// Message key
+	Key string `json:"key,omitempty"`
+	// Raw message value as consumed, base64 encoded
+	Payload []byte `json:"payload"`
+	// Message headers
+	Headers map[string]string `json:"headers"`
+	// Message source: topic, partition and offset
+	Source Source `json:"source"`
+	// Date of corrected payload replay, empty if rejection is not replayed
+	ReplayedAt *time.Time `json:"replayed_at,omitempty"`
+	// Caller who replayed corrected payload
+	ReplayedBy string `json:"replayed_by,omitempty"`
+	// Rejection date
+	CreatedAt time.Time `json:"created_at"`
+}
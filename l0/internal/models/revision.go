@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// SourceKind is a kind of order mutation source
+type SourceKind string
+
+// Order mutation sources
+const (
+	// SourceBroker is a broker message
+	SourceBroker SourceKind = "broker"
+	// SourceAPI is an HTTP API request
+	SourceAPI SourceKind = "api"
+	// SourceMigration is a revision backfilled for order saved before order history
+	SourceMigration SourceKind = "migration"
+)
+
+// Source describes where order mutation came from.
+// @Description Order mutation source: broker message position or API caller.
+type Source struct {
+	// Source kind: broker, api or migration
+	Kind SourceKind `json:"kind"`
+	// Broker topic
+	Topic string `json:"topic,omitempty"`
+	// Broker topic partition
+	Partition *int `json:"partition,omitempty"`
+	// Broker message offset
+	Offset *int64 `json:"offset,omitempty"`
+	// API caller subject
+	Caller string `json:"caller,omitempty"`
+	// API request ID
+	RequestID string `json:"request_id,omitempty"`
+	// W3C traceparent of broker message handling span
+	TraceParent string `json:"trace_parent,omitempty"`
+}
+
+// BrokerSource returns Source of broker message at topic partition offset
+func BrokerSource(topic string, partition int, offset int64) Source {
+	return Source{Kind: SourceBroker, Topic: topic, Partition: &partition, Offset: &offset}
+}
+
+// APISource returns Source of API request made by caller
+func APISource(caller, requestID string) Source {
+	return Source{Kind: SourceAPI, Caller: caller, RequestID: requestID}
+}
+
+// Revision kinds
+const (
+	RevisionCreated       = "created"
+	RevisionUpdated       = "updated"
+	RevisionStatusChanged = "status_changed"
+)
+
+// Revision is an immutable snapshot of order saved on every order mutation
+type Revision struct {
+	// Revision number, starting from 1 for every order
+	Revision int
+	// Mutation kind: created, updated or status_changed
+	Kind string
+	// Order version after mutation
+	Version int64
+	// Mutation source
+	Source Source
+	// Order after mutation, without timeline
+	Order *Order
+	// Revision date
+	CreatedAt time.Time
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// EventOrderStatusChanged is an event type of order status changes
+const EventOrderStatusChanged = "order.status_changed"
+
+// OrderStatus is an order lifecycle status
+type OrderStatus string
+
+// Order statuses. Orders are created with StatusCreated
+// and can change status only by allowed transitions
+const (
+	StatusCreated    OrderStatus = "created"
+	StatusPaid       OrderStatus = "paid"
+	StatusAssembling OrderStatus = "assembling"
+	StatusShipped    OrderStatus = "shipped"
+	StatusDelivered  OrderStatus = "delivered"
+	StatusCancelled  OrderStatus = "cancelled"
+)
+
+// transitions are allowed status transitions. Orders can be cancelled
+// until they are shipped. Delivered and cancelled are final statuses
+var transitions = map[OrderStatus][]OrderStatus{
+	StatusCreated:    {StatusPaid, StatusCancelled},
+	StatusPaid:       {StatusAssembling, StatusCancelled},
+	StatusAssembling: {StatusShipped, StatusCancelled},
+	StatusShipped:    {StatusDelivered},
+}
+
+// CanTransition reports whether order with status from can change status to
+func (from OrderStatus) CanTransition(to OrderStatus) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusChange is a message changing order status.
+// @Description Order status change message.
+type StatusChange struct {
+	// Order UID
+	OrderUID string `json:"order_uid" validate:"required"`
+	// New status
+	Status OrderStatus `json:"status" validate:"required,oneof=paid assembling shipped delivered cancelled"`
+	// Status change date
+	ChangedAt time.Time `json:"changed_at" validate:"required"`
+	// Optional change reason, for example cancellation reason
+	Reason string `json:"reason,omitempty" validate:"max=500"`
+}
+
+// StatusTransition is an order status history record.
+// @Description Order status timeline record.
+type StatusTransition struct {
+	// Previous status, empty for order creation
+	From OrderStatus `json:"from,omitempty"`
+	// New status
+	To OrderStatus `json:"to"`
+	// Status change date
+	ChangedAt time.Time `json:"changed_at"`
+	// Change reason
+	Reason string `json:"reason,omitempty"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to OrderStatus
+		want     bool
+	}{
+		{StatusCreated, StatusPaid, true},
+		{StatusPaid, StatusAssembling, true},
+		{StatusAssembling, StatusShipped, true},
+		{StatusShipped, StatusDelivered, true},
+		{StatusCreated, StatusCancelled, true},
+		{StatusAssembling, StatusCancelled, true},
+		{StatusShipped, StatusCancelled, false},
+		{StatusCreated, StatusShipped, false},
+		{StatusPaid, StatusCreated, false},
+		{StatusDelivered, StatusCancelled, false},
+		{StatusCancelled, StatusPaid, false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.CanTransition(tt.to); got != tt.want {
+			t.Errorf("%s.CanTransition(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
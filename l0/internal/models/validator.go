@@ -0,0 +1,28 @@
+package models
+
+import (
+	"slices"
+
+	"github.com/go-playground/validator/v10"
+
+	"wb-tech-l0/internal/money"
+)
+
+// NewValidator creates and returns validator for models with
+// required structs enabled and custom tags (currency, locale, webhook_event) registered.
+// Validator caches information about structs, so single instance should be reused
+func NewValidator() *validator.Validate {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	err := money.RegisterValidations(validate)
+	if err == nil {
+		// webhooks subscribed to unknown events would never fire
+		err = validate.RegisterValidation("webhook_event", func(fl validator.FieldLevel) bool {
+			return slices.Contains(WebhookEvents, fl.Field().String())
+		})
+	}
+	if err != nil {
+		// tags and functions are constant, so it is a programming error
+		panic("could not register models validations: " + err.Error())
+	}
+	return validate
+}
@@ -0,0 +1,96 @@
+package models
+
+import "time"
+
+// WebhookEvents are event types delivered to webhooks
+var WebhookEvents = []string{EventOrderSaved}
+
+// Webhook is a subscription of external endpoint to order events.
+// @Description Webhook subscription to order events.
+type Webhook struct {
+	// Unique webhook identifier
+	ID string `json:"id"`
+	// Target URL receiving POST requests
+	URL string `json:"url" validate:"required,http_url"`
+	// Secret for HMAC-SHA256 payload signature. It is never returned by API
+	Secret string `json:"secret,omitempty" validate:"required,min=16"`
+	// Subscribed event types, one of WebhookEvents
+	Events []string `json:"events" validate:"required,min=1,dive,webhook_event" enums:"order.saved"`
+	// Delivery services filter. Empty means all delivery services
+	DeliveryServices []string `json:"delivery_services,omitempty"`
+	// Whether webhook receives events. Webhooks are disabled after too many failed deliveries
+	Enabled bool `json:"enabled"`
+	// Number of consecutive failed deliveries
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// Webhook creation date
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Matches reports whether webhook is subscribed to event of order from deliveryService
+func (w *Webhook) Matches(event, deliveryService string) bool {
+	if !w.Enabled {
+		return false
+	}
+	if !contains(w.Events, event) {
+		return false
+	}
+	return len(w.DeliveryServices) == 0 || contains(w.DeliveryServices, deliveryService)
+}
+
+// WebhookDelivery is a single delivery attempt of event to webhook.
+// @Description Webhook delivery attempt log record.
+type WebhookDelivery struct {
+	// Unique delivery attempt identifier
+	ID string `json:"id"`
+	// Webhook identifier
+	WebhookID string `json:"webhook_id"`
+	// Event identifier, the same for all attempts of event delivery
+	EventID string `json:"event_id"`
+	// Event type
+	Event string `json:"event"`
+	// Order UID of event
+	OrderUID string `json:"order_uid"`
+	// Attempt number starting from 1
+	Attempt int `json:"attempt"`
+	// HTTP status code of webhook response, 0 if there was no response
+	StatusCode int `json:"status_code"`
+	// Error of delivery attempt
+	Error string `json:"error,omitempty"`
+	// Whether attempt was successful
+	Success bool `json:"success"`
+	// Attempt duration in milliseconds
+	DurationMs int64 `json:"duration_ms"`
+	// Attempt date
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookJob is a pending delivery of event to webhook.
+// Jobs are stored, so deliveries and their retries survive restarts
+type WebhookJob struct {
+	// ID is a job identifier
+	ID int64
+	// Webhook is a target webhook
+	Webhook Webhook
+	// EventID is an event identifier, the same for all webhooks and attempts
+	EventID string
+	// Event is an event type
+	Event string
+	// OrderUID is an order UID of event
+	OrderUID string
+	// Payload is an encoded webhook request body
+	Payload []byte
+	// Attempt is a number of next attempt starting from 1
+	Attempt int
+	// TraceParent is a W3C traceparent of span event was saved in, empty if it is unknown
+	TraceParent string
+}
+
+// contains reports whether values contain value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
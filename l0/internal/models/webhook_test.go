@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestWebhookEventsValidation(t *testing.T) {
+	validate := NewValidator()
+
+	tests := []struct {
+		name    string
+		events  []string
+		wantErr bool
+	}{
+		{name: "known event", events: []string{EventOrderSaved}},
+		{name: "typo", events: []string{"order.save"}, wantErr: true},
+		{name: "not delivered event", events: []string{EventOrderSaved, EventOrderStatusChanged}, wantErr: true},
+		{name: "empty event", events: []string{""}, wantErr: true},
+		{name: "no events", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhook := Webhook{URL: "https://example.com/hook", Secret: "0123456789abcdef", Events: tt.events}
+			if err := validate.Struct(webhook); (err != nil) != tt.wantErr {
+				t.Errorf("Struct() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
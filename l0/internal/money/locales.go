@@ -0,0 +1,77 @@
+package money
+
+import "strings"
+
+// numberFormat describes how amounts are written in locale
+type numberFormat struct {
+	// group separates thousands
+	group string
+	// decimal separates minor units
+	decimal string
+	// symbolAfter places currency after number
+	symbolAfter bool
+	// space separates currency and number
+	space bool
+}
+
+// nbsp is a no-break space, so formatted amounts are not wrapped
+const nbsp = " "
+
+// locales maps ISO 639-1 language codes to their number formats.
+// Languages without format are formatted as defaultLocale
+var locales = map[string]numberFormat{
+	"en": {group: ",", decimal: "."},
+	"ru": {group: nbsp, decimal: ",", symbolAfter: true, space: true},
+	"uk": {group: nbsp, decimal: ",", symbolAfter: true, space: true},
+	"be": {group: nbsp, decimal: ",", symbolAfter: true, space: true},
+	"kk": {group: nbsp, decimal: ",", symbolAfter: true, space: true},
+	"uz": {group: nbsp, decimal: ",", symbolAfter: true, space: true},
+	"hy": {group: nbsp, decimal: ",", symbolAfter: true, space: true},
+	"ka": {group: nbsp, decimal: ",", symbolAfter: true, space: true},
+	"pl": {group: nbsp, decimal: ",", symbolAfter: true, space: true},
+	"fr": {group: nbsp, decimal: ",", symbolAfter: true, space: true},
+	"de": {group: ".", decimal: ",", symbolAfter: true, space: true},
+	"es": {group: ".", decimal: ",", symbolAfter: true, space: true},
+	"it": {group: ".", decimal: ",", symbolAfter: true, space: true},
+	"nl": {group: ".", decimal: ",", space: true},
+	"pt": {group: ".", decimal: ",", space: true},
+	"tr": {group: ".", decimal: ","},
+	"zh": {group: ",", decimal: "."},
+	"ja": {group: ",", decimal: "."},
+	"ko": {group: ",", decimal: "."},
+	"hi": {group: ",", decimal: "."},
+	"he": {group: ",", decimal: ".", symbolAfter: true, space: true},
+}
+
+// defaultLocale is used for formatting amounts of unknown locales
+const defaultLocale = "en"
+
+// language returns language subtag of locale: en for en, en-US and en_US
+func language(locale string) string {
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		return strings.ToLower(locale[:i])
+	}
+	return strings.ToLower(locale)
+}
+
+// ValidLocale reports whether locale is ISO 639-1 language code
+// with optional ISO 3166-1 region: en, en-US or en_US
+func ValidLocale(locale string) bool {
+	lang := language(locale)
+	if _, ok := languages[lang]; !ok || lang != locale[:len(lang)] {
+		return false
+	}
+	if region := locale[len(lang):]; region != "" {
+		return len(region) == 3 && isUpper(region[1:])
+	}
+	return true
+}
+
+func isUpper(s string) bool {
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
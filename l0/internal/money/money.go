@@ -0,0 +1,135 @@
+package money
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Money is an amount in minor units of currency,
+// for example 1817 USD is 18.17 dollars and 1817 JPY is 1817 yen
+type Money struct {
+	// Amount in currency minor units
+	Amount int64 `json:"amount"`
+	// ISO 4217 currency code
+	Currency string `json:"currency"`
+}
+
+// New creates and returns Money of amount minor units of currency
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Add returns sum of m and others. Amounts are expected to be in m currency
+func (m Money) Add(others ...Money) Money {
+	for _, o := range others {
+		m.Amount += o.Amount
+	}
+	return m
+}
+
+// ValidCurrency reports whether code is active ISO 4217 currency code
+func ValidCurrency(code string) bool {
+	_, ok := exponents[code]
+	return ok
+}
+
+// Exponent returns number of minor unit digits of currency.
+// Second value is false for unknown currencies
+func Exponent(code string) (int, bool) {
+	exp, ok := exponents[code]
+	return exp, ok
+}
+
+// Decimal returns amount in major units without grouping: 18.17.
+// Amounts of unknown currencies are returned as is
+func (m Money) Decimal() string {
+	integer, fraction := m.split()
+	if fraction == "" {
+		return integer
+	}
+	return integer + "." + fraction
+}
+
+// Format returns amount in major units with currency written
+// as locale does: $1,234.56 for en and 1 234,56 ₽ for ru.
+// Unknown locales are formatted as en
+func (m Money) Format(locale string) string {
+	format, ok := locales[language(locale)]
+	if !ok {
+		format = locales[defaultLocale]
+	}
+
+	integer, fraction := m.split()
+	negative := strings.HasPrefix(integer, "-")
+	integer = strings.TrimPrefix(integer, "-")
+
+	var b strings.Builder
+	// grouping thousands from the left, so first group can be shorter
+	for i, c := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(format.group)
+		}
+		b.WriteRune(c)
+	}
+	if fraction != "" {
+		b.WriteString(format.decimal)
+		b.WriteString(fraction)
+	}
+	number := b.String()
+
+	symbol, ok := symbols[m.Currency]
+	space := format.space
+	if !ok {
+		// codes are always separated from number
+		symbol, space = m.Currency, true
+	}
+	separator := ""
+	if space {
+		separator = nbsp
+	}
+
+	sign := ""
+	if negative {
+		sign = "-"
+	}
+	if format.symbolAfter {
+		return sign + number + separator + symbol
+	}
+	return sign + symbol + separator + number
+}
+
+// split returns integer and fraction parts of amount in major units.
+// Fraction is empty for currencies without minor units and unknown ones
+func (m Money) split() (string, string) {
+	exp, ok := exponents[m.Currency]
+	if !ok || exp == 0 {
+		return strconv.FormatInt(m.Amount, 10), ""
+	}
+
+	abs := m.Amount
+	sign := ""
+	if abs < 0 {
+		abs, sign = -abs, "-"
+	}
+	digits := strconv.FormatInt(abs, 10)
+	// padding with zeros to have at least one integer digit
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp], digits[len(digits)-exp:]
+}
+
+// RegisterValidations registers currency (ISO 4217 code)
+// and locale (ISO 639-1 language with optional region) validation tags
+func RegisterValidations(validate *validator.Validate) error {
+	if err := validate.RegisterValidation("currency", func(fl validator.FieldLevel) bool {
+		return ValidCurrency(fl.Field().String())
+	}); err != nil {
+		return err
+	}
+	return validate.RegisterValidation("locale", func(fl validator.FieldLevel) bool {
+		return ValidLocale(fl.Field().String())
+	})
+}
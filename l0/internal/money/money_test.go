@@ -0,0 +1,95 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		money  Money
+		locale string
+		want   string
+	}{
+		{name: "Dollars", money: New(1817, "USD"), locale: "en", want: "$18.17"},
+		{name: "Grouping", money: New(123456789, "USD"), locale: "en-US", want: "$1,234,567.89"},
+		{name: "Rubles", money: New(123456, "RUB"), locale: "ru", want: "1" + nbsp + "234,56" + nbsp + "₽"},
+		{name: "Euros in german", money: New(100000, "EUR"), locale: "de_DE", want: "1.000,00" + nbsp + "€"},
+		{name: "Yen without minor units", money: New(1817, "JPY"), locale: "ja", want: "¥1,817"},
+		{name: "Three digits exponent", money: New(5, "KWD"), locale: "en", want: "KWD" + nbsp + "0.005"},
+		{name: "Negative", money: New(-150, "USD"), locale: "en", want: "-$1.50"},
+		{name: "Unknown locale", money: New(1817, "USD"), locale: "xx", want: "$18.17"},
+		{name: "Language without format", money: New(1817, "USD"), locale: "sv-SE", want: "$18.17"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.money.Format(tt.locale); got != tt.want {
+				t.Errorf("Format(%q) = %q, want %q", tt.locale, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{money: New(1817, "USD"), want: "18.17"},
+		{money: New(7, "USD"), want: "0.07"},
+		{money: New(-7, "USD"), want: "-0.07"},
+		{money: New(1817, "JPY"), want: "1817"},
+		{money: New(12345, "CLF"), want: "1.2345"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.money.Decimal(); got != tt.want {
+			t.Errorf("Decimal(%d %s) = %q, want %q", tt.money.Amount, tt.money.Currency, got, tt.want)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	got := New(1817, "USD").Add(New(-17, "USD"), New(200, "USD"))
+	if want := New(2000, "USD"); got != want {
+		t.Errorf("Add() = %v, want %v", got, want)
+	}
+}
+
+func TestValidations(t *testing.T) {
+	type payload struct {
+		Currency string `validate:"currency"`
+		Locale   string `validate:"locale"`
+	}
+	validate := validator.New()
+	if err := RegisterValidations(validate); err != nil {
+		t.Fatalf("RegisterValidations() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		payload payload
+		wantErr bool
+	}{
+		{name: "Valid", payload: payload{Currency: "USD", Locale: "en"}},
+		{name: "Locale with region", payload: payload{Currency: "RUB", Locale: "ru-RU"}},
+		{name: "Locale with underscore", payload: payload{Currency: "RUB", Locale: "ru_RU"}},
+		{name: "Unknown currency", payload: payload{Currency: "ABC", Locale: "en"}, wantErr: true},
+		{name: "Lowercase currency", payload: payload{Currency: "usd", Locale: "en"}, wantErr: true},
+		{name: "Metal without minor units", payload: payload{Currency: "XAU", Locale: "en"}, wantErr: true},
+		{name: "Language without format", payload: payload{Currency: "SEK", Locale: "sv-SE"}},
+		{name: "Unknown locale", payload: payload{Currency: "USD", Locale: "xx"}, wantErr: true},
+		{name: "Invalid region", payload: payload{Currency: "USD", Locale: "en-us"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validate.Struct(tt.payload); (err != nil) != tt.wantErr {
+				t.Errorf("Struct() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
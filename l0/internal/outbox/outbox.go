@@ -0,0 +1,169 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"wb-tech-l0/internal/broker"
+	brokermiddlewares "wb-tech-l0/internal/broker/middlewares"
+	"wb-tech-l0/internal/config"
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/pii"
+	"wb-tech-l0/internal/storage"
+)
+
+// Published message headers
+const (
+	HeaderEvent    = "event"
+	HeaderOutboxID = "outbox-id"
+)
+
+// leaseMargin is added to publish timeout to get lease of claimed messages,
+// it covers marking them sent
+const leaseMargin = 30 * time.Second
+
+// Relay publishes pending outbox messages to broker producer.
+// Messages are marked sent only after producer acknowledged them,
+// so every message is published at least once. Consumers must
+// deduplicate events by outbox-id header.
+// Orders in published events have delivery PII masked
+type Relay struct {
+	cfg      *config.OutboxConfig
+	store    storage.OutboxStorage
+	producer broker.Producer
+	masker   *pii.Masker
+	log      logger.Logger
+}
+
+// New creates and returns Relay. It must be started with Run
+func New(cfg *config.OutboxConfig, store storage.OutboxStorage, producer broker.Producer, masker *pii.Masker, log logger.Logger) *Relay {
+	return &Relay{
+		cfg:      cfg,
+		store:    store,
+		producer: producer,
+		masker:   masker,
+		log:      log,
+	}
+}
+
+// Run polls outbox every PollInterval and relays all pending messages.
+// Sent messages older than Retention are purged every Retention / 10.
+// It blocks until ctx is done
+func (r *Relay) Run(ctx context.Context) {
+	r.log.Debug("Starting outbox relay loop")
+	defer r.log.Debug("Outbox relay loop exited")
+
+	poll := time.NewTicker(r.cfg.PollInterval)
+	defer poll.Stop()
+	purge := time.NewTicker(r.cfg.Retention / 10)
+	defer purge.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+			r.relay(ctx)
+		case <-purge.C:
+			purged, err := r.store.PurgeOutbox(ctx, time.Now().Add(-r.cfg.Retention))
+			if err != nil {
+				r.log.Warn("Failed to purge outbox", logger.Error(err))
+				continue
+			}
+			r.log.Debug("Outbox purged", logger.Field("count", purged))
+		}
+	}
+}
+
+// relay relays batches of pending messages until outbox is drained or relaying fails.
+// Failed messages stay pending and are relayed on next poll
+func (r *Relay) relay(ctx context.Context) {
+	for ctx.Err() == nil {
+		relayed, err := r.relayBatch(ctx)
+		if err != nil {
+			r.log.Warn("Failed to relay outbox", logger.Error(err))
+			return
+		}
+		if relayed > 0 {
+			r.log.Debug("Outbox relayed", logger.Field("count", relayed))
+		}
+		if relayed < r.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// relayBatch claims batch of pending messages, publishes them with PublishTimeout
+// and marks them sent. No transaction is held while publishing. If publish fails,
+// messages are released. If marking fails, they are published again after their lease,
+// so delivery is at least once. It returns number of relayed messages
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	messages, err := r.store.ClaimOutbox(ctx, r.cfg.BatchSize, r.cfg.PublishTimeout+leaseMargin)
+	if err != nil {
+		return 0, err
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+	ids := make([]int64, len(messages))
+	for i := range messages {
+		ids[i] = messages[i].ID
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, r.cfg.PublishTimeout)
+	err = r.publish(publishCtx, messages)
+	cancel()
+	if err != nil {
+		if err := r.store.ReleaseOutbox(ctx, ids); err != nil {
+			r.log.Warn("Failed to release outbox", logger.Error(err))
+		}
+		return 0, fmt.Errorf("failed to publish outbox: %w", err)
+	}
+
+	if err := r.store.MarkOutboxSent(ctx, ids); err != nil {
+		return 0, err
+	}
+	return len(messages), nil
+}
+
+// publish converts outbox messages to broker messages and publishes them
+func (r *Relay) publish(ctx context.Context, messages []models.OutboxMessage) error {
+	result, err := Messages(messages, r.masker)
+	if err != nil {
+		return err
+	}
+	return r.producer.Publish(ctx, broker.StreamEvents, result...)
+}
+
+// Messages converts outbox messages to broker messages keyed by aggregate
+// with event type, outbox message id and traceparent of saving span in headers.
+// order.saved payloads are masked with masker
+func Messages(messages []models.OutboxMessage, masker *pii.Masker) ([]*broker.Message, error) {
+	result := make([]*broker.Message, 0, len(messages))
+	for _, m := range messages {
+		value := m.Payload
+		if m.Event == models.EventOrderSaved {
+			var err error
+			if value, err = masker.MaskOrderJSON(m.Payload); err != nil {
+				return nil, fmt.Errorf("could not mask outbox message %d: %w", m.ID, err)
+			}
+		}
+		message := &broker.Message{
+			Key:       []byte(m.Key),
+			Value:     value,
+			Timestamp: m.CreatedAt,
+			Headers: map[string][]byte{
+				HeaderEvent:    []byte(m.Event),
+				HeaderOutboxID: []byte(strconv.FormatInt(m.ID, 10)),
+			},
+		}
+		if m.TraceParent != "" {
+			message.Headers[brokermiddlewares.HeaderTraceParent] = []byte(m.TraceParent)
+		}
+		result = append(result, message)
+	}
+	return result, nil
+}
@@ -0,0 +1,141 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/broker"
+	brokermiddlewares "wb-tech-l0/internal/broker/middlewares"
+	"wb-tech-l0/internal/config"
+	noplogger "wb-tech-l0/internal/logger/nop"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/pii"
+)
+
+// fakeStore is an in-memory OutboxStorage
+type fakeStore struct {
+	pending []models.OutboxMessage
+	locked  map[int64]bool
+	sent    []models.OutboxMessage
+}
+
+func (s *fakeStore) ClaimOutbox(_ context.Context, limit int, _ time.Duration) ([]models.OutboxMessage, error) {
+	var claimed []models.OutboxMessage
+	for _, m := range s.pending {
+		if len(claimed) == limit {
+			break
+		}
+		if !s.locked[m.ID] {
+			s.locked[m.ID] = true
+			claimed = append(claimed, m)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *fakeStore) MarkOutboxSent(_ context.Context, ids []int64) error {
+	for _, id := range ids {
+		for i, m := range s.pending {
+			if m.ID == id {
+				s.sent = append(s.sent, m)
+				s.pending = slices.Delete(s.pending, i, i+1)
+				break
+			}
+		}
+		delete(s.locked, id)
+	}
+	return nil
+}
+
+func (s *fakeStore) ReleaseOutbox(_ context.Context, ids []int64) error {
+	for _, id := range ids {
+		delete(s.locked, id)
+	}
+	return nil
+}
+
+func (s *fakeStore) PurgeOutbox(context.Context, time.Time) (int64, error) { return 0, nil }
+
+// fakeProducer records published messages and fails when fail is set
+type fakeProducer struct {
+	published []*broker.Message
+	fail      bool
+	deadlines int
+}
+
+func (p *fakeProducer) Close() error { return nil }
+
+func (p *fakeProducer) Publish(ctx context.Context, _ broker.Stream, messages ...*broker.Message) error {
+	if _, ok := ctx.Deadline(); ok {
+		p.deadlines++
+	}
+	if p.fail {
+		return errors.New("broker is down")
+	}
+	p.published = append(p.published, messages...)
+	return nil
+}
+
+func TestRelay(t *testing.T) {
+	store := &fakeStore{locked: make(map[int64]bool)}
+	for i := int64(1); i <= 5; i++ {
+		store.pending = append(store.pending, models.OutboxMessage{ID: i, Event: models.EventOrderSaved, Key: "order", Payload: []byte("{}")})
+	}
+	store.pending[4].TraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	producer := &fakeProducer{fail: true}
+	relay := New(&config.OutboxConfig{BatchSize: 2, PublishTimeout: time.Second}, store, producer, pii.New(&config.MaskingConfig{}), noplogger.New())
+
+	// failed publishing keeps messages pending
+	relay.relay(context.Background())
+	if len(store.pending) != 5 || len(store.locked) != 0 {
+		t.Fatalf("pending = %d, locked = %d after failed publish, want 5 and 0", len(store.pending), len(store.locked))
+	}
+
+	// relay drains outbox in batches
+	producer.fail = false
+	relay.relay(context.Background())
+	if len(store.pending) != 0 || len(producer.published) != 5 {
+		t.Fatalf("pending = %d, published = %d, want 0 and 5", len(store.pending), len(producer.published))
+	}
+
+	if producer.deadlines != 4 {
+		t.Errorf("publishes with deadline = %d, want 4", producer.deadlines)
+	}
+
+	m := producer.published[4]
+	if string(m.Key) != "order" || string(m.Headers[HeaderEvent]) != models.EventOrderSaved || string(m.Headers[HeaderOutboxID]) != "5" {
+		t.Errorf("published message = key %s, headers %v", m.Key, m.Headers)
+	}
+	if string(m.Headers[brokermiddlewares.HeaderTraceParent]) != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("published message traceparent = %s, want saving span", m.Headers[brokermiddlewares.HeaderTraceParent])
+	}
+	if _, ok := producer.published[0].Headers[brokermiddlewares.HeaderTraceParent]; ok {
+		t.Error("message without saving span has traceparent header")
+	}
+}
+
+func TestMessagesMasking(t *testing.T) {
+	masker := pii.New(&config.MaskingConfig{Fields: map[string]string{"phone": pii.StrategyPhone}})
+	messages, err := Messages([]models.OutboxMessage{
+		{ID: 1, Event: models.EventOrderSaved, Key: "o1", Payload: []byte(`{"order_uid":"o1","delivery":{"phone":"+79991231234","city":"Moscow"}}`)},
+		{ID: 2, Event: models.EventOrderStatusChanged, Key: "o1", Payload: []byte(`{"order_uid":"o1","status":"paid"}`)},
+	}, masker)
+	if err != nil {
+		t.Fatalf("Messages() error = %v", err)
+	}
+
+	var order models.Order
+	if err := json.Unmarshal(messages[0].Value, &order); err != nil {
+		t.Fatalf("order.saved payload is invalid: %v", err)
+	}
+	if order.OrderUID != "o1" || order.Delivery.Phone != "+7******1234" || order.Delivery.City != "Moscow" {
+		t.Errorf("order.saved payload = %s, want masked phone only", messages[0].Value)
+	}
+	if string(messages[1].Value) != `{"order_uid":"o1","status":"paid"}` {
+		t.Errorf("status payload = %s, want unchanged", messages[1].Value)
+	}
+}
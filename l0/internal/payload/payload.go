@@ -0,0 +1,184 @@
+package payload
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+
+	"wb-tech-l0/internal/config"
+)
+
+// message headers describing payload
+const (
+	// HeaderContentEncoding is an encoding of payload: gzip, zstd or identity
+	HeaderContentEncoding = "content-encoding"
+	// HeaderClaimCheck is a key of payload in object store. Message value
+	// is ignored if it is set, so large payloads don't go through broker
+	HeaderClaimCheck = "claim-check"
+	// HeaderClaimCheckDigest is a digest of stored payload bytes: sha256:<hex>
+	HeaderClaimCheckDigest = "claim-check-digest"
+)
+
+// supported content encodings
+const (
+	EncodingIdentity = "identity"
+	EncodingGzip     = "gzip"
+	EncodingZstd     = "zstd"
+)
+
+// digestPrefix is a prefix of claim-check digests, it is the only supported algorithm
+const digestPrefix = "sha256:"
+
+// ErrInvalid is returned for payloads that could never be resolved:
+// unsupported encodings, corrupted data and digest mismatches
+var ErrInvalid = errors.New("invalid payload")
+
+// Resolver resolves message payloads: loads claim-check payloads from
+// object store directory, verifies their digests and decompresses them.
+// It is safe for concurrent use
+type Resolver struct {
+	// store is an object store directory. Claim-check messages are invalid if it is nil
+	store   *os.Root
+	maxSize int64
+}
+
+// New creates and returns Resolver from payload config
+func New(cfg *config.PayloadConfig) (*Resolver, error) {
+	r := &Resolver{maxSize: cfg.MaxSize}
+	if cfg.StoreDir != "" {
+		// root doesn't let keys escape store directory
+		store, err := os.OpenRoot(cfg.StoreDir)
+		if err != nil {
+			return nil, fmt.Errorf("could not open payload store: %w", err)
+		}
+		r.store = store
+	}
+	return r, nil
+}
+
+// Close closes object store directory
+func (r *Resolver) Close() error {
+	if r.store == nil {
+		return nil
+	}
+	return r.store.Close()
+}
+
+// Resolve returns decoded payload of message with headers and value.
+// Reading stops when ctx is done. Errors wrapping ErrInvalid mean that
+// message must be skipped, other errors (object store failures, ctx errors) are temporary
+func (r *Resolver) Resolve(ctx context.Context, headers map[string][]byte, value []byte) ([]byte, error) {
+	data := value
+	if key := string(headers[HeaderClaimCheck]); key != "" {
+		claimed, err := r.claim(ctx, key, string(headers[HeaderClaimCheckDigest]))
+		if err != nil {
+			return nil, err
+		}
+		data = claimed
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(string(headers[HeaderContentEncoding])))
+	switch encoding {
+	case "", EncodingIdentity:
+		return data, nil
+	case EncodingGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+		}
+		defer zr.Close() // nolint: errcheck
+		return r.readAll(ctx, zr)
+	case EncodingZstd:
+		zr, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+		}
+		defer zr.Close()
+		return r.readAll(ctx, zr)
+	default:
+		return nil, fmt.Errorf("%w: unsupported content encoding %q", ErrInvalid, encoding)
+	}
+}
+
+// claim reads payload of key from object store and verifies its digest
+func (r *Resolver) claim(ctx context.Context, key, digest string) ([]byte, error) {
+	if r.store == nil {
+		return nil, fmt.Errorf("%w: claim-check is not enabled", ErrInvalid)
+	}
+	want, ok := strings.CutPrefix(digest, digestPrefix)
+	if !ok {
+		return nil, fmt.Errorf("%w: claim-check digest must be %s<hex>", ErrInvalid, digestPrefix)
+	}
+	expected, err := hex.DecodeString(want)
+	if err != nil || len(expected) != sha256.Size {
+		return nil, fmt.Errorf("%w: invalid claim-check digest", ErrInvalid)
+	}
+
+	if !filepath.IsLocal(filepath.FromSlash(key)) {
+		return nil, fmt.Errorf("%w: claim-check key %q is not local path", ErrInvalid, key)
+	}
+	f, err := r.store.Open(filepath.FromSlash(key))
+	if err != nil {
+		// missing object is retried too, producer could be still uploading it
+		// or shared store could be not mounted yet
+		return nil, fmt.Errorf("could not open claim-check object %q: %w", key, err)
+	}
+	defer f.Close() // nolint: errcheck
+
+	data, err := r.readAll(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if subtle.ConstantTimeCompare(sum[:], expected) != 1 {
+		return nil, fmt.Errorf("%w: claim-check object %q digest mismatch", ErrInvalid, key)
+	}
+	return data, nil
+}
+
+// readAll reads at most maxSize bytes until ctx is done, so compressed
+// and stored payloads can't exhaust memory and handling deadline
+func (r *Resolver) readAll(ctx context.Context, reader io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(contextReader{ctx: ctx, r: reader}, r.maxSize+1))
+	if err != nil {
+		if ctx.Err() != nil {
+			// message is retried by broker with new deadline
+			return nil, ctx.Err()
+		}
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			// object store read failure can be temporary
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if int64(len(data)) > r.maxSize {
+		return nil, fmt.Errorf("%w: payload exceeds %d bytes", ErrInvalid, r.maxSize)
+	}
+	return data, nil
+}
+
+// contextReader stops reading when ctx is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
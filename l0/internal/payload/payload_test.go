@@ -0,0 +1,182 @@
+package payload
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+
+	"wb-tech-l0/internal/config"
+)
+
+func newResolver(t *testing.T, dir string) *Resolver {
+	t.Helper()
+	r, err := New(&config.PayloadConfig{StoreDir: dir, MaxSize: 1024})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if err := r.Close(); err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+	})
+	return r
+}
+
+// put stores payload in store directory with key the way producers do
+// and returns its digest for claim-check header
+func put(t *testing.T, dir, key string, data []byte) string {
+	t.Helper()
+	name := filepath.Join(dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(name), 0o750); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := os.WriteFile(name, data, 0o640); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	sum := sha256.Sum256(data)
+	return digestPrefix + hex.EncodeToString(sum[:])
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("gzip Write() error = %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("gzip Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func zstded(t *testing.T, data []byte) []byte {
+	t.Helper()
+	w, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("zstd.NewWriter() error = %v", err)
+	}
+	defer w.Close() // nolint: errcheck
+	return w.EncodeAll(data, nil)
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	r := newResolver(t, dir)
+	order := []byte(`{"order_uid":"test"}`)
+
+	digest := put(t, dir, "orders/test.json.gz", gzipped(t, order))
+	plainDigest := put(t, dir, "plain.json", order)
+
+	tests := []struct {
+		name        string
+		headers     map[string]string
+		value       []byte
+		wantInvalid bool
+		wantRetry   bool
+	}{
+		{name: "Plain", value: order},
+		{name: "Identity", headers: map[string]string{HeaderContentEncoding: "identity"}, value: order},
+		{name: "Gzip", headers: map[string]string{HeaderContentEncoding: "gzip"}, value: gzipped(t, order)},
+		{name: "Zstd", headers: map[string]string{HeaderContentEncoding: "ZSTD"}, value: zstded(t, order)},
+		{
+			name:    "Claim-check",
+			headers: map[string]string{HeaderClaimCheck: "plain.json", HeaderClaimCheckDigest: plainDigest},
+		},
+		{
+			name: "Compressed claim-check",
+			headers: map[string]string{
+				HeaderClaimCheck: "orders/test.json.gz", HeaderClaimCheckDigest: digest, HeaderContentEncoding: "gzip",
+			},
+		},
+		{name: "Unknown encoding", headers: map[string]string{HeaderContentEncoding: "br"}, value: order, wantInvalid: true},
+		{name: "Corrupted gzip", headers: map[string]string{HeaderContentEncoding: "gzip"}, value: order, wantInvalid: true},
+		{
+			name:        "Too large",
+			headers:     map[string]string{HeaderContentEncoding: "gzip"},
+			value:       gzipped(t, bytes.Repeat([]byte("a"), 2048)),
+			wantInvalid: true,
+		},
+		{
+			name:        "Digest mismatch",
+			headers:     map[string]string{HeaderClaimCheck: "plain.json", HeaderClaimCheckDigest: digest},
+			wantInvalid: true,
+		},
+		{
+			name:        "Missing digest",
+			headers:     map[string]string{HeaderClaimCheck: "plain.json"},
+			wantInvalid: true,
+		},
+		{
+			name:      "Missing object is retried",
+			headers:   map[string]string{HeaderClaimCheck: "missing.json", HeaderClaimCheckDigest: plainDigest},
+			wantRetry: true,
+		},
+		{
+			name:        "Path traversal",
+			headers:     map[string]string{HeaderClaimCheck: "../secret.json", HeaderClaimCheckDigest: plainDigest},
+			wantInvalid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := make(map[string][]byte, len(tt.headers))
+			for k, v := range tt.headers {
+				headers[k] = []byte(v)
+			}
+
+			got, err := r.Resolve(context.Background(), headers, tt.value)
+			if tt.wantRetry {
+				if err == nil || errors.Is(err, ErrInvalid) {
+					t.Errorf("Resolve() error = %v, want temporary error", err)
+				}
+				return
+			}
+			if tt.wantInvalid {
+				if !errors.Is(err, ErrInvalid) {
+					t.Errorf("Resolve() error = %v, want %v", err, ErrInvalid)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if !bytes.Equal(got, order) {
+				t.Errorf("Resolve() = %s, want %s", got, order)
+			}
+		})
+	}
+}
+
+func TestResolveWithoutStore(t *testing.T) {
+	r, err := New(&config.PayloadConfig{MaxSize: 1024})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	headers := map[string][]byte{HeaderClaimCheck: []byte("plain.json"), HeaderClaimCheckDigest: []byte("sha256:00")}
+	if _, err := r.Resolve(context.Background(), headers, nil); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Resolve() error = %v, want %v", err, ErrInvalid)
+	}
+}
+
+func TestResolveCanceled(t *testing.T) {
+	r := newResolver(t, t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	headers := map[string][]byte{HeaderContentEncoding: []byte("gzip")}
+	_, err := r.Resolve(ctx, headers, gzipped(t, []byte(`{"order_uid":"test"}`)))
+	if !errors.Is(err, context.Canceled) || errors.Is(err, ErrInvalid) {
+		t.Errorf("Resolve() error = %v, want temporary %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,180 @@
+package pii
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/config"
+	"wb-tech-l0/internal/models"
+)
+
+// masking strategies for config.MaskingConfig.Fields values
+const (
+	// StrategyNone leaves field as is
+	StrategyNone = "none"
+	// StrategyFull replaces field with fixed mask, so even its length is hidden
+	StrategyFull = "full"
+	// StrategyPartial keeps first and last characters
+	StrategyPartial = "partial"
+	// StrategyPhone keeps country code and last 4 digits: +7******1234
+	StrategyPhone = "phone"
+	// StrategyEmail keeps first character of local part and domain: a***@example.com
+	StrategyEmail = "email"
+)
+
+// fullMask replaces values masked with StrategyFull
+const fullMask = "***"
+
+// Masker masks PII fields of models.Delivery in responses
+// for callers without full access role. It never changes given
+// order, so orders shared with cache stay unmasked and every
+// response (from cache or storage) is masked the same way
+type Masker struct {
+	fields          map[string]func(string) string
+	fullAccessRoles []auth.Role
+}
+
+// New creates and returns Masker from masking config
+func New(cfg *config.MaskingConfig) *Masker {
+	m := &Masker{
+		fields:          make(map[string]func(string) string, len(cfg.Fields)),
+		fullAccessRoles: make([]auth.Role, 0, len(cfg.FullAccessRoles)),
+	}
+	for field, strategy := range cfg.Fields {
+		if fn := strategyFunc(strategy); fn != nil {
+			m.fields[field] = fn
+		}
+	}
+	for _, role := range cfg.FullAccessRoles {
+		m.fullAccessRoles = append(m.fullAccessRoles, auth.Role(role))
+	}
+	return m
+}
+
+// masking views returned by View
+const (
+	ViewFull   = "full"
+	ViewMasked = "masked"
+)
+
+// View returns masking view of principal: ViewFull if it has
+// full access role and ViewMasked otherwise. Responses with
+// the same view are equal, so it can be used as cache key part
+func (m *Masker) View(principal *auth.Principal) string {
+	if principal != nil && principal.HasAnyRole(m.fullAccessRoles...) {
+		return ViewFull
+	}
+	return ViewMasked
+}
+
+// Apply returns order shaped for principal. If principal has full access role,
+// order itself is returned. Otherwise it returns masked copy of order
+func (m *Masker) Apply(order *models.Order, principal *auth.Principal) *models.Order {
+	if m.View(principal) == ViewFull {
+		return order
+	}
+
+	// Delivery is stored by value, so copying order is enough to not change original
+	masked := *order
+	masked.Delivery = m.MaskDelivery(order.Delivery)
+	return &masked
+}
+
+// MaskDelivery returns copy of delivery with configured fields masked
+func (m *Masker) MaskDelivery(d models.Delivery) models.Delivery {
+	d.Name = m.mask("name", d.Name)
+	d.Phone = m.mask("phone", d.Phone)
+	d.Zip = m.mask("zip", d.Zip)
+	d.City = m.mask("city", d.City)
+	d.Address = m.mask("address", d.Address)
+	d.Region = m.mask("region", d.Region)
+	d.Email = m.mask("email", d.Email)
+	return d
+}
+
+// MaskOrderJSON returns JSON encoded order with delivery fields masked.
+// Other fields are kept as is, payloads without delivery are returned unchanged.
+// It is used for payloads leaving the service (outbox events), which have no caller role
+func (m *Masker) MaskOrderJSON(payload []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, fmt.Errorf("could not decode order: %w", err)
+	}
+	raw, ok := fields["delivery"]
+	if !ok {
+		return payload, nil
+	}
+
+	var delivery models.Delivery
+	if err := json.Unmarshal(raw, &delivery); err != nil {
+		return nil, fmt.Errorf("could not decode order delivery: %w", err)
+	}
+	masked, err := json.Marshal(m.MaskDelivery(delivery))
+	if err != nil {
+		return nil, fmt.Errorf("could not encode order delivery: %w", err)
+	}
+	fields["delivery"] = masked
+	return json.Marshal(fields)
+}
+
+// mask masks value of field with its configured strategy
+func (m *Masker) mask(field, value string) string {
+	fn, ok := m.fields[field]
+	if !ok || value == "" {
+		return value
+	}
+	return fn(value)
+}
+
+// strategyFunc returns mask function for strategy name.
+// It returns nil for StrategyNone and unknown strategies
+func strategyFunc(strategy string) func(string) string {
+	switch strategy {
+	case StrategyFull:
+		return func(string) string { return fullMask }
+	case StrategyPartial:
+		return maskPartial
+	case StrategyPhone:
+		return maskPhone
+	case StrategyEmail:
+		return maskEmail
+	default:
+		return nil
+	}
+}
+
+// maskPartial keeps first and last characters of value.
+// Values shorter than 3 characters are fully masked
+func maskPartial(value string) string {
+	runes := []rune(value)
+	if len(runes) < 3 {
+		return fullMask
+	}
+	return string(runes[0]) + strings.Repeat("*", len(runes)-2) + string(runes[len(runes)-1])
+}
+
+// maskPhone keeps first 2 characters (+ and country code) and last 4 digits.
+// Too short phones are fully masked
+func maskPhone(value string) string {
+	const keepPrefix, keepSuffix = 2, 4
+
+	runes := []rune(value)
+	if len(runes) <= keepPrefix+keepSuffix {
+		return fullMask
+	}
+	return string(runes[:keepPrefix]) +
+		strings.Repeat("*", len(runes)-keepPrefix-keepSuffix) +
+		string(runes[len(runes)-keepSuffix:])
+}
+
+// maskEmail keeps first character of local part and full domain
+func maskEmail(value string) string {
+	local, domain, found := strings.Cut(value, "@")
+	if !found || local == "" {
+		return fullMask
+	}
+	first := []rune(local)[0]
+	return string(first) + fullMask + "@" + domain
+}
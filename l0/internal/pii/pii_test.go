@@ -0,0 +1,73 @@
+package pii
+
+import (
+	"testing"
+
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/config"
+	"wb-tech-l0/internal/models"
+)
+
+func TestStrategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		value    string
+		want     string
+	}{
+		{name: "Phone", strategy: StrategyPhone, value: "+79991231234", want: "+7******1234"},
+		{name: "Short phone", strategy: StrategyPhone, value: "+71234", want: fullMask},
+		{name: "Email", strategy: StrategyEmail, value: "alice@example.com", want: "a***@example.com"},
+		{name: "Invalid email", strategy: StrategyEmail, value: "alice", want: fullMask},
+		{name: "Partial", strategy: StrategyPartial, value: "Иванов", want: "И****в"},
+		{name: "Short partial", strategy: StrategyPartial, value: "ab", want: fullMask},
+		{name: "Full", strategy: StrategyFull, value: "Lenina 1", want: fullMask},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strategyFunc(tt.strategy)(tt.value); got != tt.want {
+				t.Errorf("mask(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskerApply(t *testing.T) {
+	masker := New(&config.MaskingConfig{
+		Fields:          map[string]string{"phone": StrategyPhone, "email": StrategyEmail},
+		FullAccessRoles: []string{"admin"},
+	})
+
+	order := &models.Order{
+		OrderUID: "uid",
+		Delivery: models.Delivery{Name: "Alice", Phone: "+79991231234", Email: "alice@example.com"},
+	}
+
+	t.Run("Support is masked", func(t *testing.T) {
+		got := masker.Apply(order, &auth.Principal{Roles: []auth.Role{auth.RoleSupport}})
+		if got.Delivery.Phone != "+7******1234" || got.Delivery.Email != "a***@example.com" {
+			t.Errorf("Apply() delivery = %+v, want masked phone and email", got.Delivery)
+		}
+		if got.Delivery.Name != "Alice" {
+			t.Errorf("Apply() name = %q, want unmasked", got.Delivery.Name)
+		}
+		if order.Delivery.Phone != "+79991231234" {
+			t.Errorf("Apply() changed original order")
+		}
+	})
+
+	t.Run("Admin is not masked", func(t *testing.T) {
+		got := masker.Apply(order, &auth.Principal{Roles: []auth.Role{auth.RoleAdmin}})
+		if got.Delivery.Phone != "+79991231234" {
+			t.Errorf("Apply() phone = %q, want unmasked", got.Delivery.Phone)
+		}
+	})
+
+	t.Run("Anonymous is masked", func(t *testing.T) {
+		got := masker.Apply(order, nil)
+		if got.Delivery.Phone != "+7******1234" {
+			t.Errorf("Apply() phone = %q, want masked", got.Delivery.Phone)
+		}
+	})
+}
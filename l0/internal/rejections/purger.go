@@ -0,0 +1,59 @@
+// Package rejections purges rejected broker messages after their retention.
+// Rejections keep raw payloads with customers PII, so they are not kept forever
+package rejections
+
+import (
+	"context"
+	"time"
+
+	"wb-tech-l0/internal/config"
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/storage"
+)
+
+// Purger deletes rejections older than retention
+type Purger struct {
+	cfg   *config.RejectionsConfig
+	store storage.RejectionStorage
+	now   func() time.Time
+	log   logger.Logger
+}
+
+// New creates and returns Purger. It must be started with Run
+func New(cfg *config.RejectionsConfig, store storage.RejectionStorage, log logger.Logger) *Purger {
+	return &Purger{
+		cfg:   cfg,
+		store: store,
+		now:   time.Now,
+		log:   log,
+	}
+}
+
+// Run purges rejections older than Retention every Retention / 10.
+// It blocks until ctx is done
+func (p *Purger) Run(ctx context.Context) {
+	p.log.Debug("Starting rejections purge loop")
+	defer p.log.Debug("Rejections purge loop exited")
+
+	purge := time.NewTicker(p.cfg.Retention / 10)
+	defer purge.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-purge.C:
+			p.Purge(ctx)
+		}
+	}
+}
+
+// Purge deletes rejections older than Retention once
+func (p *Purger) Purge(ctx context.Context) {
+	purged, err := p.store.PurgeRejections(ctx, p.now().Add(-p.cfg.Retention))
+	if err != nil {
+		p.log.Warn("Failed to purge rejections", logger.Error(err))
+		return
+	}
+	p.log.Debug("Rejections purged", logger.Field("count", purged))
+}
@@ -0,0 +1,36 @@
+package rejections
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/config"
+	noplogger "wb-tech-l0/internal/logger/nop"
+	"wb-tech-l0/internal/storage"
+)
+
+// fakeStore records purge requests
+type fakeStore struct {
+	storage.RejectionStorage
+	before []time.Time
+}
+
+func (s *fakeStore) PurgeRejections(_ context.Context, before time.Time) (int64, error) {
+	s.before = append(s.before, before)
+	return 1, nil
+}
+
+func TestPurge(t *testing.T) {
+	now := time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC)
+	store := &fakeStore{}
+	p := New(&config.RejectionsConfig{Retention: 720 * time.Hour}, store, noplogger.New())
+	p.now = func() time.Time { return now }
+
+	p.Purge(context.Background())
+
+	want := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	if len(store.before) != 1 || !store.before[0].Equal(want) {
+		t.Errorf("PurgeRejections() calls = %v, want [%v]", store.before, want)
+	}
+}
@@ -0,0 +1,296 @@
+// Package replay runs one-off replays of broker stream ranges in background.
+// Replays read messages by separate consumer without consumer group, so main
+// subscription offsets are not changed. Only one replay can run at a time.
+//
+// Replayed messages are handled as usual, so stored orders with the same or newer
+// version are not overwritten. Replays recover orders missing in storage: rejected
+// before rules or schemas were fixed, or skipped by offsets. Stored orders are
+// corrected by publishing them with greater version.
+package replay
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"wb-tech-l0/internal/broker"
+	"wb-tech-l0/internal/logger"
+)
+
+// Start errors
+var (
+	// ErrRunning is returned by Start if another replay is running
+	ErrRunning = errors.New("replay is already running")
+	// ErrPaused is returned by Start while replays are paused with consumer
+	ErrPaused = errors.New("replays are paused")
+)
+
+// maxJobs is a number of kept replays states
+const maxJobs = 20
+
+// Replay states
+const (
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	StateFailed    = "failed"
+	StateCanceled  = "canceled"
+)
+
+// Job is a state of replay.
+// @Description Replay of broker stream range.
+type Job struct {
+	// Replay identifier
+	ID string `json:"id"`
+	// Replayed stream
+	Stream string `json:"stream"`
+	// Replay start
+	From Target `json:"from"`
+	// Replay end (exclusive), empty means partitions ends at replay start
+	To Target `json:"to"`
+	// Replay state: running, completed, failed or canceled
+	State string `json:"state"`
+	// Number of handled messages
+	Messages int64 `json:"messages"`
+	// Replay error
+	Error string `json:"error,omitempty"`
+	// Replay start date
+	StartedAt time.Time `json:"started_at"`
+	// Replay finish date
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Target is a replay range bound: timestamp or explicit offsets of partitions.
+// @Description Position in stream partitions: timestamp or explicit offsets.
+type Target struct {
+	// Selects the first messages at or after timestamp in every partition
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	// Explicit offsets of partitions
+	Offsets map[int]int64 `json:"offsets,omitempty"`
+}
+
+// OffsetTarget converts Target to broker.OffsetTarget
+func (t Target) OffsetTarget() broker.OffsetTarget {
+	target := broker.OffsetTarget{Offsets: t.Offsets}
+	if t.Timestamp != nil {
+		target.Timestamp = *t.Timestamp
+	}
+	return target
+}
+
+// job is a Job with its progress and cancellation
+type job struct {
+	Job
+	messages atomic.Int64
+	cancel   context.CancelFunc
+	// done is closed when replay handlers exited
+	done chan struct{}
+}
+
+// Runner runs replays of stream with handler
+type Runner struct {
+	replayer broker.Replayer
+	stream   broker.Stream
+	handler  func(message *broker.Message) error
+
+	mu     sync.Mutex
+	jobs   []*job
+	nextID int
+	paused bool
+
+	ctx context.Context
+	log logger.Logger
+}
+
+// New creates and returns Runner of stream replays.
+// Replays are canceled when ctx is done
+func New(ctx context.Context, log logger.Logger, replayer broker.Replayer, stream broker.Stream, handler func(message *broker.Message) error) *Runner {
+	return &Runner{
+		replayer: replayer,
+		stream:   stream,
+		handler:  handler,
+		ctx:      ctx,
+		log:      log,
+	}
+}
+
+// Start starts replay of range in background and returns its state.
+// It returns ErrRunning if another replay is running and ErrPaused if replays are paused
+func (r *Runner) Start(from, to Target) (Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.paused {
+		return Job{}, ErrPaused
+	}
+	if len(r.jobs) > 0 && r.jobs[len(r.jobs)-1].State == StateRunning {
+		return Job{}, ErrRunning
+	}
+
+	r.nextID++
+	ctx, cancel := context.WithCancel(r.ctx)
+	j := &job{
+		Job: Job{
+			ID:        strconv.Itoa(r.nextID),
+			Stream:    string(r.stream),
+			From:      from,
+			To:        to,
+			State:     StateRunning,
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	r.jobs = append(r.jobs, j)
+	if len(r.jobs) > maxJobs {
+		r.jobs = slices.Delete(r.jobs, 0, len(r.jobs)-maxJobs)
+	}
+
+	go r.run(ctx, j)
+
+	return r.snapshot(j), nil
+}
+
+// run replays job range and records its result
+func (r *Runner) run(ctx context.Context, j *job) {
+	defer close(j.done)
+	defer j.cancel()
+
+	log := r.log.With(logger.Field("replay_id", j.ID), logger.Field("stream", j.Stream))
+	log.Info("Replay started")
+
+	err := r.replayer.Replay(ctx, r.stream, j.From.OffsetTarget(), j.To.OffsetTarget(), func(message *broker.Message) error {
+		// replayed messages were accepted when they were live, so their signatures age is not checked
+		message.Reread = true
+		if err := r.handler(message); err != nil {
+			return err
+		}
+		j.messages.Add(1)
+		return nil
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	finished := time.Now()
+	j.FinishedAt = &finished
+	switch {
+	case err == nil:
+		j.State = StateCompleted
+		log.Info("Replay completed", logger.Field("messages", j.messages.Load()))
+	case ctx.Err() != nil:
+		j.State = StateCanceled
+		log.Info("Replay canceled", logger.Field("messages", j.messages.Load()))
+	default:
+		j.State = StateFailed
+		j.Error = err.Error()
+		log.Warn("Replay failed", logger.Field("messages", j.messages.Load()), logger.Error(err))
+	}
+}
+
+// Jobs returns states of running and last finished replays, newest first
+func (r *Runner) Jobs() []Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	jobs := make([]Job, 0, len(r.jobs))
+	for i := len(r.jobs) - 1; i >= 0; i-- {
+		jobs = append(jobs, r.snapshot(r.jobs[i]))
+	}
+	return jobs
+}
+
+// Job returns state of replay by ID
+func (r *Runner) Job(id string) (Job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, j := range r.jobs {
+		if j.ID == id {
+			return r.snapshot(j), true
+		}
+	}
+	return Job{}, false
+}
+
+// Cancel cancels replay by ID. Finished replays are not changed.
+// It returns false if there is no such replay
+func (r *Runner) Cancel(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, j := range r.jobs {
+		if j.ID == id {
+			j.cancel()
+			return true
+		}
+	}
+	return false
+}
+
+// Running returns states of running replays
+func (r *Runner) Running() []Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	jobs := []Job{}
+	for _, j := range r.jobs {
+		if j.State == StateRunning {
+			jobs = append(jobs, r.snapshot(j))
+		}
+	}
+	return jobs
+}
+
+// Pause cancels running replays and makes Start return ErrPaused until Resume.
+// Replays are one-off, so canceled ones are not continued on Resume.
+// Handlers of canceled replays could still run, Wait waits for them
+func (r *Runner) Pause() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.paused = true
+	for _, j := range r.jobs {
+		if j.State == StateRunning {
+			j.cancel()
+		}
+	}
+}
+
+// Resume allows starting replays again
+func (r *Runner) Resume() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.paused = false
+}
+
+// Wait waits for handlers of all replays to exit or ctx to be done
+func (r *Runner) Wait(ctx context.Context) error {
+	r.mu.Lock()
+	var running []*job
+	for _, j := range r.jobs {
+		if j.State == StateRunning {
+			running = append(running, j)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, j := range running {
+		select {
+		case <-j.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// snapshot returns copy of job state. r.mu must be held
+func (r *Runner) snapshot(j *job) Job {
+	s := j.Job
+	s.Messages = j.messages.Load()
+	return s
+}
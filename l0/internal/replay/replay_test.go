@@ -0,0 +1,143 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/broker"
+	noplogger "wb-tech-l0/internal/logger/nop"
+)
+
+// fakeReplayer replays count messages, then waits for release or ctx
+type fakeReplayer struct {
+	count   int
+	release chan error
+}
+
+func (f *fakeReplayer) Replay(ctx context.Context, _ broker.Stream, _, _ broker.OffsetTarget, handler func(message *broker.Message) error) error {
+	for i := 0; i < f.count; i++ {
+		if err := handler(&broker.Message{Offset: int64(i)}); err != nil {
+			return err
+		}
+	}
+	select {
+	case err := <-f.release:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitState waits for replay to leave running state
+func waitState(t *testing.T, r *Runner, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		job, ok := r.Job(id)
+		if !ok {
+			t.Fatalf("Job(%q) not found", id)
+		}
+		if job.State != StateRunning {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("replay %q is still running", id)
+	return Job{}
+}
+
+func TestRunner(t *testing.T) {
+	replayer := &fakeReplayer{count: 3, release: make(chan error)}
+	handled := 0
+	r := New(context.Background(), noplogger.New(), replayer, broker.StreamOrders, func(message *broker.Message) error {
+		if !message.Reread {
+			t.Error("replayed message is not marked reread")
+		}
+		handled++
+		return nil
+	})
+
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	first, err := r.Start(Target{Timestamp: &from}, Target{})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if _, err := r.Start(Target{Timestamp: &from}, Target{}); !errors.Is(err, ErrRunning) {
+		t.Fatalf("second Start() error = %v, want %v", err, ErrRunning)
+	}
+
+	replayer.release <- nil
+	job := waitState(t, r, first.ID)
+	if job.State != StateCompleted || job.Messages != 3 || handled != 3 || job.FinishedAt == nil {
+		t.Fatalf("completed job = %+v, handled = %d", job, handled)
+	}
+
+	failed, err := r.Start(Target{Offsets: map[int]int64{0: 1}}, Target{})
+	if err != nil {
+		t.Fatalf("Start() after completion error = %v", err)
+	}
+	replayer.release <- errors.New("broken")
+	if job := waitState(t, r, failed.ID); job.State != StateFailed || job.Error != "broken" {
+		t.Fatalf("failed job = %+v", job)
+	}
+
+	canceled, err := r.Start(Target{Offsets: map[int]int64{0: 1}}, Target{})
+	if err != nil {
+		t.Fatalf("Start() after failure error = %v", err)
+	}
+	if !r.Cancel(canceled.ID) {
+		t.Fatalf("Cancel(%q) = false", canceled.ID)
+	}
+	if job := waitState(t, r, canceled.ID); job.State != StateCanceled {
+		t.Fatalf("canceled job = %+v", job)
+	}
+
+	jobs := r.Jobs()
+	if len(jobs) != 3 || jobs[0].ID != canceled.ID || jobs[2].ID != first.ID {
+		t.Fatalf("Jobs() = %+v, want newest first", jobs)
+	}
+	if r.Cancel("unknown") {
+		t.Fatal("Cancel(unknown) = true")
+	}
+}
+
+func TestRunnerPause(t *testing.T) {
+	replayer := &fakeReplayer{count: 1, release: make(chan error)}
+	r := New(context.Background(), noplogger.New(), replayer, broker.StreamOrders, func(*broker.Message) error { return nil })
+
+	job, err := r.Start(Target{Offsets: map[int]int64{0: 1}}, Target{})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if running := r.Running(); len(running) != 1 || running[0].ID != job.ID {
+		t.Fatalf("Running() = %+v, want started replay", running)
+	}
+
+	r.Pause()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.Wait(ctx); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if job := waitState(t, r, job.ID); job.State != StateCanceled {
+		t.Fatalf("paused job = %+v, want canceled", job)
+	}
+	if running := r.Running(); len(running) != 0 {
+		t.Fatalf("Running() after pause = %+v", running)
+	}
+	if _, err := r.Start(Target{Offsets: map[int]int64{0: 1}}, Target{}); !errors.Is(err, ErrPaused) {
+		t.Fatalf("Start() while paused error = %v, want %v", err, ErrPaused)
+	}
+
+	r.Resume()
+	resumed, err := r.Start(Target{Offsets: map[int]int64{0: 1}}, Target{})
+	if err != nil {
+		t.Fatalf("Start() after resume error = %v", err)
+	}
+	replayer.release <- nil
+	if job := waitState(t, r, resumed.ID); job.State != StateCompleted {
+		t.Fatalf("resumed job = %+v", job)
+	}
+}
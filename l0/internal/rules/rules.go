@@ -0,0 +1,174 @@
+package rules
+
+import (
+	"fmt"
+	"strings"
+
+	"wb-tech-l0/internal/config"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/money"
+)
+
+// actions for config.RulesConfig values
+const (
+	// ActionReject rejects order violating rule
+	ActionReject = "reject"
+	// ActionWarn accepts order violating rule and reports violation as warning
+	ActionWarn = "warn"
+	// ActionIgnore doesn't check rule
+	ActionIgnore = "ignore"
+)
+
+// rule IDs. They are stable, so they are used in config and violation reports
+const (
+	// RuleGoodsTotal checks that payment goods total is sum of items total prices
+	RuleGoodsTotal = "goods_total"
+	// RuleAmount checks that payment amount is goods total plus delivery cost and custom fee
+	RuleAmount = "amount"
+	// RuleItemTotal checks that item total price is its price with sale applied
+	RuleItemTotal = "item_total"
+)
+
+// Violation is a single violated rule
+type Violation struct {
+	// Rule is a violated rule ID
+	Rule string `json:"rule"`
+	// Field is a path of inconsistent field, for example items[0].total_price
+	Field string `json:"field"`
+	// Message describes expected and actual values
+	Message string `json:"message"`
+}
+
+// String returns violation in "rule: field: message" form
+func (v Violation) String() string {
+	return v.Rule + ": " + v.Field + ": " + v.Message
+}
+
+// Error is returned for orders violating rejecting rules
+type Error struct {
+	// Violations are violations of rejecting rules
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, v.String())
+	}
+	return "order violates business rules: " + strings.Join(parts, "; ")
+}
+
+// rule checks order and returns its violations.
+// Order is already validated by struct tags, so required fields are set
+type rule struct {
+	id    string
+	check func(o *models.Order) []Violation
+}
+
+// all are all supported rules in check order
+var all = []rule{
+	{id: RuleItemTotal, check: checkItemTotal},
+	{id: RuleGoodsTotal, check: checkGoodsTotal},
+	{id: RuleAmount, check: checkAmount},
+}
+
+// Validator checks orders consistency with business rules.
+// Unlike struct tags validation, rules check relations between fields.
+// It is shared by broker handler and HTTP API, so orders are
+// checked the same way regardless of their source
+type Validator struct {
+	actions map[string]string
+}
+
+// New creates and returns Validator from rules config.
+// Rules missing in config get its default action
+func New(cfg *config.RulesConfig) *Validator {
+	v := &Validator{actions: make(map[string]string, len(all))}
+	for _, r := range all {
+		action, ok := cfg.Actions[r.id]
+		if !ok {
+			action = cfg.DefaultAction
+		}
+		v.actions[r.id] = action
+	}
+	return v
+}
+
+// Validate checks order with all not ignored rules.
+// It returns violations of warning rules and *Error
+// if order violates any rejecting rule
+func (v *Validator) Validate(o *models.Order) ([]Violation, error) {
+	var warnings, rejected []Violation
+	for _, r := range all {
+		action := v.actions[r.id]
+		if action == ActionIgnore {
+			continue
+		}
+		violations := r.check(o)
+		if action == ActionWarn {
+			warnings = append(warnings, violations...)
+		} else {
+			rejected = append(rejected, violations...)
+		}
+	}
+
+	if len(rejected) > 0 {
+		return warnings, &Error{Violations: rejected}
+	}
+	return warnings, nil
+}
+
+// IDs returns IDs of violated rules, for logging
+func IDs(violations []Violation) []string {
+	ids := make([]string, 0, len(violations))
+	for _, v := range violations {
+		ids = append(ids, v.Rule)
+	}
+	return ids
+}
+
+func checkItemTotal(o *models.Order) []Violation {
+	var violations []Violation
+	for i, item := range o.Items {
+		// sale is in percents, so total price can be fractional.
+		// any rounding of exact total is accepted
+		exact := *item.Price * (100 - *item.Sale)
+		if diff := *item.TotalPrice*100 - exact; diff <= -100 || diff >= 100 {
+			violations = append(violations, Violation{
+				Rule:    RuleItemTotal,
+				Field:   fmt.Sprintf("items[%d].total_price", i),
+				Message: fmt.Sprintf("expected %d with price %d and sale %d%%, got %d", exact/100, *item.Price, *item.Sale, *item.TotalPrice),
+			})
+		}
+	}
+	return violations
+}
+
+func checkGoodsTotal(o *models.Order) []Violation {
+	sum := money.New(0, o.Payment.Currency)
+	for i := range o.Items {
+		sum = sum.Add(o.Payment.ItemAmounts(&o.Items[i]).TotalPrice)
+	}
+	goodsTotal := o.Payment.Amounts().GoodsTotal
+	if goodsTotal == sum {
+		return nil
+	}
+	return []Violation{{
+		Rule:    RuleGoodsTotal,
+		Field:   "payment.goods_total",
+		Message: fmt.Sprintf("expected %s as sum of items total prices, got %s", sum.Decimal(), goodsTotal.Decimal()),
+	}}
+}
+
+func checkAmount(o *models.Order) []Violation {
+	a := o.Payment.Amounts()
+	expected := a.GoodsTotal.Add(a.DeliveryCost, a.CustomFee)
+	if a.Amount == expected {
+		return nil
+	}
+	return []Violation{{
+		Rule:    RuleAmount,
+		Field:   "payment.amount",
+		Message: fmt.Sprintf("expected %s as goods total plus delivery cost and custom fee, got %s", expected.Decimal(), a.Amount.Decimal()),
+	}}
+}
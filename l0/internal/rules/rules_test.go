@@ -0,0 +1,96 @@
+package rules
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"wb-tech-l0/internal/config"
+	"wb-tech-l0/internal/models"
+)
+
+func ptr(v int) *int { return &v }
+
+// newOrder returns consistent order with single item
+func newOrder() *models.Order {
+	return &models.Order{
+		Payment: models.Payment{
+			Amount:       ptr(1817),
+			DeliveryCost: ptr(1500),
+			GoodsTotal:   ptr(317),
+			CustomFee:    ptr(0),
+		},
+		Items: []models.Item{{Price: ptr(453), Sale: ptr(30), TotalPrice: ptr(317)}},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name         string
+		actions      map[string]string
+		modify       func(o *models.Order)
+		wantRejected []string
+		wantWarnings []string
+	}{
+		{
+			name:   "Consistent order",
+			modify: func(*models.Order) {},
+		},
+		{
+			name: "Rounded up item total",
+			modify: func(o *models.Order) {
+				o.Items[0].TotalPrice = ptr(318)
+				o.Payment.GoodsTotal = ptr(318)
+				o.Payment.Amount = ptr(1818)
+			},
+		},
+		{
+			name:         "Wrong item total",
+			modify:       func(o *models.Order) { o.Items[0].TotalPrice = ptr(453) },
+			wantRejected: []string{RuleItemTotal, RuleGoodsTotal},
+		},
+		{
+			name:         "Wrong amount",
+			modify:       func(o *models.Order) { o.Payment.Amount = ptr(317) },
+			wantRejected: []string{RuleAmount},
+		},
+		{
+			name:         "Warning rule",
+			actions:      map[string]string{RuleAmount: ActionWarn},
+			modify:       func(o *models.Order) { o.Payment.Amount = ptr(317) },
+			wantWarnings: []string{RuleAmount},
+		},
+		{
+			name:    "Ignored rule",
+			actions: map[string]string{RuleGoodsTotal: ActionIgnore},
+			modify: func(o *models.Order) {
+				o.Payment.GoodsTotal = ptr(0)
+				o.Payment.Amount = ptr(1500)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(&config.RulesConfig{DefaultAction: ActionReject, Actions: tt.actions})
+			order := newOrder()
+			tt.modify(order)
+
+			warnings, err := v.Validate(order)
+
+			var rejected []string
+			var rulesErr *Error
+			if errors.As(err, &rulesErr) {
+				rejected = IDs(rulesErr.Violations)
+			} else if err != nil {
+				t.Fatalf("Validate() error = %v, want *Error", err)
+			}
+			if !slices.Equal(rejected, tt.wantRejected) {
+				t.Errorf("Validate() rejected = %v, want %v", rejected, tt.wantRejected)
+			}
+			if got := IDs(warnings); !slices.Equal(got, tt.wantWarnings) {
+				t.Errorf("Validate() warnings = %v, want %v", got, tt.wantWarnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package schema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// FieldError is a single schema violation
+type FieldError struct {
+	// Path is a JSON path of invalid value, for example payment.amount or items[0].price
+	Path string `json:"path"`
+	// Keyword is a violated schema keyword, for example required
+	Keyword string `json:"keyword"`
+	// Message describes violation
+	Message string `json:"message"`
+}
+
+// printer formats violations messages
+var printer = message.NewPrinter(language.English)
+
+// compileSchema compiles JSON Schema document named name.
+// Formats are asserted, so schemas never silently accept more than they declare
+func compileSchema(name string, raw []byte) (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	c := jsonschema.NewCompiler()
+	c.AssertFormat()
+	if err := c.AddResource(name, doc); err != nil {
+		return nil, err
+	}
+	return c.Compile(name)
+}
+
+// validate validates decoded JSON document with schema and returns its violations
+func validate(s *jsonschema.Schema, doc any) ([]FieldError, error) {
+	err := s.Validate(doc)
+	if err == nil {
+		return nil, nil
+	}
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return nil, err
+	}
+	return fieldErrors(validationErr, doc, nil), nil
+}
+
+// fieldErrors appends leaf violations of err to errs. Missing required properties
+// are reported as violations of properties themselves
+func fieldErrors(err *jsonschema.ValidationError, doc any, errs []FieldError) []FieldError {
+	if len(err.Causes) > 0 {
+		for _, cause := range err.Causes {
+			errs = fieldErrors(cause, doc, errs)
+		}
+		return errs
+	}
+
+	path := jsonPath(doc, err.InstanceLocation)
+	if required, ok := err.ErrorKind.(*kind.Required); ok {
+		for _, property := range required.Missing {
+			errs = append(errs, FieldError{Path: joinPath(path, property), Keyword: "required", Message: "is required"})
+		}
+		return errs
+	}
+
+	keyword := ""
+	if keywords := err.ErrorKind.KeywordPath(); len(keywords) > 0 {
+		keyword = keywords[len(keywords)-1]
+	}
+	return append(errs, FieldError{Path: path, Keyword: keyword, Message: err.ErrorKind.LocalizedString(printer)})
+}
+
+// jsonPath returns path of value at location in doc: payment.amount or items[0].price
+func jsonPath(doc any, location []string) string {
+	path := ""
+	for _, token := range location {
+		if array, ok := doc.([]any); ok {
+			i, _ := strconv.Atoi(token)
+			path += fmt.Sprintf("[%d]", i)
+			if i < len(array) {
+				doc = array[i]
+			}
+			continue
+		}
+		path = joinPath(path, token)
+		if object, ok := doc.(map[string]any); ok {
+			doc = object[token]
+		}
+	}
+	return path
+}
+
+// joinPath appends object property to path
+func joinPath(path, property string) string {
+	if path == "" {
+		return property
+	}
+	return path + "." + property
+}
@@ -0,0 +1,219 @@
+package schema
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// files are message schemas named <subject>.v<version>.json
+//
+//go:embed schemas/*.json
+var files embed.FS
+
+// HeaderVersion is a message header with schema version of message value.
+// Messages without it have the oldest subject version
+const HeaderVersion = "schema-version"
+
+// Order is a subject of orders messages schemas
+const Order = "order"
+
+// ErrUnknownVersion is returned for versions without schema
+var ErrUnknownVersion = errors.New("unknown schema version")
+
+// ValidationError is returned for messages not matching their schema
+type ValidationError struct {
+	// Subject is a schema subject
+	Subject string
+	// Version is a schema version
+	Version int
+	// Errors are schema violations
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		parts = append(parts, fe.Path+": "+fe.Message)
+	}
+	return fmt.Sprintf("message doesn't match %s schema v%d: %s", e.Subject, e.Version, strings.Join(parts, "; "))
+}
+
+// Subject describes versions of subject schemas
+type Subject struct {
+	// Subject name
+	Name string `json:"name"`
+	// Available versions, oldest first
+	Versions []int `json:"versions"`
+	// Current version, older ones are upcasted to it
+	Current int `json:"current"`
+}
+
+// version is a compiled schema of subject version
+type version struct {
+	raw    []byte
+	schema *jsonschema.Schema
+}
+
+// Registry keeps versioned message schemas embedded in binary.
+// It validates messages with schema of their version and upcasts
+// older versions to current one, so application works with current
+// models only and producers can migrate at their own pace
+type Registry struct {
+	subjects map[string]map[int]*version
+}
+
+// New creates and returns Registry with embedded schemas.
+// It returns error if any schema is invalid or older
+// version has no upcaster to the next one
+func New() (*Registry, error) {
+	r := &Registry{subjects: make(map[string]map[int]*version)}
+
+	err := fs.WalkDir(files, "schemas", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		subject, v, err := parseFileName(path.Base(name))
+		if err != nil {
+			return err
+		}
+		raw, err := files.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		compiled, err := compileSchema(name, raw)
+		if err != nil {
+			return fmt.Errorf("invalid schema %s: %w", name, err)
+		}
+		if r.subjects[subject] == nil {
+			r.subjects[subject] = make(map[int]*version)
+		}
+		r.subjects[subject][v] = &version{raw: raw, schema: compiled}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not load schemas: %w", err)
+	}
+
+	// every version except current must be upcasted to the next one
+	for _, s := range r.Subjects() {
+		for _, v := range s.Versions[:len(s.Versions)-1] {
+			if upcasters[s.Name][v] == nil {
+				return nil, fmt.Errorf("no upcaster of %s schema from v%d to v%d", s.Name, v, v+1)
+			}
+			if _, ok := r.subjects[s.Name][v+1]; !ok {
+				return nil, fmt.Errorf("no %s schema v%d after v%d", s.Name, v+1, v)
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// parseFileName parses <subject>.v<version>.json schema file name
+func parseFileName(name string) (string, int, error) {
+	base, ok := strings.CutSuffix(name, ".json")
+	subject, v, found := strings.Cut(base, ".v")
+	if !ok || !found {
+		return "", 0, fmt.Errorf("schema file name %q is not <subject>.v<version>.json", name)
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return "", 0, fmt.Errorf("schema file name %q has invalid version", name)
+	}
+	return subject, n, nil
+}
+
+// Subjects returns all subjects sorted by name
+func (r *Registry) Subjects() []Subject {
+	subjects := make([]Subject, 0, len(r.subjects))
+	for name, versions := range r.subjects {
+		s := Subject{Name: name, Versions: slices.Sorted(maps.Keys(versions))}
+		s.Current = s.Versions[len(s.Versions)-1]
+		subjects = append(subjects, s)
+	}
+	slices.SortFunc(subjects, func(a, b Subject) int { return strings.Compare(a.Name, b.Name) })
+	return subjects
+}
+
+// Current returns current version of subject. It returns 0 for unknown subjects
+func (r *Registry) Current(subject string) int {
+	versions, ok := r.subjects[subject]
+	if !ok {
+		return 0
+	}
+	return slices.Max(slices.Collect(maps.Keys(versions)))
+}
+
+// Schema returns JSON Schema document of subject version
+func (r *Registry) Schema(subject string, v int) ([]byte, bool) {
+	compiled, ok := r.subjects[subject][v]
+	if !ok {
+		return nil, false
+	}
+	return compiled.raw, true
+}
+
+// Decode validates JSON data with subject schema of given version,
+// upcasts it to current version and unmarshals result into v.
+// Empty version means the oldest one. It returns ErrUnknownVersion
+// for unknown versions and *ValidationError for invalid data
+func (r *Registry) Decode(subject, headerVersion string, data []byte, v any) error {
+	versions, ok := r.subjects[subject]
+	if !ok {
+		return fmt.Errorf("unknown schema subject %q", subject)
+	}
+
+	from := slices.Min(slices.Collect(maps.Keys(versions)))
+	if headerVersion != "" {
+		n, err := strconv.Atoi(headerVersion)
+		if err != nil || versions[n] == nil {
+			return fmt.Errorf("%w: %s v%s", ErrUnknownVersion, subject, headerVersion)
+		}
+		from = n
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// numbers are kept as is, so integers are not converted to floats
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	errs, err := validate(versions[from].schema, doc)
+	if err != nil {
+		return fmt.Errorf("could not validate %s v%d: %w", subject, from, err)
+	}
+	if len(errs) > 0 {
+		return &ValidationError{Subject: subject, Version: from, Errors: errs}
+	}
+
+	// schema guarantees object on top level if upcasting is needed
+	current := slices.Max(slices.Collect(maps.Keys(versions)))
+	for n := from; n < current; n++ {
+		object, ok := doc.(map[string]any)
+		if !ok {
+			return fmt.Errorf("could not upcast %s v%d: message is not object", subject, n)
+		}
+		if err := upcasters[subject][n](object); err != nil {
+			return fmt.Errorf("could not upcast %s v%d: %w", subject, n, err)
+		}
+	}
+
+	upcasted, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("could not encode upcasted message: %w", err)
+	}
+	return json.Unmarshal(upcasted, v)
+}
@@ -0,0 +1,139 @@
+package schema
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"wb-tech-l0/internal/models"
+)
+
+// orderV1 is an order message without version, with lowercase currency and uppercase locale
+const orderV1 = `{
+	"order_uid": "b563feb7b2b84b6test",
+	"track_number": "WBILMTESTTRACK",
+	"entry": "WBIL",
+	"delivery": {
+		"name": "Test Testov", "phone": "+9720000000", "zip": "2639809", "city": "Kiryat Mozkin",
+		"address": "Ploshad Mira 15", "region": "Kraiot", "email": "test@gmail.com"
+	},
+	"payment": {
+		"transaction": "b563feb7b2b84b6test", "request_id": "", "currency": "usd", "provider": "wbpay",
+		"amount": 1817, "payment_dt": 1637907727, "bank": "alpha", "delivery_cost": 1500, "goods_total": 317, "custom_fee": 0
+	},
+	"items": [{
+		"chrt_id": 9934930, "track_number": "WBILMTESTTRACK", "price": 453, "rid": "ab4219087a764ae0btest",
+		"name": "Mascaras", "sale": 30, "size": "0", "total_price": 317, "nm_id": 2389212, "brand": "Vivienne Sabo", "status": 202
+	}],
+	"locale": "EN",
+	"internal_signature": "",
+	"customer_id": "test",
+	"delivery_service": "meest",
+	"shardkey": "9",
+	"sm_id": 99,
+	"date_created": "2021-11-26T06:22:19Z",
+	"oof_shard": "1"
+}`
+
+func TestNew(t *testing.T) {
+	r, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	subjects := r.Subjects()
+	if len(subjects) != 1 || subjects[0].Name != Order || subjects[0].Current != 2 {
+		t.Errorf("Subjects() = %+v, want order with current version 2", subjects)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	r, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	orderV2 := strings.NewReplacer(`"usd"`, `"USD"`, `"EN"`, `"en"`, `"oof_shard": "1"`, `"oof_shard": "1", "version": 3`).Replace(orderV1)
+
+	tests := []struct {
+		name        string
+		version     string
+		data        string
+		wantVersion int64
+		wantErr     error
+	}{
+		{name: "Without header is v1", version: "", data: orderV1, wantVersion: 1},
+		{name: "Upcasted v1", version: "1", data: orderV1, wantVersion: 1},
+		{name: "Current v2", version: "2", data: orderV2, wantVersion: 3},
+		{name: "v2 without header keeps version", version: "", data: orderV2, wantVersion: 3},
+		{name: "v1 is not valid v2", version: "2", data: orderV1, wantErr: &ValidationError{}},
+		{name: "v2 without version", version: "2", data: strings.Replace(orderV2, `, "version": 3`, "", 1), wantErr: &ValidationError{}},
+		{name: "v2 with zero version", version: "2", data: strings.Replace(orderV2, `"version": 3`, `"version": 0`, 1), wantErr: &ValidationError{}},
+		{name: "Unknown version", version: "10", data: orderV2, wantErr: ErrUnknownVersion},
+		{name: "Not JSON", version: "2", data: "{", wantErr: errors.New("invalid JSON")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var order models.Order
+			err := r.Decode(Order, tt.version, []byte(tt.data), &order)
+
+			var validationErr *ValidationError
+			switch {
+			case tt.wantErr == nil:
+				if err != nil {
+					t.Fatalf("Decode() error = %v", err)
+				}
+			case errors.As(tt.wantErr, &validationErr):
+				if !errors.As(err, &validationErr) {
+					t.Fatalf("Decode() error = %v, want validation error", err)
+				}
+				return
+			case errors.Is(tt.wantErr, ErrUnknownVersion):
+				if !errors.Is(err, ErrUnknownVersion) {
+					t.Fatalf("Decode() error = %v, want %v", err, ErrUnknownVersion)
+				}
+				return
+			default:
+				if err == nil {
+					t.Fatalf("Decode() error = nil, want error")
+				}
+				return
+			}
+
+			if order.Payment.Currency != "USD" || order.Locale != "en" || order.Version != tt.wantVersion {
+				t.Errorf("Decode() currency = %s, locale = %s, version = %d, want USD, en, %d",
+					order.Payment.Currency, order.Locale, order.Version, tt.wantVersion)
+			}
+			if err := models.NewValidator().Struct(order); err != nil {
+				t.Errorf("decoded order is invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	r, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	data := strings.NewReplacer(`"amount": 1817`, `"amount": "1817"`, `"sale": 30`, `"sale": 130`, `"entry": "WBIL",`, ``).Replace(orderV1)
+
+	err = r.Decode(Order, "1", []byte(data), &models.Order{})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Decode() error = %v, want validation error", err)
+	}
+
+	got := make(map[string]string, len(validationErr.Errors))
+	for _, fe := range validationErr.Errors {
+		got[fe.Path] = fe.Keyword
+	}
+	want := map[string]string{"entry": "required", "payment.amount": "type", "items[0].sale": "maximum"}
+	for path, keyword := range want {
+		if got[path] != keyword {
+			t.Errorf("error of %s = %q, want %q (all errors: %v)", path, got[path], keyword, validationErr.Errors)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d errors, want %d: %v", len(got), len(want), validationErr.Errors)
+	}
+}
@@ -0,0 +1,33 @@
+package schema
+
+import "strings"
+
+// Upcaster converts message of schema version to the next version in place
+type Upcaster func(doc map[string]any) error
+
+// upcasters maps subjects to upcasters from version to the next one
+var upcasters = map[string]map[int]Upcaster{
+	Order: {
+		1: upcastOrderV1,
+	},
+}
+
+// upcastOrderV1 converts order v1 to v2: sets version of unversioned
+// orders to 1, so they can be created but not corrected, uppercases currency
+// and lowercases locale language.
+// Messages without schema-version header are v1, so it must
+// leave v2 orders sent without header unchanged
+func upcastOrderV1(doc map[string]any) error {
+	if _, ok := doc["version"]; !ok {
+		doc["version"] = 1
+	}
+	if payment, ok := doc["payment"].(map[string]any); ok {
+		if currency, ok := payment["currency"].(string); ok {
+			payment["currency"] = strings.ToUpper(currency)
+		}
+	}
+	if locale, ok := doc["locale"].(string); ok {
+		doc["locale"] = strings.ToLower(locale)
+	}
+	return nil
+}
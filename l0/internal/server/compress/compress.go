@@ -0,0 +1,179 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+
+	"wb-tech-l0/internal/config"
+)
+
+// supported content encodings
+const (
+	EncodingGzip = "gzip"
+	EncodingZstd = "zstd"
+)
+
+// Encoded is a response body already encoded with Encoding.
+// It is stored in cache, so cache hits are not compressed again
+type Encoded struct {
+	Encoding    string
+	ContentType string
+	Body        []byte
+}
+
+// Compressor negotiates response encoding with clients
+// and compresses response bodies. It's methods are safe for concurrent use
+type Compressor struct {
+	// encodings are enabled encodings in server preference order
+	encodings    []string
+	minSize      int
+	contentTypes map[string]struct{}
+
+	gzipPool sync.Pool
+	zstdPool sync.Pool
+}
+
+// New creates and returns Compressor from compression config
+func New(cfg *config.CompressionConfig) *Compressor {
+	c := &Compressor{
+		encodings:    cfg.Encodings,
+		minSize:      cfg.MinSize,
+		contentTypes: make(map[string]struct{}, len(cfg.ContentTypes)),
+	}
+	for _, ct := range cfg.ContentTypes {
+		c.contentTypes[strings.ToLower(strings.TrimSpace(ct))] = struct{}{}
+	}
+	return c
+}
+
+// MinSize returns minimum body size worth compressing
+func (c *Compressor) MinSize() int {
+	return c.minSize
+}
+
+// Allowed reports whether response with content type can be compressed
+func (c *Compressor) Allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	_, ok := c.contentTypes[mediaType]
+	return ok
+}
+
+// Negotiate chooses encoding from request Accept-Encoding header.
+// Encoding with highest quality wins and ties are broken by server preference.
+// It returns empty string if response must not be encoded
+func (c *Compressor) Negotiate(r *http.Request) string {
+	accepted := parseAcceptEncoding(r.Header.Get("Accept-Encoding"))
+
+	best, bestQ := "", 0.0
+	for _, enc := range c.encodings {
+		q, ok := accepted[enc]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		// strict comparison keeps server preference on ties
+		if ok && q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// Encode compresses data with encoding
+func (c *Compressor) Encode(encoding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := c.NewWriter(encoding, &buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Writer is a streaming compressing writer.
+// Close must be called to flush data and return writer to pool
+type Writer interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// NewWriter returns streaming writer compressing to w with encoding.
+// Writers are pooled, so they are cheap to create per response
+func (c *Compressor) NewWriter(encoding string, w io.Writer) (Writer, error) {
+	switch encoding {
+	case EncodingGzip:
+		gw, ok := c.gzipPool.Get().(*gzip.Writer)
+		if !ok {
+			gw = gzip.NewWriter(w)
+		} else {
+			gw.Reset(w)
+		}
+		return &pooledWriter{Writer: gw, release: func() { c.gzipPool.Put(gw) }}, nil
+	case EncodingZstd:
+		zw, ok := c.zstdPool.Get().(*zstd.Encoder)
+		if !ok {
+			var err error
+			// single goroutine encoder, as responses are compressed concurrently anyway
+			zw, err = zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+			if err != nil {
+				return nil, fmt.Errorf("could not create zstd writer: %w", err)
+			}
+		} else {
+			zw.Reset(w)
+		}
+		return &pooledWriter{Writer: zw, release: func() { c.zstdPool.Put(zw) }}, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+// pooledWriter returns its writer to pool after Close
+type pooledWriter struct {
+	Writer
+	release func()
+}
+
+func (w *pooledWriter) Close() error {
+	err := w.Writer.Close()
+	w.release()
+	return err
+}
+
+// parseAcceptEncoding parses Accept-Encoding header to map of encoding qualities
+func parseAcceptEncoding(header string) map[string]float64 {
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		if key, value, found := strings.Cut(strings.TrimSpace(params), "="); found && strings.TrimSpace(key) == "q" {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		accepted[name] = q
+	}
+	return accepted
+}
@@ -0,0 +1,89 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+
+	"wb-tech-l0/internal/config"
+)
+
+func newTestCompressor() *Compressor {
+	return New(&config.CompressionConfig{
+		Encodings:    []string{EncodingZstd, EncodingGzip},
+		MinSize:      16,
+		ContentTypes: []string{"application/json"},
+	})
+}
+
+func TestNegotiate(t *testing.T) {
+	c := newTestCompressor()
+
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{name: "No header", header: "", want: ""},
+		{name: "Gzip only", header: "gzip, deflate", want: EncodingGzip},
+		{name: "Server preference on tie", header: "gzip, zstd", want: EncodingZstd},
+		{name: "Client quality", header: "zstd;q=0.5, gzip;q=0.8", want: EncodingGzip},
+		{name: "Refused encoding", header: "zstd;q=0, gzip", want: EncodingGzip},
+		{name: "Wildcard", header: "*", want: EncodingZstd},
+		{name: "Unsupported", header: "br", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Accept-Encoding", tt.header)
+			}
+			if got := c.Negotiate(r); got != tt.want {
+				t.Errorf("Negotiate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	c := newTestCompressor()
+	data := bytes.Repeat([]byte(`{"order_uid":"b563feb7b2b84b6test"}`), 100)
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		EncodingGzip: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		EncodingZstd: func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+
+	for encoding, decode := range decoders {
+		t.Run(encoding, func(t *testing.T) {
+			// encoding twice to check pooled writers reuse
+			for i := 0; i < 2; i++ {
+				encoded, err := c.Encode(encoding, data)
+				if err != nil {
+					t.Fatalf("Encode() error = %v", err)
+				}
+				if len(encoded) >= len(data) {
+					t.Errorf("Encode() size = %d, want less than %d", len(encoded), len(data))
+				}
+
+				r, err := decode(bytes.NewReader(encoded))
+				if err != nil {
+					t.Fatalf("decoder error = %v", err)
+				}
+				decoded, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatalf("decode error = %v", err)
+				}
+				if !bytes.Equal(decoded, data) {
+					t.Errorf("decoded data is not equal to original")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package serverhandlers
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"wb-tech-l0/internal/cache"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/pii"
+	"wb-tech-l0/internal/server/compress"
+)
+
+// generations is a number of orders cache generation counters.
+// Orders are spread over them by uid hash, so their memory is bounded
+const generations = 256
+
+// OrderCache is a cache of orders and their pre-encoded responses.
+// Orders read from storage are cached only if they were not saved (invalidated)
+// while reading, so concurrent request can't put stale order back to cache.
+// It is events.Notifier and events.Invalidator invalidating saved orders
+type OrderCache struct {
+	cache cache.Cache
+
+	// mu guards generations and makes generation check and saving atomic
+	mu sync.Mutex
+	// generations are incremented on every saved order of their uids
+	generations [generations]uint64
+}
+
+// NewOrderCache creates and returns OrderCache backed by cache
+func NewOrderCache(cache cache.Cache) *OrderCache {
+	return &OrderCache{cache: cache}
+}
+
+// Generation returns current cache generation of order uid.
+// It must be taken before reading order, which is saved with it
+func (c *OrderCache) Generation(uid string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generations[generationIndex(uid)]
+}
+
+// Get gets cached order or its pre-encoded response by key
+func (c *OrderCache) Get(key string) (interface{}, bool) {
+	return c.cache.GetOrder(key)
+}
+
+// Save saves order uid or its pre-encoded response by key, if order
+// was not invalidated since generation was taken. It returns true if value was saved
+func (c *OrderCache) Save(uid string, generation uint64, key string, value interface{}) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generations[generationIndex(uid)] != generation {
+		return false
+	}
+	c.cache.SaveOrder(key, value)
+	return true
+}
+
+// OrderSaved invalidates saved (updated) order
+func (c *OrderCache) OrderSaved(order *models.Order) {
+	c.InvalidateOrder(order.OrderUID)
+}
+
+// InvalidateOrder removes order and all its pre-encoded responses from cache,
+// so they are not served stale, and starts new generation of order
+func (c *OrderCache) InvalidateOrder(uid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generations[generationIndex(uid)]++
+	c.cache.DeleteOrder(uid)
+	for _, view := range []string{pii.ViewFull, pii.ViewMasked} {
+		for _, encoding := range []string{compress.EncodingGzip, compress.EncodingZstd} {
+			c.cache.DeleteOrder(encodedKey(uid, view, encoding))
+		}
+	}
+}
+
+// generationIndex returns index of uid generation counter
+func generationIndex(uid string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(uid)) // nolint: errcheck
+	return h.Sum32() % generations
+}
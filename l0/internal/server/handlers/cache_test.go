@@ -0,0 +1,110 @@
+package serverhandlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/config"
+	"wb-tech-l0/internal/events"
+	noplogger "wb-tech-l0/internal/logger/nop"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/pii"
+	"wb-tech-l0/internal/server/compress"
+	"wb-tech-l0/internal/storage"
+)
+
+// mapCache is a map based Cache implementation
+type mapCache map[string]interface{}
+
+func (mapCache) Close() error { return nil }
+
+func (c mapCache) GetOrder(key string) (interface{}, bool) {
+	v, ok := c[key]
+	return v, ok
+}
+
+func (c mapCache) SaveOrder(key string, value interface{}) { c[key] = value }
+func (c mapCache) DeleteOrder(key string)                  { delete(c, key) }
+
+// savingStorage returns stored order, calling saved while it is read
+type savingStorage struct {
+	storage.Storage
+	order *models.Order
+	saved func()
+}
+
+func (s *savingStorage) GetOrder(_ context.Context, _ string) (*models.Order, error) {
+	if s.saved != nil {
+		s.saved()
+	}
+	return s.order, nil
+}
+
+func TestOrderCacheInvalidation(t *testing.T) {
+	c := mapCache{
+		"a": &models.Order{OrderUID: "a"},
+		"b": &models.Order{OrderUID: "b"},
+		"a|" + pii.ViewMasked + "|" + compress.EncodingGzip: &compress.Encoded{},
+		"a|" + pii.ViewFull + "|" + compress.EncodingZstd:   &compress.Encoded{},
+	}
+	orders := NewOrderCache(c)
+	generation := orders.Generation("a")
+
+	orders.OrderSaved(&models.Order{OrderUID: "a"})
+
+	if len(c) != 1 {
+		t.Fatalf("cache keeps %d items, want only b", len(c))
+	}
+	if _, ok := c["b"]; !ok {
+		t.Error("other order was removed from cache")
+	}
+	if orders.Save("a", generation, "a", &models.Order{OrderUID: "a"}) {
+		t.Error("order read before invalidation was cached")
+	}
+	if !orders.Save("a", orders.Generation("a"), "a", &models.Order{OrderUID: "a"}) {
+		t.Error("order read after invalidation was not cached")
+	}
+}
+
+func TestGetOrderSavedWhileLoading(t *testing.T) {
+	c := mapCache{}
+	orders := NewOrderCache(c)
+	// order is updated and invalidated after it is read, but before it is cached
+	stale := &models.Order{OrderUID: "a", Version: 1}
+	store := &savingStorage{order: stale, saved: func() {
+		orders.OrderSaved(&models.Order{OrderUID: "a", Version: 2})
+	}}
+	compressor := compress.New(&config.CompressionConfig{Enabled: true, Encodings: []string{compress.EncodingGzip}})
+	handler := GetOrderHandler(noplogger.New(), orders, store, pii.New(&config.MaskingConfig{}), compressor, events.NewWaiters(1), time.Second)
+
+	get := func() int {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/order/a", nil)
+		r.SetPathValue("order_uid", "a")
+		r.Header.Set("Accept-Encoding", compress.EncodingGzip)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Code
+	}
+
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if len(c) != 0 {
+		t.Fatalf("stale order is cached: %v", c)
+	}
+
+	// order read without concurrent saving is cached with its encoded body
+	store.saved = nil
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if _, ok := c["a"]; !ok {
+		t.Error("order is not cached")
+	}
+	if _, ok := c[encodedKey("a", pii.ViewMasked, compress.EncodingGzip)]; !ok {
+		t.Error("encoded order is not cached")
+	}
+}
@@ -0,0 +1,358 @@
+package serverhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"wb-tech-l0/internal/broker"
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/replay"
+	"wb-tech-l0/internal/server/middlewares"
+	"wb-tech-l0/internal/server/problem"
+)
+
+// maxConsumerBody is a maximum size of offsets reset and replay requests bodies
+const maxConsumerBody = 64 << 10
+
+// OffsetsResetRequest is a request to move consumer group offsets of stream.
+// @Description Consumer group offsets target: timestamp or explicit offsets of partitions.
+type OffsetsResetRequest struct {
+	// Stream: orders (default) or statuses
+	Stream string `json:"stream"`
+	replay.Target
+}
+
+// OffsetsResetResult is a result of consumer group offsets reset.
+// @Description New next offsets of stream partitions.
+type OffsetsResetResult struct {
+	// Stream
+	Stream string `json:"stream"`
+	// New next offsets of partitions
+	Offsets map[int]int64 `json:"offsets"`
+}
+
+// ConsumerState is a broker consumer state.
+// @Description Broker consumer state: running, draining or paused, and running replays.
+type ConsumerState struct {
+	// Consumer state: running, draining (paused with running handlers) or paused
+	State string `json:"state"`
+	// Running replays
+	Replays []replay.Job `json:"replays"`
+}
+
+// consumerState returns state of consumer and running replays, replays can be nil.
+// Paused consumer with running replay handlers is draining
+func consumerState(consumer broker.Broker, replays *replay.Runner) ConsumerState {
+	state := ConsumerState{State: string(consumer.State()), Replays: []replay.Job{}}
+	if replays != nil {
+		state.Replays = replays.Running()
+	}
+	if state.State == string(broker.StatePaused) && len(state.Replays) > 0 {
+		state.State = string(broker.StateDraining)
+	}
+	return state
+}
+
+// ReplayRequest is a request to replay stream range.
+// @Description Replay range: start and optional end (exclusive).
+type ReplayRequest struct {
+	// Replay start
+	From replay.Target `json:"from"`
+	// Replay end (exclusive), empty means partitions ends at replay start
+	To replay.Target `json:"to"`
+}
+
+// GetConsumerHandler godoc
+//
+//	@Summary		Состояние consumer
+//	@Description	Возвращает состояние consumer брокера: running, draining или paused, и выполняющиеся replay
+//	@Tags			consumer
+//	@Success		200	{object}	ConsumerState
+//	@Router			/api/v1/admin/consumer [get]
+func GetConsumerHandler(log logger.Logger, consumer broker.Broker, replays *replay.Runner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())))
+		writeJSON(w, r, log, http.StatusOK, consumerState(consumer, replays))
+	}
+}
+
+// PauseConsumerHandler godoc
+//
+//	@Summary		Приостановить consumer
+//	@Description	Останавливает получение сообщений из брокера, API продолжает работать.
+//	@Description	Обрабатываемые сообщения не прерываются. Выполняющиеся replay отменяются,
+//	@Description	новые replay не запускаются до resume
+//	@Tags			consumer
+//	@Success		200	{object}	ConsumerState
+//	@Failure		504	{object}	problem.Problem	"request timed out"
+//	@Router			/api/v1/admin/consumer/pause [post]
+func PauseConsumerHandler(log logger.Logger, consumer broker.Broker, replays *replay.Runner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())))
+
+		if err := consumer.Pause(r.Context()); err != nil {
+			log.Warn("Failed to pause consumer", logger.Error(err))
+			problem.Error(w, r, log, err)
+			return
+		}
+		if replays != nil {
+			replays.Pause()
+		}
+
+		log.Info("Consumer paused", logger.Field("caller", caller(r)))
+		writeJSON(w, r, log, http.StatusOK, consumerState(consumer, replays))
+	}
+}
+
+// DrainConsumerHandler godoc
+//
+//	@Summary		Дренировать consumer
+//	@Description	Останавливает получение сообщений из брокера и ждет завершения обрабатываемых сообщений.
+//	@Description	Выполняющиеся replay отменяются, их обработчики тоже ожидаются.
+//	@Description	Если они не завершились до таймаута запроса, consumer остается приостановленным
+//	@Tags			consumer
+//	@Success		200	{object}	ConsumerState
+//	@Failure		504	{object}	problem.Problem	"handlers are still running"
+//	@Router			/api/v1/admin/consumer/drain [post]
+func DrainConsumerHandler(log logger.Logger, consumer broker.Broker, replays *replay.Runner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())))
+
+		if replays != nil {
+			replays.Pause()
+		}
+		err := consumer.Drain(r.Context())
+		if err == nil && replays != nil {
+			err = replays.Wait(r.Context())
+		}
+		if err != nil {
+			log.Warn("Failed to drain consumer", logger.Field("state", consumerState(consumer, replays).State), logger.Error(err))
+			problem.Error(w, r, log, err)
+			return
+		}
+
+		log.Info("Consumer drained", logger.Field("caller", caller(r)))
+		writeJSON(w, r, log, http.StatusOK, consumerState(consumer, replays))
+	}
+}
+
+// ResumeConsumerHandler godoc
+//
+//	@Summary		Возобновить consumer
+//	@Description	Возобновляет получение сообщений из брокера после pause или drain и разрешает запуск replay.
+//	@Description	Отмененные replay не продолжаются
+//	@Tags			consumer
+//	@Success		200	{object}	ConsumerState
+//	@Router			/api/v1/admin/consumer/resume [post]
+func ResumeConsumerHandler(log logger.Logger, consumer broker.Broker, replays *replay.Runner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())))
+
+		consumer.Resume()
+		if replays != nil {
+			replays.Resume()
+		}
+
+		log.Info("Consumer resumed", logger.Field("caller", caller(r)))
+		writeJSON(w, r, log, http.StatusOK, consumerState(consumer, replays))
+	}
+}
+
+// ResetOffsetsHandler godoc
+//
+//	@Summary		Сдвинуть offsets consumer group
+//	@Description	Сдвигает offsets группы KAFKA_GROUP_ID для партиций потока на первые сообщения после timestamp
+//	@Description	или на явные offsets партиций. Подписки приложения на время сдвига останавливаются,
+//	@Description	другие экземпляры приложения должны быть остановлены
+//	@Tags			consumer
+//	@Accept			json
+//	@Param			target	body		OffsetsResetRequest	true	"Поток и timestamp или offsets"
+//	@Success		200		{object}	OffsetsResetResult
+//	@Failure		400		{object}	problem.Problem	"invalid target"
+//	@Failure		409		{object}	problem.Problem	"consumer group has active members"
+//	@Failure		500		{object}	problem.Problem	"internal server error"
+//	@Router			/api/v1/admin/consumer/offsets [post]
+func ResetOffsetsHandler(log logger.Logger, resetter broker.OffsetResetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())))
+
+		var req OffsetsResetRequest
+		if !decodeConsumerRequest(w, r, log, &req) {
+			return
+		}
+		if req.Stream == "" {
+			req.Stream = string(broker.StreamOrders)
+		}
+		if req.Stream != string(broker.StreamOrders) && req.Stream != string(broker.StreamStatuses) {
+			problem.Write(w, r, log, problem.BadRequest("stream must be orders or statuses"))
+			return
+		}
+		if detail, ok := validTarget(req.Target, true); !ok {
+			problem.Write(w, r, log, problem.BadRequest(detail))
+			return
+		}
+
+		offsets, err := resetter.ResetOffsets(r.Context(), broker.Stream(req.Stream), req.OffsetTarget())
+		if err != nil {
+			log.Warn("Failed to reset consumer group offsets", logger.Field("stream", req.Stream), logger.Error(err))
+			problem.Write(w, r, log, consumerProblem(err))
+			return
+		}
+
+		log.Info("Consumer group offsets reset", logger.Field("stream", req.Stream), logger.Field("offsets", offsets))
+		writeJSON(w, r, log, http.StatusOK, OffsetsResetResult{Stream: req.Stream, Offsets: offsets})
+	}
+}
+
+// StartReplayHandler godoc
+//
+//	@Summary		Запустить replay
+//	@Description	Запускает в фоне повторную обработку диапазона потока заказов отдельным consumer без группы,
+//	@Description	offsets группы не изменяются. Одновременно выполняется только один replay.
+//	@Description	Сохраненные заказы с той же или более новой версией не перезаписываются
+//	@Tags			consumer
+//	@Accept			json
+//	@Param			range	body		ReplayRequest	true	"Начало и конец диапазона"
+//	@Success		202		{object}	replay.Job
+//	@Failure		400		{object}	problem.Problem	"invalid range"
+//	@Failure		409		{object}	problem.Problem	"another replay is running or consumer is paused"
+//	@Router			/api/v1/admin/replays [post]
+func StartReplayHandler(log logger.Logger, replays *replay.Runner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())))
+
+		var req ReplayRequest
+		if !decodeConsumerRequest(w, r, log, &req) {
+			return
+		}
+		if detail, ok := validTarget(req.From, true); !ok {
+			problem.Write(w, r, log, problem.BadRequest("from: "+detail))
+			return
+		}
+		if detail, ok := validTarget(req.To, false); !ok {
+			problem.Write(w, r, log, problem.BadRequest("to: "+detail))
+			return
+		}
+
+		job, err := replays.Start(req.From, req.To)
+		if err != nil {
+			log.Debug("Failed to start replay", logger.Error(err))
+			problem.Write(w, r, log, consumerProblem(err))
+			return
+		}
+
+		log.Info("Replay started", logger.Field("replay_id", job.ID))
+		writeJSON(w, r, log, http.StatusAccepted, job)
+	}
+}
+
+// ListReplaysHandler godoc
+//
+//	@Summary		Список replay
+//	@Description	Возвращает выполняющийся и последние завершенные replay, новые первыми
+//	@Tags			consumer
+//	@Success		200	{array}	replay.Job
+//	@Router			/api/v1/admin/replays [get]
+func ListReplaysHandler(log logger.Logger, replays *replay.Runner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())))
+		writeJSON(w, r, log, http.StatusOK, replays.Jobs())
+	}
+}
+
+// GetReplayHandler godoc
+//
+//	@Summary		Состояние replay
+//	@Description	Возвращает состояние и количество обработанных сообщений replay
+//	@Tags			consumer
+//	@Param			id	path		string	true	"ID replay"
+//	@Success		200	{object}	replay.Job
+//	@Failure		404	{object}	problem.Problem	"replay not found"
+//	@Router			/api/v1/admin/replays/{id} [get]
+func GetReplayHandler(log logger.Logger, replays *replay.Runner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())), logger.Field("replay_id", r.PathValue("id")))
+
+		job, ok := replays.Job(r.PathValue("id"))
+		if !ok {
+			problem.Write(w, r, log, problem.NotFound("replay not found"))
+			return
+		}
+		writeJSON(w, r, log, http.StatusOK, job)
+	}
+}
+
+// CancelReplayHandler godoc
+//
+//	@Summary		Отменить replay
+//	@Description	Отменяет выполняющийся replay. Уже обработанные сообщения не откатываются
+//	@Tags			consumer
+//	@Param			id	path	string	true	"ID replay"
+//	@Success		204
+//	@Failure		404	{object}	problem.Problem	"replay not found"
+//	@Router			/api/v1/admin/replays/{id}/cancel [post]
+func CancelReplayHandler(log logger.Logger, replays *replay.Runner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())), logger.Field("replay_id", r.PathValue("id")))
+
+		if !replays.Cancel(r.PathValue("id")) {
+			problem.Write(w, r, log, problem.NotFound("replay not found"))
+			return
+		}
+
+		log.Info("Replay cancel requested")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// decodeConsumerRequest decodes offsets tools JSON request body.
+// It writes problem and returns false if body is invalid
+func decodeConsumerRequest(w http.ResponseWriter, r *http.Request, log logger.Logger, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxConsumerBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		log.Debug("Invalid consumer request JSON", logger.Error(err))
+		problem.Write(w, r, log, problem.BadRequest("request body must be JSON object with timestamp or offsets"))
+		return false
+	}
+	return true
+}
+
+// validTarget checks that target has either timestamp or non-negative offsets.
+// Empty target is valid if it is not required. It returns problem detail if target is invalid
+func validTarget(target replay.Target, required bool) (string, bool) {
+	switch {
+	case target.Timestamp != nil && len(target.Offsets) > 0:
+		return "only one of timestamp and offsets must be set", false
+	case target.Timestamp == nil && len(target.Offsets) == 0:
+		if required {
+			return "timestamp or offsets must be set", false
+		}
+		return "", true
+	}
+	for _, offset := range target.Offsets {
+		if offset < 0 {
+			return "offsets must be non-negative", false
+		}
+	}
+	return "", true
+}
+
+// consumerProblem maps offsets tools and replay errors to Problem.
+// Other errors are mapped by problem.FromError
+func consumerProblem(err error) *problem.Problem {
+	switch {
+	case errors.Is(err, broker.ErrGroupActive):
+		return problem.New(http.StatusConflict, problem.CodeConflict, "consumer group has active members of other instances")
+	case errors.Is(err, replay.ErrRunning):
+		return problem.New(http.StatusConflict, problem.CodeConflict, "another replay is running")
+	case errors.Is(err, replay.ErrPaused):
+		return problem.New(http.StatusConflict, problem.CodeConflict, "replays are paused with consumer")
+	case errors.Is(err, broker.ErrUnknownPartition), errors.Is(err, broker.ErrOffsetOutOfRange):
+		// offsets errors describe invalid target given by caller
+		return problem.BadRequest(err.Error())
+	default:
+		return problem.FromError(err)
+	}
+}
@@ -0,0 +1,103 @@
+package serverhandlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/broker"
+	noplogger "wb-tech-l0/internal/logger/nop"
+	"wb-tech-l0/internal/replay"
+	"wb-tech-l0/internal/server/problem"
+)
+
+func TestConsumerProblem(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "Active consumer group",
+			err:        fmt.Errorf("reset offsets: %w", broker.ErrGroupActive),
+			wantStatus: http.StatusConflict,
+			wantCode:   problem.CodeConflict,
+		},
+		{
+			name:       "Running replay",
+			err:        replay.ErrRunning,
+			wantStatus: http.StatusConflict,
+			wantCode:   problem.CodeConflict,
+		},
+		{
+			name:       "Offset out of range",
+			err:        fmt.Errorf("%w: partition 0 offset 10", broker.ErrOffsetOutOfRange),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   problem.CodeBadRequest,
+		},
+		{
+			name:       "Unknown",
+			err:        errors.New("boom"),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   problem.CodeInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := consumerProblem(tt.err)
+			if p.Status != tt.wantStatus || p.Code != tt.wantCode {
+				t.Errorf("consumerProblem() = %d %s, want %d %s", p.Status, p.Code, tt.wantStatus, tt.wantCode)
+			}
+		})
+	}
+}
+
+// fakeConsumer is a broker in given state.
+// Not implemented methods panic on nil embedded interface
+type fakeConsumer struct {
+	broker.Broker
+	state broker.State
+}
+
+func (c fakeConsumer) State() broker.State { return c.state }
+
+// blockingReplayer replays until ctx is done
+type blockingReplayer struct{}
+
+func (blockingReplayer) Replay(ctx context.Context, _ broker.Stream, _, _ broker.OffsetTarget, _ func(message *broker.Message) error) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestConsumerState(t *testing.T) {
+	replays := replay.New(context.Background(), noplogger.New(), blockingReplayer{}, broker.StreamOrders, nil)
+
+	if state := consumerState(fakeConsumer{state: broker.StatePaused}, nil); state.State != string(broker.StatePaused) || state.Replays == nil {
+		t.Fatalf("consumerState() without replays = %+v", state)
+	}
+
+	job, err := replays.Start(replay.Target{Offsets: map[int]int64{0: 1}}, replay.Target{})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	state := consumerState(fakeConsumer{state: broker.StateRunning}, replays)
+	if state.State != string(broker.StateRunning) || len(state.Replays) != 1 || state.Replays[0].ID != job.ID {
+		t.Fatalf("consumerState() with replay = %+v", state)
+	}
+	// paused consumer is draining until replay handlers exit
+	if state := consumerState(fakeConsumer{state: broker.StatePaused}, replays); state.State != string(broker.StateDraining) {
+		t.Fatalf("consumerState() of paused consumer with replay = %+v, want draining", state)
+	}
+
+	replays.Pause()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := replays.Wait(ctx); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package serverhandlers
+
+import (
+	"net/http"
+	"time"
+
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/history"
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/pii"
+	"wb-tech-l0/internal/server/middlewares"
+	"wb-tech-l0/internal/server/problem"
+	"wb-tech-l0/internal/storage"
+)
+
+// OrderHistory is an order change history.
+// @Description Order revisions with field-level changes.
+type OrderHistory struct {
+	// Unique order identifier
+	OrderUID string `json:"order_uid"`
+	// Order revisions, oldest first
+	Revisions []OrderRevision `json:"revisions"`
+}
+
+// OrderRevision is a single order mutation.
+// @Description Order revision with its source and changed fields.
+type OrderRevision struct {
+	// Revision number, starting from 1
+	Revision int `json:"revision"`
+	// Mutation kind: created, updated or status_changed
+	Kind string `json:"kind"`
+	// Order version after mutation
+	Version int64 `json:"version"`
+	// Mutation source
+	Source models.Source `json:"source"`
+	// Revision date
+	CreatedAt time.Time `json:"created_at"`
+	// Changed fields compared to previous revision. Empty for first revision
+	Changes []history.Change `json:"changes"`
+}
+
+// GetOrderHistoryHandler godoc
+//
+//	@Summary		История изменений заказа
+//	@Description	Возвращает все ревизии заказа с источником изменения (сообщение брокера или вызов API)
+//	@Description	и списком измененных полей относительно предыдущей ревизии.
+//	@Description	Персональные данные получателя маскируются, если у клиента нет роли с полным доступом
+//	@Tags			order
+//	@Param			order_uid	path		string	true	"UID заказа"
+//	@Success		200			{object}	OrderHistory
+//	@Failure		404			{object}	problem.Problem	"order not found"
+//	@Failure		500			{object}	problem.Problem	"internal server error"
+//	@Failure		504			{object}	problem.Problem	"request timed out"
+//	@Router			/api/v1/order/{order_uid}/history [get]
+func GetOrderHistoryHandler(log logger.Logger, store storage.Storage, masker *pii.Masker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// response depends on caller role because of PII masking
+		w.Header().Add("Vary", "Authorization, X-API-Key")
+		principal := auth.PrincipalFrom(r.Context())
+
+		uid := r.PathValue("order_uid")
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())), logger.Field("order_uid", uid))
+
+		revisions, err := store.GetOrderHistory(r.Context(), uid)
+		if err != nil {
+			log.Debug("Failed to get order history", logger.Error(err))
+			problem.Error(w, r, log, err)
+			return
+		}
+
+		response := OrderHistory{OrderUID: uid, Revisions: make([]OrderRevision, 0, len(revisions))}
+		// snapshots are masked before comparing, so diffs don't reveal masked fields
+		var previous *models.Order
+		for _, rev := range revisions {
+			current := masker.Apply(rev.Order, principal)
+
+			changes := []history.Change{}
+			if previous != nil {
+				changes, err = history.Diff(previous, current)
+				if err != nil {
+					log.Error("Failed to compare order revisions", logger.Field("revision", rev.Revision), logger.Error(err))
+					problem.Write(w, r, log, problem.Internal())
+					return
+				}
+			}
+
+			response.Revisions = append(response.Revisions, OrderRevision{
+				Revision:  rev.Revision,
+				Kind:      rev.Kind,
+				Version:   rev.Version,
+				Source:    rev.Source,
+				CreatedAt: rev.CreatedAt,
+				Changes:   changes,
+			})
+			previous = current
+		}
+
+		writeJSON(w, r, log, http.StatusOK, response)
+	}
+}
@@ -0,0 +1,181 @@
+package serverhandlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/events"
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/rules"
+	"wb-tech-l0/internal/schema"
+	"wb-tech-l0/internal/server/middlewares"
+	"wb-tech-l0/internal/server/problem"
+	"wb-tech-l0/internal/storage"
+)
+
+// maxOrderBody is a maximum size of order ingestion request body
+const maxOrderBody = 1 << 20
+
+// OrderSaveResult is a result of order ingestion.
+// @Description Result of order saving with business rules warnings.
+type OrderSaveResult struct {
+	// Unique order identifier
+	OrderUID string `json:"order_uid"`
+	// Save result: created, updated or ignored (stored version is the same or newer)
+	Result string `json:"result"`
+	// Order version
+	Version int64 `json:"version"`
+	// Violations of business rules configured to warn
+	Warnings []rules.Violation `json:"warnings"`
+}
+
+// CreateOrderHandler godoc
+//
+//	@Summary		Сохранить заказ
+//	@Description	Сохраняет заказ так же, как сообщение из брокера: заказ проверяется по схеме и бизнес-правилам
+//	@Description	(сумма товаров, итоговая сумма, цены товаров со скидкой), новая версия заменяет сохраненную,
+//	@Description	а та же или меньшая версия игнорируется. Нарушения правил с действием warn возвращаются в warnings.
+//	@Description	Заказ проверяется по JSON Schema версии из заголовка Schema-Version (без заголовка - самой старой)
+//	@Description	и приводится к текущей версии. В схеме v2 version обязательна и начинается с 1, каждое исправление
+//	@Description	заказа должно иметь большую версию. Заказы v1 получают версию 1, поэтому их можно создать, но не исправить
+//	@Tags			order
+//	@Accept			json
+//	@Param			order			body		models.Order	true	"Заказ"
+//	@Param			Schema-Version	header		string			false	"Версия схемы заказа"
+//	@Success		201		{object}	OrderSaveResult	"order created"
+//	@Success		200		{object}	OrderSaveResult	"order updated or ignored"
+//	@Failure		400		{object}	problem.Problem	"invalid order"
+//	@Failure		409		{object}	problem.Problem	"order conflicts with another order"
+//	@Failure		422		{object}	problem.Problem	"business rules violated"
+//	@Failure		500		{object}	problem.Problem	"internal server error"
+//	@Router			/api/v1/orders [post]
+func CreateOrderHandler(log logger.Logger, store storage.Storage, schemas *schema.Registry, validate *validator.Validate, orderRules *rules.Validator, notifier events.Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())))
+
+		var order models.Order
+		if !decodeOrder(w, r, log, schemas, &order) {
+			return
+		}
+
+		status, result, ok := ingestOrder(w, r, log, store, validate, orderRules, notifier, &order)
+		if !ok {
+			return
+		}
+		writeJSON(w, r, log, status, result)
+	}
+}
+
+// decodeOrder decodes order JSON request body the same way as broker messages:
+// body is validated with order schema of Schema-Version header version
+// (the oldest one without header) and upcasted to current order.
+// It writes problem and returns false if body is invalid
+func decodeOrder(w http.ResponseWriter, r *http.Request, log logger.Logger, schemas *schema.Registry, order *models.Order) bool {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxOrderBody))
+	if err != nil {
+		log.Debug("Failed to read order body", logger.Error(err))
+		problem.Write(w, r, log, problem.BadRequest("request body must be order JSON object"))
+		return false
+	}
+
+	err = schemas.Decode(schema.Order, r.Header.Get(schema.HeaderVersion), body, order)
+	if err == nil {
+		return true
+	}
+	log.Debug("Invalid order", logger.Error(err))
+
+	var schemaErr *schema.ValidationError
+	switch {
+	case errors.As(err, &schemaErr):
+		p := problem.New(http.StatusBadRequest, problem.CodeValidation, "order doesn't match schema")
+		p.Errors = make([]problem.FieldError, 0, len(schemaErr.Errors))
+		for _, fe := range schemaErr.Errors {
+			p.Errors = append(p.Errors, problem.FieldError{Field: fe.Path, Rule: fe.Keyword, Detail: fe.Message})
+		}
+		problem.Write(w, r, log, p)
+	case errors.Is(err, schema.ErrUnknownVersion):
+		problem.Write(w, r, log, problem.BadRequest(err.Error()))
+	default:
+		problem.Write(w, r, log, problem.BadRequest("request body must be order JSON object"))
+	}
+	return false
+}
+
+// ingestOrder validates order by schema and business rules and saves it the same way
+// as broker handler does. It returns response status and result of saving,
+// or writes problem and returns false if order is not saved
+func ingestOrder(w http.ResponseWriter, r *http.Request, log logger.Logger, store storage.Storage, validate *validator.Validate, orderRules *rules.Validator, notifier events.Notifier, order *models.Order) (int, *OrderSaveResult, bool) {
+	if err := validate.Struct(order); err != nil {
+		log.Debug("Invalid order schema", logger.Error(err))
+		problem.Error(w, r, log, err)
+		return 0, nil, false
+	}
+
+	log = log.With(logger.Field("order_uid", order.OrderUID))
+
+	warnings, err := orderRules.Validate(order)
+	if err != nil {
+		log.Info("Order violates business rules", logger.Error(err))
+		problem.Write(w, r, log, rulesProblem(err))
+		return 0, nil, false
+	}
+	if len(warnings) > 0 {
+		log.Warn("Order violates business rules. Order is accepted", logger.Field("rules", rules.IDs(warnings)))
+	} else {
+		warnings = []rules.Violation{}
+	}
+
+	result, err := store.SaveOrder(r.Context(), order, models.APISource(caller(r), middlewares.GetRequestID(r.Context())), nil)
+	if err != nil {
+		log.Error("Failed to save order", logger.Error(err))
+		problem.Error(w, r, log, err)
+		return 0, nil, false
+	}
+
+	log.Info("Order saved", logger.Field("result", result), logger.Field("version", order.Version))
+	status := http.StatusOK
+	if result == storage.SaveCreated {
+		status = http.StatusCreated
+	}
+	if result != storage.SaveIgnored {
+		// notifying in-process subscribers the same way as broker handler does
+		notifier.OrderSaved(order)
+	}
+
+	return status, &OrderSaveResult{
+		OrderUID: order.OrderUID,
+		Result:   string(result),
+		Version:  order.Version,
+		Warnings: warnings,
+	}, true
+}
+
+// caller returns subject of authenticated principal.
+// anonymous principal is used when authentication is disabled
+func caller(r *http.Request) string {
+	if principal := auth.PrincipalFrom(r.Context()); principal != nil {
+		return principal.Subject
+	}
+	return ""
+}
+
+// rulesProblem maps business rules violations to Problem with violations as field errors.
+// Other errors are mapped by problem.FromError
+func rulesProblem(err error) *problem.Problem {
+	var rulesErr *rules.Error
+	if !errors.As(err, &rulesErr) {
+		return problem.FromError(err)
+	}
+
+	p := problem.New(http.StatusUnprocessableEntity, problem.CodeRuleViolation, "business rules validation failed")
+	p.Errors = make([]problem.FieldError, 0, len(rulesErr.Violations))
+	for _, v := range rulesErr.Violations {
+		p.Errors = append(p.Errors, problem.FieldError{Field: v.Field, Rule: v.Rule, Detail: v.Message})
+	}
+	return p
+}
@@ -0,0 +1,261 @@
+package serverhandlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"wb-tech-l0/internal/config"
+	noplogger "wb-tech-l0/internal/logger/nop"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/rules"
+	"wb-tech-l0/internal/schema"
+	"wb-tech-l0/internal/server/problem"
+	"wb-tech-l0/internal/storage"
+)
+
+// testOrder is a valid order of the oldest schema version
+const testOrder = `{
+	"order_uid": "b563feb7b2b84b6test", "track_number": "WBILMTESTTRACK", "entry": "WBIL",
+	"delivery": {
+		"name": "Test Testov", "phone": "+9720000000", "zip": "2639809", "city": "Kiryat Mozkin",
+		"address": "Ploshad Mira 15", "region": "Kraiot", "email": "test@gmail.com"
+	},
+	"payment": {
+		"transaction": "b563feb7b2b84b6test", "request_id": "", "currency": "USD", "provider": "wbpay",
+		"amount": 1817, "payment_dt": 1637907727, "bank": "alpha", "delivery_cost": 1500, "goods_total": 317, "custom_fee": 0
+	},
+	"items": [{
+		"chrt_id": 9934930, "track_number": "WBILMTESTTRACK", "price": 453, "rid": "ab4219087a764ae0btest",
+		"name": "Mascaras", "sale": 30, "size": "0", "total_price": 317, "nm_id": 2389212, "brand": "Vivienne Sabo", "status": 202
+	}],
+	"locale": "en", "internal_signature": "", "customer_id": "test", "delivery_service": "meest",
+	"shardkey": "9", "sm_id": 99, "date_created": "2021-11-26T06:22:19Z", "oof_shard": "1"
+}`
+
+// ingestStorage records saved orders and returns configured save result
+type ingestStorage struct {
+	storage.Storage
+	result storage.SaveResult
+	err    error
+	saved  []*models.Order
+}
+
+func (s *ingestStorage) SaveOrder(_ context.Context, order *models.Order, _ models.Source, _ *models.Position) (storage.SaveResult, error) {
+	s.saved = append(s.saved, order)
+	return s.result, s.err
+}
+
+// recordingNotifier records notified orders
+type recordingNotifier []*models.Order
+
+func (n *recordingNotifier) OrderSaved(order *models.Order) {
+	*n = append(*n, order)
+}
+
+func TestCreateOrderHandler(t *testing.T) {
+	schemas, err := schema.New()
+	if err != nil {
+		t.Fatalf("schema.New() error = %v", err)
+	}
+	wrongAmount := strings.Replace(testOrder, `"amount": 1817`, `"amount": 1000`, 1)
+
+	tests := []struct {
+		name        string
+		body        string
+		version     string
+		actions     map[string]string
+		result      storage.SaveResult
+		err         error
+		wantStatus  int
+		wantCode    string
+		wantField   string
+		wantResult  storage.SaveResult
+		wantVersion int64
+		wantWarning string
+		saved       bool
+		notified    bool
+	}{
+		{
+			name:        "created",
+			body:        testOrder,
+			result:      storage.SaveCreated,
+			wantStatus:  http.StatusCreated,
+			wantResult:  storage.SaveCreated,
+			wantVersion: 1,
+			saved:       true,
+			notified:    true,
+		},
+		{
+			name:        "updated with schema version",
+			body:        strings.Replace(testOrder, `"oof_shard": "1"`, `"oof_shard": "1", "version": 3`, 1),
+			version:     "2",
+			result:      storage.SaveUpdated,
+			wantStatus:  http.StatusOK,
+			wantResult:  storage.SaveUpdated,
+			wantVersion: 3,
+			saved:       true,
+			notified:    true,
+		},
+		{
+			name:        "ignored is not notified",
+			body:        testOrder,
+			result:      storage.SaveIgnored,
+			wantStatus:  http.StatusOK,
+			wantResult:  storage.SaveIgnored,
+			wantVersion: 1,
+			saved:       true,
+		},
+		{
+			name:        "created with warnings",
+			body:        wrongAmount,
+			actions:     map[string]string{rules.RuleAmount: rules.ActionWarn},
+			result:      storage.SaveCreated,
+			wantStatus:  http.StatusCreated,
+			wantResult:  storage.SaveCreated,
+			wantVersion: 1,
+			wantWarning: rules.RuleAmount,
+			saved:       true,
+			notified:    true,
+		},
+		{
+			name:        "updated with warnings",
+			body:        wrongAmount,
+			actions:     map[string]string{rules.RuleAmount: rules.ActionWarn},
+			result:      storage.SaveUpdated,
+			wantStatus:  http.StatusOK,
+			wantResult:  storage.SaveUpdated,
+			wantVersion: 1,
+			wantWarning: rules.RuleAmount,
+			saved:       true,
+			notified:    true,
+		},
+		{
+			name:       "rules violation is rejected",
+			body:       wrongAmount,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   problem.CodeRuleViolation,
+			wantField:  "payment.amount",
+		},
+		{
+			name:       "schema violation",
+			body:       strings.Replace(testOrder, `"amount": 1817`, `"amount": "1817"`, 1),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   problem.CodeValidation,
+			wantField:  "payment.amount",
+		},
+		{
+			name:       "schema version requires order version",
+			body:       testOrder,
+			version:    "2",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   problem.CodeValidation,
+		},
+		{
+			name:       "unknown schema version",
+			body:       testOrder,
+			version:    "100",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   problem.CodeBadRequest,
+		},
+		{
+			name:       "not JSON",
+			body:       `{"order_uid":`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   problem.CodeBadRequest,
+		},
+		{
+			name:       "conflict with another order",
+			body:       testOrder,
+			err:        storage.ErrUniqueViolation,
+			wantStatus: http.StatusConflict,
+			wantCode:   problem.CodeConflict,
+			saved:      true,
+		},
+		{
+			name:       "storage error",
+			body:       testOrder,
+			err:        errors.New("connection refused"),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   problem.CodeInternal,
+			saved:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &ingestStorage{result: tt.result, err: tt.err}
+			notifier := &recordingNotifier{}
+			orderRules := rules.New(&config.RulesConfig{DefaultAction: rules.ActionReject, Actions: tt.actions})
+			handler := CreateOrderHandler(noplogger.New(), store, schemas, models.NewValidator(), orderRules, notifier)
+
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/orders", strings.NewReader(tt.body))
+			if tt.version != "" {
+				r.Header.Set(schema.HeaderVersion, tt.version)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if saved := len(store.saved) > 0; saved != tt.saved {
+				t.Errorf("order saved = %v, want %v", saved, tt.saved)
+			}
+			if notified := len(*notifier) > 0; notified != tt.notified {
+				t.Errorf("order notified = %v, want %v", notified, tt.notified)
+			}
+
+			if tt.wantCode != "" {
+				var p problem.Problem
+				if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+					t.Fatalf("could not decode problem: %v", err)
+				}
+				if p.Code != tt.wantCode {
+					t.Errorf("problem code = %s, want %s", p.Code, tt.wantCode)
+				}
+				if tt.wantField != "" && (len(p.Errors) == 0 || p.Errors[0].Field != tt.wantField) {
+					t.Errorf("problem errors = %+v, want %s", p.Errors, tt.wantField)
+				}
+				return
+			}
+
+			var result OrderSaveResult
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Fatalf("could not decode result: %v", err)
+			}
+			if result.OrderUID != "b563feb7b2b84b6test" || result.Result != string(tt.wantResult) || result.Version != tt.wantVersion {
+				t.Errorf("result = %+v, want %s of version %d", result, tt.wantResult, tt.wantVersion)
+			}
+			// warnings are always an array, so clients don't check for null
+			if result.Warnings == nil {
+				t.Errorf("warnings = nil, want empty array")
+			}
+			if ids := rules.IDs(result.Warnings); tt.wantWarning != "" && (len(ids) != 1 || ids[0] != tt.wantWarning) {
+				t.Errorf("warnings = %v, want %s", ids, tt.wantWarning)
+			} else if tt.wantWarning == "" && len(ids) != 0 {
+				t.Errorf("warnings = %v, want none", ids)
+			}
+		})
+	}
+}
+
+func TestRulesProblem(t *testing.T) {
+	err := &rules.Error{Violations: []rules.Violation{{Rule: rules.RuleAmount, Field: "payment.amount", Message: "amount is not a sum"}}}
+
+	p := rulesProblem(err)
+	if p.Status != http.StatusUnprocessableEntity || p.Code != problem.CodeRuleViolation {
+		t.Errorf("rulesProblem() = %d %s, want %d %s", p.Status, p.Code, http.StatusUnprocessableEntity, problem.CodeRuleViolation)
+	}
+	if len(p.Errors) != 1 || p.Errors[0].Field != "payment.amount" || p.Errors[0].Rule != rules.RuleAmount {
+		t.Errorf("rulesProblem() errors = %v", p.Errors)
+	}
+
+	if p := rulesProblem(errors.New("boom")); p.Code != problem.CodeInternal {
+		t.Errorf("rulesProblem() code = %s, want %s", p.Code, problem.CodeInternal)
+	}
+}
@@ -1,87 +1,288 @@
 package serverhandlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
-	"strings"
+	"strconv"
+	"time"
 
-	"wb-tech-l0/internal/cache"
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/events"
 	"wb-tech-l0/internal/logger"
 	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/money"
+	"wb-tech-l0/internal/pii"
+	"wb-tech-l0/internal/server/compress"
 	"wb-tech-l0/internal/server/middlewares"
+	"wb-tech-l0/internal/server/problem"
 	"wb-tech-l0/internal/storage"
 )
 
+// OrderResponse is an order with formatted amounts.
+// @Description Order with amounts formatted for order locale and payment currency.
+type OrderResponse struct {
+	*models.Order
+	// Formatted amounts. Not set if payment currency is unknown
+	Formatted *FormattedAmounts `json:"formatted,omitempty"`
+}
+
+// FormattedAmounts are order amounts in major units with currency, for example $18.17.
+// @Description Order amounts formatted for order locale.
+type FormattedAmounts struct {
+	// Payment amount
+	Amount string `json:"amount"`
+	// Delivery cost
+	DeliveryCost string `json:"delivery_cost"`
+	// Total goods cost
+	GoodsTotal string `json:"goods_total"`
+	// Custom fee
+	CustomFee string `json:"custom_fee"`
+	// Items amounts, in items order
+	Items []FormattedItem `json:"items"`
+}
+
+// FormattedItem is an item amounts formatted for order locale.
+// @Description Item amounts formatted for order locale.
+type FormattedItem struct {
+	// Unit price
+	Price string `json:"price"`
+	// Total price
+	TotalPrice string `json:"total_price"`
+}
+
+// newOrderResponse creates OrderResponse from order.
+// Amounts are in minor units of payment currency, so they
+// can be formatted only if currency is known
+func newOrderResponse(o *models.Order) *OrderResponse {
+	response := &OrderResponse{Order: o}
+	if !money.ValidCurrency(o.Payment.Currency) {
+		return response
+	}
+
+	amounts := o.Payment.Amounts()
+	formatted := &FormattedAmounts{
+		Amount:       amounts.Amount.Format(o.Locale),
+		DeliveryCost: amounts.DeliveryCost.Format(o.Locale),
+		GoodsTotal:   amounts.GoodsTotal.Format(o.Locale),
+		CustomFee:    amounts.CustomFee.Format(o.Locale),
+		Items:        make([]FormattedItem, 0, len(o.Items)),
+	}
+	for i := range o.Items {
+		item := o.Payment.ItemAmounts(&o.Items[i])
+		formatted.Items = append(formatted.Items, FormattedItem{Price: item.Price.Format(o.Locale), TotalPrice: item.TotalPrice.Format(o.Locale)})
+	}
+	response.Formatted = formatted
+	return response
+}
+
 // GetOrderHandler godoc
 //
 //	@Summary		Получить заказ по UID
-//	@Description	Возвращает заказ по его уникальному идентификатору
+//	@Description	Возвращает заказ по его уникальному идентификатору.
+//	@Description	Персональные данные получателя маскируются, если у клиента нет роли с полным доступом.
+//	@Description	Суммы в ответе указаны в минимальных единицах валюты (центах, копейках),
+//	@Description	в formatted они отформатированы для локали заказа.
+//	@Description	Ответ сжимается (zstd, gzip) по Accept-Encoding.
+//	@Description	С параметром wait запрос ждет, пока заказ будет получен из брокера и сохранен
 //	@Tags			order
 //	@Param			order_uid	path		string	true	"UID заказа"
-//	@Success		200			{object}	models.Order
-//	@Failure		400			{string}	string	"missing order uid"
-//	@Failure		404			{string}	string	"order not found"
-//	@Failure		405			{string}	string	"method not allowed"
-//	@Failure		500			{string}	string	"internal server error"
-//	@Router			/api/order/{order_uid} [get]
-func GetOrderHandler(log logger.Logger, cache cache.Cache, store storage.Storage) http.HandlerFunc {
+//	@Param			wait		query		string	false	"Ждать сохранения заказа до указанного времени (например, 10s)"
+//	@Success		200			{object}	OrderResponse
+//	@Failure		400			{object}	problem.Problem	"invalid wait"
+//	@Failure		404			{object}	problem.Problem	"order not found"
+//	@Failure		405			{object}	problem.Problem	"method not allowed"
+//	@Failure		500			{object}	problem.Problem	"internal server error"
+//	@Failure		503			{object}	problem.Problem	"too many waiting requests"
+//	@Failure		504			{object}	problem.Problem	"request timed out"
+//	@Router			/api/v1/order/{order_uid} [get]
+func GetOrderHandler(log logger.Logger, cache *OrderCache, store storage.Storage, masker *pii.Masker, compressor *compress.Compressor, waiters *events.Waiters, maxWait time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// response depends on caller role because of PII masking
+		w.Header().Add("Vary", "Authorization, X-API-Key")
+		principal := auth.PrincipalFrom(r.Context())
+
 		// getting request id
 		requestID := middlewares.GetRequestID(r.Context())
-		log = log.With(logger.Field("request_id", requestID))
+		log := log.With(logger.Field("request_id", requestID))
+
+		// getting uid. router guarantees that it is single non-empty path segment
+		uid := r.PathValue("order_uid")
 
-		// checking method
-		if r.Method != http.MethodGet {
-			log.Debug("Request method is not allowed")
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		// parsing long-poll wait duration
+		wait, err := parseWait(r.URL.Query().Get("wait"), maxWait)
+		if err != nil {
+			log.Debug("Invalid wait parameter", logger.Error(err))
+			problem.Write(w, r, log, problem.BadRequest("wait must be non-negative duration, for example 10s"))
 			return
 		}
-		// getting uid
-		uid := strings.TrimPrefix(r.URL.Path, "/api/order/")
-		if uid == "" {
-			log.Debug("Request /{order_uid} path is empty")
-			http.Error(w, "missing order uid", http.StatusBadRequest)
-			return
+
+		// response body depends on masking view and encoding,
+		// so pre-encoded bodies are cached per both of them
+		view := masker.View(principal)
+		var encoding string
+		if compressor != nil {
+			encoding = compressor.Negotiate(r)
 		}
 
-		// try to get from cache first
-		if cached, found := cache.GetOrder(uid); found {
-			var ok bool
-			// check if it iss order
-			if cached, ok = cached.(*models.Order); !ok {
-				log.Debug("Requested item in cache is not order", logger.Field("uid", uid))
-				http.Error(w, "order not found", http.StatusNotFound)
+		// taking cache generation before reading order, so order saved
+		// while it is read is not cached stale
+		generation := cache.Generation(uid)
+
+		// try to get pre-encoded body first, so cache hits are not compressed again
+		if encoding != "" {
+			if cached, found := cache.Get(encodedKey(uid, view, encoding)); found {
+				if encoded, ok := cached.(*compress.Encoded); ok {
+					writeEncoded(w, log, encoded)
+					log.Debug("Successfully sent pre-encoded order response from cache")
+					return
+				}
+			}
+		}
+
+		// registering waiter before loading order, so order saved
+		// between storage check and registration is not missed
+		var saved <-chan *models.Order
+		if wait > 0 {
+			c, cancel, err := waiters.Register(uid)
+			if err != nil {
+				log.Warn("Could not register order waiter", logger.Error(err))
+				w.Header().Set("Retry-After", "1")
+				problem.Write(w, r, log, problem.New(http.StatusServiceUnavailable, problem.CodeUnavailable, "too many waiting requests"))
 				return
 			}
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(cached) // nolint: errcheck
-			log.Debug("Successfully sent order response from cache")
-			return
+			defer cancel()
+			saved = c
 		}
 
-		// getting order
-		order, err := store.GetOrder(r.Context(), uid)
+		order, err := loadOrder(r, log, cache, store, uid, generation)
+		if errors.Is(err, storage.ErrNotFound) && saved != nil {
+			order, err = waitOrder(w, r, log, saved, wait)
+		}
 		if err != nil {
-			switch {
-			case errors.Is(err, storage.ErrNotFound):
-				http.Error(w, "order not found", http.StatusNotFound)
-			default:
-				log.Warn("Failed to get order", logger.Error(err))
-				http.Error(w, "internal server error", http.StatusInternalServerError)
+			if errors.Is(err, context.Canceled) {
+				log.Debug("Client gone while waiting for order")
+				return
+			}
+			if errors.Is(err, storage.ErrNotFound) {
+				problem.Write(w, r, log, problem.NotFound("order not found"))
+				return
 			}
+			log.Warn("Failed to get order", logger.Error(err))
+			// mapping error to stable code (timeout or internal error)
+			problem.Error(w, r, log, err)
+			return
+		}
+
+		// cache keeps unmasked order, masking its copy for caller
+		body, err := json.Marshal(newOrderResponse(masker.Apply(order, principal)))
+		if err != nil {
+			log.Warn("Failed to encode order", logger.Error(err))
+			problem.Write(w, r, log, problem.Internal())
 			return
 		}
 
-		// save to cache for future requests
-		cache.SaveOrder(uid, order)
+		// compressing here instead of middleware to cache encoded body
+		if encoding != "" && len(body) >= compressor.MinSize() {
+			data, err := compressor.Encode(encoding, body)
+			if err == nil {
+				encoded := &compress.Encoded{Encoding: encoding, ContentType: "application/json", Body: data}
+				cache.Save(uid, generation, encodedKey(uid, view, encoding), encoded)
+				writeEncoded(w, log, encoded)
+				log.Debug("Successfully sent encoded order response")
+				return
+			}
+			// sending uncompressed body, it is still correct response
+			log.Warn("Failed to compress order response", logger.Field("encoding", encoding), logger.Error(err))
+		}
 
 		// sending response
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(order) // nolint: errcheck
+		writeBody(w, log, http.StatusOK, "application/json", body)
 
 		log.Debug("Successfully sent order response")
 	}
 }
+
+// loadOrder gets order from cache or from storage.
+// Order fetched from storage is saved to cache for future requests
+// if it was not saved since cache generation was taken
+func loadOrder(r *http.Request, log logger.Logger, cache *OrderCache, store storage.Storage, uid string, generation uint64) (*models.Order, error) {
+	// try to get from cache first
+	if cached, found := cache.Get(uid); found {
+		// check if it is order
+		if order, ok := cached.(*models.Order); ok {
+			log.Debug("Got order from cache")
+			return order, nil
+		}
+		log.Debug("Requested item in cache is not order", logger.Field("uid", uid))
+	}
+
+	// getting order
+	order, err := store.GetOrder(r.Context(), uid)
+	if err != nil {
+		return nil, err
+	}
+
+	// save to cache for future requests. order saved while reading is stale
+	if !cache.Save(uid, generation, uid, order) {
+		log.Debug("Order was saved while reading, not caching it")
+	}
+	return order, nil
+}
+
+// waitOrder waits until order is saved (saved channel receives it) or wait expires.
+// Received order is not cached, it can be already replaced by newer one.
+// It returns storage.ErrNotFound on timeout and request context error if client is gone
+func waitOrder(w http.ResponseWriter, r *http.Request, log logger.Logger, saved <-chan *models.Order, wait time.Duration) (*models.Order, error) {
+	// extending server write timeout, as waiting can be longer than it
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(wait + waitWriteMargin)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Debug("Could not extend write deadline", logger.Error(err))
+	}
+
+	log.Debug("Waiting for order to be saved", logger.Field("wait", wait.String()))
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case order := <-saved:
+		log.Debug("Order saved while waiting")
+		return order, nil
+	case <-timer.C:
+		return nil, storage.ErrNotFound
+	case <-r.Context().Done():
+		return nil, r.Context().Err()
+	}
+}
+
+// waitWriteMargin is added to write deadline of long-poll requests to write response after waiting
+const waitWriteMargin = 5 * time.Second
+
+// parseWait parses long-poll wait parameter: Go duration (10s) or integer seconds (10).
+// Empty parameter means no waiting. Wait longer than maxWait is clamped to it
+func parseWait(param string, maxWait time.Duration) (time.Duration, error) {
+	if param == "" {
+		return 0, nil
+	}
+
+	wait, err := time.ParseDuration(param)
+	if err != nil {
+		seconds, convErr := strconv.Atoi(param)
+		if convErr != nil {
+			return 0, err
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+	if wait < 0 {
+		return 0, fmt.Errorf("negative wait %s", wait)
+	}
+	return min(wait, maxWait), nil
+}
+
+// encodedKey is a cache key of order body encoded for masking view
+func encodedKey(uid, view, encoding string) string {
+	return uid + "|" + view + "|" + encoding
+}
@@ -0,0 +1,31 @@
+package serverhandlers
+
+import (
+	"net/http"
+
+	"wb-tech-l0/internal/broker"
+	"wb-tech-l0/internal/logger"
+)
+
+// Readiness is a readiness probe response.
+// @Description Readiness of API with broker consumer state.
+type Readiness struct {
+	// API status, always ready while server accepts requests
+	Status string `json:"status"`
+	// Broker consumer state: running, draining or paused
+	Consumer string `json:"consumer"`
+}
+
+// ReadyHandler godoc
+//
+//	@Summary		Готовность сервиса
+//	@Description	Возвращает готовность API и состояние consumer брокера. Приостановленный consumer
+//	@Description	не делает сервис неготовым, так как API продолжает обслуживать запросы
+//	@Tags			health
+//	@Success		200	{object}	Readiness
+//	@Router			/readyz [get]
+func ReadyHandler(log logger.Logger, consumer broker.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, log, http.StatusOK, Readiness{Status: "ready", Consumer: string(consumer.State())})
+	}
+}
@@ -0,0 +1,160 @@
+package serverhandlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+
+	"wb-tech-l0/internal/events"
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/rules"
+	"wb-tech-l0/internal/schema"
+	"wb-tech-l0/internal/server/middlewares"
+	"wb-tech-l0/internal/server/problem"
+	"wb-tech-l0/internal/storage"
+)
+
+// default and maximum number of returned rejections
+const (
+	defaultRejectionsLimit = 50
+	maxRejectionsLimit     = 500
+)
+
+// ListRejectionsHandler godoc
+//
+//	@Summary		Список отклоненных сообщений
+//	@Description	Возвращает сообщения брокера, отклоненные обработчиком, новые первыми: исходный payload (base64),
+//	@Description	заголовки, ошибки полей и позицию сообщения. Фильтруется по причине и интервалу дат [from, to)
+//	@Tags			rejections
+//	@Param			reason	query		string	false	"Причина: payload, content_type, decode, validation, rules, conflict"
+//	@Param			from	query		string	false	"Начало интервала, RFC 3339"
+//	@Param			to		query		string	false	"Конец интервала (не включительно), RFC 3339"
+//	@Param			limit	query		int		false	"Количество сообщений (по умолчанию 50, максимум 500)"
+//	@Success		200		{array}		models.Rejection
+//	@Failure		400		{object}	problem.Problem	"invalid filter"
+//	@Failure		500		{object}	problem.Problem	"internal server error"
+//	@Router			/api/v1/admin/rejections [get]
+func ListRejectionsHandler(log logger.Logger, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())))
+
+		query := r.URL.Query()
+		filter := storage.RejectionFilter{Reason: query.Get("reason")}
+
+		var err error
+		filter.Limit, err = parseLimit(query.Get("limit"), defaultRejectionsLimit, maxRejectionsLimit)
+		if err != nil {
+			problem.Write(w, r, log, problem.BadRequest("limit must be positive integer"))
+			return
+		}
+		if filter.From, err = parseTime(query.Get("from")); err != nil {
+			problem.Write(w, r, log, problem.BadRequest("from must be RFC 3339 date"))
+			return
+		}
+		if filter.To, err = parseTime(query.Get("to")); err != nil {
+			problem.Write(w, r, log, problem.BadRequest("to must be RFC 3339 date"))
+			return
+		}
+		if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+			problem.Write(w, r, log, problem.BadRequest("from must be before to"))
+			return
+		}
+
+		rejections, err := store.ListRejections(r.Context(), filter)
+		if err != nil {
+			log.Error("Failed to list rejections", logger.Error(err))
+			problem.Error(w, r, log, err)
+			return
+		}
+
+		if rejections == nil {
+			rejections = []models.Rejection{}
+		}
+		writeJSON(w, r, log, http.StatusOK, rejections)
+	}
+}
+
+// GetRejectionHandler godoc
+//
+//	@Summary		Отклоненное сообщение
+//	@Description	Возвращает отклоненное сообщение брокера по ID
+//	@Tags			rejections
+//	@Param			id	path		string	true	"ID отклоненного сообщения"
+//	@Success		200	{object}	models.Rejection
+//	@Failure		404	{object}	problem.Problem	"rejection not found"
+//	@Failure		500	{object}	problem.Problem	"internal server error"
+//	@Router			/api/v1/admin/rejections/{id} [get]
+func GetRejectionHandler(log logger.Logger, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())), logger.Field("rejection_id", r.PathValue("id")))
+
+		rejection, err := store.GetRejection(r.Context(), r.PathValue("id"))
+		if err != nil {
+			log.Debug("Failed to get rejection", logger.Error(err))
+			problem.Error(w, r, log, err)
+			return
+		}
+
+		writeJSON(w, r, log, http.StatusOK, rejection)
+	}
+}
+
+// ReplayRejectionHandler godoc
+//
+//	@Summary		Повторить отклоненное сообщение
+//	@Description	Сохраняет исправленный заказ отклоненного сообщения так же, как POST /api/v1/orders,
+//	@Description	и отмечает сообщение повторенным. Исходный payload не изменяется
+//	@Tags			rejections
+//	@Accept			json
+//	@Param			id				path		string			true	"ID отклоненного сообщения"
+//	@Param			order			body		models.Order	true	"Исправленный заказ"
+//	@Param			Schema-Version	header		string			false	"Версия схемы заказа"
+//	@Success		201		{object}	OrderSaveResult	"order created"
+//	@Success		200		{object}	OrderSaveResult	"order updated or ignored"
+//	@Failure		400		{object}	problem.Problem	"invalid order"
+//	@Failure		404		{object}	problem.Problem	"rejection not found"
+//	@Failure		409		{object}	problem.Problem	"order conflicts with another order"
+//	@Failure		422		{object}	problem.Problem	"business rules violated"
+//	@Failure		500		{object}	problem.Problem	"internal server error"
+//	@Router			/api/v1/admin/rejections/{id}/replay [post]
+func ReplayRejectionHandler(log logger.Logger, store storage.Storage, schemas *schema.Registry, validate *validator.Validate, orderRules *rules.Validator, notifier events.Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())), logger.Field("rejection_id", id))
+
+		// checking rejection before saving order, so orders are not saved by unknown IDs
+		if _, err := store.GetRejection(r.Context(), id); err != nil {
+			log.Debug("Failed to get rejection", logger.Error(err))
+			problem.Error(w, r, log, err)
+			return
+		}
+
+		var order models.Order
+		if !decodeOrder(w, r, log, schemas, &order) {
+			return
+		}
+
+		status, result, ok := ingestOrder(w, r, log, store, validate, orderRules, notifier, &order)
+		if !ok {
+			return
+		}
+
+		if err := store.MarkRejectionReplayed(r.Context(), id, caller(r)); err != nil {
+			// order is already saved, so replay is reported as successful
+			log.Error("Failed to mark rejection replayed", logger.Error(err))
+		} else {
+			log.Info("Rejection replayed", logger.Field("order_uid", order.OrderUID))
+		}
+		writeJSON(w, r, log, status, result)
+	}
+}
+
+// parseTime parses RFC 3339 time query parameter. Empty parameter means zero time
+func parseTime(param string) (time.Time, error) {
+	if param == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, param)
+}
@@ -0,0 +1,40 @@
+package serverhandlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/server/compress"
+	"wb-tech-l0/internal/server/problem"
+)
+
+// writeBody writes already encoded body with given status code and content type
+func writeBody(w http.ResponseWriter, log logger.Logger, status int, contentType string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		// client could be gone already, nothing else to do here
+		log.Debug("Failed to write response", logger.Error(err))
+	}
+}
+
+// writeEncoded writes pre-encoded (compressed) body with 200 status.
+// Content-Encoding is set, so compression middleware passes it as is
+func writeEncoded(w http.ResponseWriter, log logger.Logger, encoded *compress.Encoded) {
+	w.Header().Set("Content-Encoding", encoded.Encoding)
+	writeBody(w, log, http.StatusOK, encoded.ContentType, encoded.Body)
+}
+
+// writeJSON encodes value to JSON and writes it with given status code
+func writeJSON(w http.ResponseWriter, r *http.Request, log logger.Logger, status int, value any) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		log.Error("Failed to encode response", logger.Error(err))
+		problem.Write(w, r, log, problem.Internal())
+		return
+	}
+	writeBody(w, log, status, "application/json", body)
+}
@@ -0,0 +1,58 @@
+package serverhandlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/schema"
+	"wb-tech-l0/internal/server/middlewares"
+	"wb-tech-l0/internal/server/problem"
+)
+
+// ListSchemasHandler godoc
+//
+//	@Summary		Список схем сообщений
+//	@Description	Возвращает субъекты JSON Schema сообщений с доступными и текущей версиями.
+//	@Description	Версия сообщения передается в заголовке schema-version, старые версии приводятся к текущей
+//	@Tags			schemas
+//	@Success		200	{array}	schema.Subject
+//	@Router			/api/schemas [get]
+func ListSchemasHandler(log logger.Logger, schemas *schema.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())))
+		writeJSON(w, r, log, http.StatusOK, schemas.Subjects())
+	}
+}
+
+// GetSchemaHandler godoc
+//
+//	@Summary		Получить схему сообщения
+//	@Description	Возвращает JSON Schema (draft 2020-12) версии субъекта
+//	@Tags			schemas
+//	@Produce		application/schema+json
+//	@Param			subject	path		string	true	"Субъект схемы, например order"
+//	@Param			version	path		int		true	"Версия схемы"
+//	@Success		200		{object}	object
+//	@Failure		404		{object}	problem.Problem	"schema not found"
+//	@Router			/api/schemas/{subject}/{version} [get]
+func GetSchemaHandler(log logger.Logger, schemas *schema.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())))
+
+		version, err := strconv.Atoi(r.PathValue("version"))
+		if err != nil {
+			problem.Write(w, r, log, problem.NotFound("schema not found"))
+			return
+		}
+		body, ok := schemas.Schema(r.PathValue("subject"), version)
+		if !ok {
+			problem.Write(w, r, log, problem.NotFound("schema not found"))
+			return
+		}
+
+		// schemas are embedded in binary and never change while it runs
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		writeBody(w, log, http.StatusOK, "application/schema+json", body)
+	}
+}
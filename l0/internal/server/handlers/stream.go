@@ -0,0 +1,205 @@
+package serverhandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/events"
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/money"
+	"wb-tech-l0/internal/pii"
+	"wb-tech-l0/internal/server/middlewares"
+	"wb-tech-l0/internal/server/problem"
+)
+
+// OrderSummary is a short order view without PII.
+// @Description Short order info sent by orders stream.
+type OrderSummary struct {
+	// Unique order identifier
+	OrderUID string `json:"order_uid"`
+	// Tracking number
+	TrackNumber string `json:"track_number"`
+	// Delivery service
+	DeliveryService string `json:"delivery_service"`
+	// Payment amount
+	Amount *int `json:"amount"`
+	// Payment currency
+	Currency string `json:"currency"`
+	// Payment amount formatted for order locale, for example $18.17
+	AmountFormatted string `json:"amount_formatted,omitempty"`
+	// Number of items
+	ItemsCount int `json:"items_count"`
+	// Current order status
+	Status models.OrderStatus `json:"status"`
+	// Order creation date
+	DateCreated time.Time `json:"date_created"`
+}
+
+// newOrderSummary creates OrderSummary from order
+func newOrderSummary(o *models.Order) *OrderSummary {
+	summary := &OrderSummary{
+		OrderUID:        o.OrderUID,
+		TrackNumber:     o.TrackNumber,
+		DeliveryService: o.DeliveryService,
+		Amount:          o.Payment.Amount,
+		Currency:        o.Payment.Currency,
+		ItemsCount:      len(o.Items),
+		Status:          o.Status,
+		DateCreated:     o.DateCreated,
+	}
+	if money.ValidCurrency(o.Payment.Currency) {
+		summary.AmountFormatted = o.Payment.Amounts().Amount.Format(o.Locale)
+	}
+	return summary
+}
+
+// OrdersStreamHandler godoc
+//
+//	@Summary		Поток новых заказов (SSE)
+//	@Description	Отправляет события order (text/event-stream) для каждого сохраненного заказа.
+//	@Description	Поддерживает продолжение с Last-Event-ID из ограниченного буфера последних событий.
+//	@Description	Медленные клиенты отключаются
+//	@Tags			order
+//	@Produce		text/event-stream
+//	@Param			delivery_service	query		string	false	"Фильтр по службе доставки"
+//	@Param			view				query		string	false	"summary (по умолчанию) или full"	Enums(summary, full)
+//	@Param			Last-Event-ID		header		string	false	"ID последнего полученного события"
+//	@Success		200					{object}	OrderSummary
+//	@Failure		400					{object}	problem.Problem	"invalid view"
+//	@Failure		503					{object}	problem.Problem	"too many stream clients"
+//	@Router			/api/v1/orders/stream [get]
+func OrdersStreamHandler(log logger.Logger, hub *events.Hub, masker *pii.Masker, heartbeat time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := middlewares.GetRequestID(r.Context())
+		log := log.With(logger.Field("request_id", requestID))
+
+		// parsing filters
+		deliveryService := r.URL.Query().Get("delivery_service")
+		view := r.URL.Query().Get("view")
+		if view == "" {
+			view = "summary"
+		}
+		if view != "summary" && view != "full" {
+			problem.Write(w, r, log, problem.BadRequest("view must be summary or full"))
+			return
+		}
+
+		// browsers send Last-Event-ID header on reconnect,
+		// query parameter is for clients that can't set headers
+		lastEventID := r.Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = r.URL.Query().Get("last_event_id")
+		}
+
+		backlog, sub, err := hub.Subscribe(lastEventID)
+		if err != nil {
+			if errors.Is(err, events.ErrTooManySubscribers) {
+				log.Warn("Too many orders stream clients")
+				w.Header().Set("Retry-After", "5")
+				problem.Write(w, r, log, problem.New(http.StatusServiceUnavailable, problem.CodeUnavailable, "too many stream clients"))
+				return
+			}
+			problem.Error(w, r, log, err)
+			return
+		}
+		defer sub.Close()
+
+		principal := auth.PrincipalFrom(r.Context())
+		rc := http.NewResponseController(w)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// disabling nginx buffering of stream
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		log.Debug("Orders stream client connected", logger.Field("backlog", len(backlog)))
+
+		// stream is long-lived, so server write timeout is extended before every write.
+		// deadline is longer than heartbeat, so idle stream is not timed out
+		extendDeadline := func() error {
+			if err := rc.SetWriteDeadline(time.Now().Add(heartbeat * 2)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+			return nil
+		}
+
+		// send writes event if it passes filters and flushes it
+		send := func(event events.Event) error {
+			if deliveryService != "" && event.Order.DeliveryService != deliveryService {
+				return nil
+			}
+
+			var payload interface{} = newOrderSummary(event.Order)
+			if view == "full" {
+				payload = masker.Apply(event.Order, principal)
+			}
+			data, err := json.Marshal(payload)
+			if err != nil {
+				return err
+			}
+
+			if err := extendDeadline(); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: order\ndata: %s\n\n", event.ID, data); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+
+		// sending missed events first
+		for _, event := range backlog {
+			if err := send(event); err != nil {
+				log.Debug("Failed to send orders stream backlog", logger.Error(err))
+				return
+			}
+		}
+		// flushing headers even if there is no backlog
+		if err := extendDeadline(); err != nil {
+			log.Debug("Failed to extend orders stream deadline", logger.Error(err))
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			log.Debug("Failed to flush orders stream", logger.Error(err))
+			return
+		}
+
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				log.Debug("Orders stream client disconnected")
+				return
+			case event, ok := <-sub.C:
+				if !ok {
+					// hub closed channel because client didn't keep up
+					log.Warn("Orders stream client dropped as slow")
+					return
+				}
+				if err := send(event); err != nil {
+					log.Debug("Failed to send orders stream event", logger.Error(err))
+					return
+				}
+			case <-ticker.C:
+				// comment line keeps connection alive through proxies
+				if err := extendDeadline(); err != nil {
+					return
+				}
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+				if err := rc.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
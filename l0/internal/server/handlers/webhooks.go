@@ -0,0 +1,197 @@
+package serverhandlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/server/middlewares"
+	"wb-tech-l0/internal/server/problem"
+	"wb-tech-l0/internal/storage"
+)
+
+// maxWebhookBody is a maximum size of webhook creation request body
+const maxWebhookBody = 64 << 10
+
+// default and maximum number of returned webhook deliveries
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+// CreateWebhookHandler godoc
+//
+//	@Summary		Создать webhook
+//	@Description	Подписывает URL на события заказов. Запросы подписываются HMAC-SHA256 с секретом webhook.
+//	@Description	Поддерживается только событие order.saved, неизвестные события отклоняются с 400
+//	@Description	Секрет не возвращается в ответах
+//	@Tags			webhooks
+//	@Accept			json
+//	@Param			webhook	body		models.Webhook	true	"Webhook (url, secret, events: order.saved, delivery_services)"
+//	@Success		201		{object}	models.Webhook
+//	@Failure		400		{object}	problem.Problem	"invalid webhook"
+//	@Failure		500		{object}	problem.Problem	"internal server error"
+//	@Router			/api/v1/admin/webhooks [post]
+func CreateWebhookHandler(log logger.Logger, store storage.Storage, validate *validator.Validate) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())))
+
+		var webhook models.Webhook
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&webhook); err != nil {
+			log.Debug("Invalid webhook JSON", logger.Error(err))
+			problem.Write(w, r, log, problem.BadRequest("request body must be webhook JSON object"))
+			return
+		}
+
+		if err := validate.Struct(webhook); err != nil {
+			log.Debug("Invalid webhook", logger.Error(err))
+			problem.Error(w, r, log, err)
+			return
+		}
+
+		if err := store.CreateWebhook(r.Context(), &webhook); err != nil {
+			log.Error("Failed to create webhook", logger.Error(err))
+			problem.Error(w, r, log, err)
+			return
+		}
+
+		log.Info("Webhook created", logger.Field("webhook_id", webhook.ID), logger.Field("url", webhook.URL))
+		webhook.Secret = ""
+		writeJSON(w, r, log, http.StatusCreated, webhook)
+	}
+}
+
+// ListWebhooksHandler godoc
+//
+//	@Summary		Список webhook
+//	@Description	Возвращает все webhook без секретов
+//	@Tags			webhooks
+//	@Success		200	{array}		models.Webhook
+//	@Failure		500	{object}	problem.Problem	"internal server error"
+//	@Router			/api/v1/admin/webhooks [get]
+func ListWebhooksHandler(log logger.Logger, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())))
+
+		webhooks, err := store.ListWebhooks(r.Context())
+		if err != nil {
+			log.Error("Failed to list webhooks", logger.Error(err))
+			problem.Error(w, r, log, err)
+			return
+		}
+
+		// secrets are never returned
+		for i := range webhooks {
+			webhooks[i].Secret = ""
+		}
+		if webhooks == nil {
+			webhooks = []models.Webhook{}
+		}
+		writeJSON(w, r, log, http.StatusOK, webhooks)
+	}
+}
+
+// DeleteWebhookHandler godoc
+//
+//	@Summary		Удалить webhook
+//	@Description	Удаляет webhook вместе с журналом доставок
+//	@Tags			webhooks
+//	@Param			id	path	string	true	"ID webhook"
+//	@Success		204
+//	@Failure		404	{object}	problem.Problem	"webhook not found"
+//	@Failure		500	{object}	problem.Problem	"internal server error"
+//	@Router			/api/v1/admin/webhooks/{id} [delete]
+func DeleteWebhookHandler(log logger.Logger, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())), logger.Field("webhook_id", r.PathValue("id")))
+
+		if err := store.DeleteWebhook(r.Context(), r.PathValue("id")); err != nil {
+			log.Debug("Failed to delete webhook", logger.Error(err))
+			problem.Error(w, r, log, err)
+			return
+		}
+
+		log.Info("Webhook deleted")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// EnableWebhookHandler godoc
+//
+//	@Summary		Включить webhook
+//	@Description	Включает webhook, отключенный после неудачных доставок, и сбрасывает счетчик ошибок
+//	@Tags			webhooks
+//	@Param			id	path	string	true	"ID webhook"
+//	@Success		204
+//	@Failure		404	{object}	problem.Problem	"webhook not found"
+//	@Failure		500	{object}	problem.Problem	"internal server error"
+//	@Router			/api/v1/admin/webhooks/{id}/enable [post]
+func EnableWebhookHandler(log logger.Logger, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())), logger.Field("webhook_id", r.PathValue("id")))
+
+		if err := store.EnableWebhook(r.Context(), r.PathValue("id")); err != nil {
+			log.Debug("Failed to enable webhook", logger.Error(err))
+			problem.Error(w, r, log, err)
+			return
+		}
+
+		log.Info("Webhook enabled")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ListWebhookDeliveriesHandler godoc
+//
+//	@Summary		Журнал доставок webhook
+//	@Description	Возвращает последние попытки доставки событий webhook, новые первыми
+//	@Tags			webhooks
+//	@Param			id		path		string	true	"ID webhook"
+//	@Param			limit	query		int		false	"Количество попыток (по умолчанию 50, максимум 500)"
+//	@Success		200		{array}		models.WebhookDelivery
+//	@Failure		400		{object}	problem.Problem	"invalid limit"
+//	@Failure		404		{object}	problem.Problem	"webhook not found"
+//	@Failure		500		{object}	problem.Problem	"internal server error"
+//	@Router			/api/v1/admin/webhooks/{id}/deliveries [get]
+func ListWebhookDeliveriesHandler(log logger.Logger, store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(logger.Field("request_id", middlewares.GetRequestID(r.Context())), logger.Field("webhook_id", r.PathValue("id")))
+
+		limit, err := parseLimit(r.URL.Query().Get("limit"), defaultDeliveriesLimit, maxDeliveriesLimit)
+		if err != nil {
+			problem.Write(w, r, log, problem.BadRequest("limit must be positive integer"))
+			return
+		}
+
+		deliveries, err := store.ListWebhookDeliveries(r.Context(), r.PathValue("id"), limit)
+		if err != nil {
+			log.Debug("Failed to list webhook deliveries", logger.Error(err))
+			problem.Error(w, r, log, err)
+			return
+		}
+
+		if deliveries == nil {
+			deliveries = []models.WebhookDelivery{}
+		}
+		writeJSON(w, r, log, http.StatusOK, deliveries)
+	}
+}
+
+// parseLimit parses limit query parameter. Empty parameter means
+// defaultLimit, greater values are clamped to maxLimit
+func parseLimit(param string, defaultLimit, maxLimit int) (int, error) {
+	if param == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.Atoi(param)
+	if err != nil || limit < 1 {
+		return 0, strconv.ErrSyntax
+	}
+	return min(limit, maxLimit), nil
+}
@@ -0,0 +1,71 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/logger"
+)
+
+// AuthMiddleware authenticates every request with authenticator
+// and puts auth.Principal to request context. Requests without valid
+// credentials are rejected with 401. Access to endpoints is checked
+// by RequireRoles, so this middleware must be placed before it
+func AuthMiddleware(log logger.Logger, authenticator *auth.Authenticator, writeError ErrorWriter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := authenticator.Authenticate(r)
+			if err != nil {
+				log.Warn("Request authentication denied",
+					logger.Field("request_id", GetRequestID(r.Context())),
+					logger.Field("method", r.Method),
+					logger.Field("path", r.URL.Path),
+					logger.Field("remote_addr", r.RemoteAddr),
+					logger.Error(err),
+				)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+				detail := "invalid credentials"
+				if errors.Is(err, auth.ErrNoCredentials) {
+					detail = "authentication required"
+				}
+				writeError(w, r, http.StatusUnauthorized, detail)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// RequireRoles allows request only if its principal has any of given roles.
+// Otherwise request is rejected with 403 (or 401 if it is not authenticated)
+func RequireRoles(log logger.Logger, writeError ErrorWriter, roles ...auth.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := auth.PrincipalFrom(r.Context())
+			if principal == nil {
+				log.Warn("Request access denied: not authenticated",
+					logger.Field("request_id", GetRequestID(r.Context())),
+					logger.Field("path", r.URL.Path),
+				)
+				writeError(w, r, http.StatusUnauthorized, "authentication required")
+				return
+			}
+
+			if !principal.HasAnyRole(roles...) {
+				log.Warn("Request access denied: missing role",
+					logger.Field("request_id", GetRequestID(r.Context())),
+					logger.Field("path", r.URL.Path),
+					logger.Field("subject", principal.Subject),
+					logger.Field("roles", principal.Roles),
+					logger.Field("required_roles", roles),
+				)
+				writeError(w, r, http.StatusForbidden, "insufficient permissions")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
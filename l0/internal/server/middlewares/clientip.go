@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ClientIP returns request client IP. If request came from trusted proxy,
+// X-Forwarded-For is walked from right to left and first address not from
+// trusted proxies is returned, so clients can't spoof it with own header
+func ClientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !isTrusted(addr, trustedProxies) {
+		return host
+	}
+
+	// header can be set multiple times, each of them is a list
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			// garbage in header, trusting nothing before it
+			return host
+		}
+		if !isTrusted(hop, trustedProxies) {
+			return hop.String()
+		}
+		host = hop.String()
+	}
+
+	// all hops are trusted, returning the farthest one
+	return host
+}
+
+// isTrusted reports whether addr is in any of trusted prefixes
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/server/compress"
+)
+
+// CompressionMiddleware compresses responses with encoding negotiated
+// by compressor. Only responses with allowed content type and body not
+// smaller than compressor.MinSize are compressed. Responses that already
+// have Content-Encoding (for example, pre-encoded cached bodies) are passed as is
+func CompressionMiddleware(log logger.Logger, compressor *compress.Compressor) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// response depends on Accept-Encoding even if it is not compressed
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := compressor.Negotiate(r)
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				compressor:     compressor,
+				encoding:       encoding,
+				status:         http.StatusOK,
+			}
+
+			next.ServeHTTP(cw, r)
+
+			// not deferred: on handler panic buffered body must be dropped,
+			// so recovery middleware could still write its own response
+			if err := cw.close(); err != nil {
+				log.Debug("Failed to finish compressed response",
+					logger.Field("request_id", GetRequestID(r.Context())),
+					logger.Error(err),
+				)
+			}
+		})
+	}
+}
+
+// compressWriter buffers response body until it reaches minimum size
+// and then decides whether to compress it. Header is written
+// only after decision, as Content-Encoding depends on it
+type compressWriter struct {
+	http.ResponseWriter
+	compressor *compress.Compressor
+	encoding   string
+
+	status      int
+	wroteHeader bool
+	buf         []byte
+
+	// decided is set after compression decision is made and header is written
+	decided bool
+	// encoder is set if response is compressed
+	encoder compress.Writer
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = status
+
+	// informational and bodiless responses are passed immediately
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		cw.decided = true
+		cw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.decided {
+		if cw.encoder != nil {
+			return cw.encoder.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.compressor.MinSize() {
+		if err := cw.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush makes decision with buffered data and flushes encoder and wrapped writer
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if !cw.wroteHeader {
+			cw.WriteHeader(http.StatusOK)
+		}
+		if err := cw.decide(); err != nil {
+			return
+		}
+	}
+	if cw.encoder != nil {
+		if err := cw.encoder.Flush(); err != nil {
+			return
+		}
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns wrapped writer for http.ResponseController
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// decide writes header with or without Content-Encoding
+// and writes buffered data to encoder or wrapped writer
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	h := cw.Header()
+
+	if h.Get("Content-Encoding") == "" &&
+		len(cw.buf) >= cw.compressor.MinSize() &&
+		cw.compressor.Allowed(h.Get("Content-Type")) {
+		encoder, err := cw.compressor.NewWriter(cw.encoding, cw.ResponseWriter)
+		if err == nil {
+			cw.encoder = encoder
+			h.Set("Content-Encoding", cw.encoding)
+			// length is changed by compression
+			h.Del("Content-Length")
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if cw.encoder != nil {
+		_, err := cw.encoder.Write(buf)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return err
+}
+
+// close finishes response: makes decision for small bodies and closes encoder
+func (cw *compressWriter) close() error {
+	if !cw.decided {
+		// handler wrote nothing, status is default
+		if !cw.wroteHeader {
+			cw.wroteHeader = true
+		}
+		if err := cw.decide(); err != nil {
+			return err
+		}
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Close()
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DeprecationMiddleware marks responses of deprecated endpoints.
+// It sets Deprecation header (RFC 9745) with the date endpoint was deprecated,
+// Sunset header (RFC 8594) with the date after which endpoint can be removed
+// and Link header pointing to the successor endpoint, built by successor
+// function from request. Successor must escape path values it uses
+func DeprecationMiddleware(deprecation, sunset time.Time, successor func(r *http.Request) string) func(http.Handler) http.Handler {
+	// deprecation is structured field date, unix seconds prefixed with @
+	deprecationValue := "@" + strconv.FormatInt(deprecation.Unix(), 10)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", deprecationValue)
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			w.Header().Set("Link", "<"+successor(r)+`>; rel="successor-version"`)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
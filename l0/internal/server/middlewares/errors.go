@@ -0,0 +1,7 @@
+package middlewares
+
+import "net/http"
+
+// ErrorWriter writes error response for requests rejected by middlewares.
+// Middlewares don't know about response format, so it is provided by router
+type ErrorWriter func(w http.ResponseWriter, r *http.Request, status int, detail string)
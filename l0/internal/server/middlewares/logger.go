@@ -2,6 +2,7 @@ package middlewares
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"time"
 
@@ -16,7 +17,12 @@ type contextKey string
 // key for setting request id in request context
 const requestIDKey contextKey = "request_id"
 
-// LoggingMiddleware logs every http request before handlers.
+// LoggingMiddleware logs every http request before handlers
+// and writes access log record after them with response status,
+// written bytes and duration. Record level depends on status class:
+// 5xx is logged as error, 4xx as warning and others as info.
+// Responses aborted with panic (http.ErrAbortHandler passed on by RecoveryMiddleware)
+// are logged as errors before panic is passed on to net/http.
 // It sets requestIDKey in request context
 func LoggingMiddleware(log logger.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -26,6 +32,8 @@ func LoggingMiddleware(log logger.Logger) func(http.Handler) http.Handler {
 			if reqID == "" {
 				reqID = uuid.NewString()
 			}
+			// returning request id to client for chaining with logs
+			w.Header().Set("X-Request-ID", reqID)
 
 			// setting to request context
 			ctx := context.WithValue(r.Context(), requestIDKey, reqID)
@@ -41,13 +49,40 @@ func LoggingMiddleware(log logger.Logger) func(http.Handler) http.Handler {
 				logger.Field("user_agent", r.UserAgent()),
 			)
 
-			// handling request with context with its id
-			next.ServeHTTP(w, r.WithContext(ctx))
+			// wrapping writer to capture status and size
+			rw := newResponseWriter(w)
 
-			log.Debug("Request processed",
-				logger.Field("request_id", reqID),
-				logger.Field("duration", time.Since(start).String()),
-			)
+			// writing access record even if handler panics,
+			// so aborted responses don't disappear from logs
+			defer func() {
+				rec := recover()
+				fields := []logger.LogField{
+					logger.Field("request_id", reqID),
+					logger.Field("method", r.Method),
+					logger.Field("path", r.URL.Path),
+					logger.Field("status", rw.Status()),
+					logger.Field("size", rw.Size()),
+					logger.Field("duration", time.Since(start).String()),
+				}
+
+				if rec != nil {
+					log.Error("Request aborted", append(fields, logger.Field("panic", fmt.Sprint(rec)))...)
+					// net/http closes connection and suppresses logging of http.ErrAbortHandler
+					panic(rec)
+				}
+
+				switch status := rw.Status(); {
+				case status >= http.StatusInternalServerError:
+					log.Error("Request processed", fields...)
+				case status >= http.StatusBadRequest:
+					log.Warn("Request processed", fields...)
+				default:
+					log.Info("Request processed", fields...)
+				}
+			}()
+
+			// handling request with context with its id
+			next.ServeHTTP(rw, r.WithContext(ctx))
 		})
 	}
 }
@@ -0,0 +1,338 @@
+package middlewares
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/config"
+	"wb-tech-l0/internal/logger"
+	noplogger "wb-tech-l0/internal/logger/nop"
+	"wb-tech-l0/internal/server/compress"
+	"wb-tech-l0/internal/server/ratelimit"
+)
+
+func TestResponseWriter(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantSize   int64
+	}{
+		{
+			name:       "Implicit OK",
+			handler:    func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte("hello")) },
+			wantStatus: http.StatusOK,
+			wantSize:   5,
+		},
+		{
+			name:       "Explicit status",
+			handler:    func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNotFound) },
+			wantStatus: http.StatusNotFound,
+			wantSize:   0,
+		},
+		{
+			name: "Only first status",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			wantStatus: http.StatusCreated,
+			wantSize:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw := newResponseWriter(httptest.NewRecorder())
+			tt.handler(rw, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rw.Status() != tt.wantStatus {
+				t.Errorf("Status() = %d, want %d", rw.Status(), tt.wantStatus)
+			}
+			if rw.Size() != tt.wantSize {
+				t.Errorf("Size() = %d, want %d", rw.Size(), tt.wantSize)
+			}
+		})
+	}
+
+	t.Run("Flusher", func(t *testing.T) {
+		var w http.ResponseWriter = newResponseWriter(httptest.NewRecorder())
+		if _, ok := w.(http.Flusher); !ok {
+			t.Errorf("responseWriter does not implement http.Flusher")
+		}
+	})
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	writeError := func(w http.ResponseWriter, _ *http.Request, status int, _ string) {
+		w.WriteHeader(status)
+	}
+	panicking := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	})
+
+	handler := LoggingMiddleware(noplogger.New())(RecoveryMiddleware(noplogger.New(), writeError)(panicking))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if rec.Header().Get("X-Request-ID") == "" {
+		t.Errorf("X-Request-ID header is not set")
+	}
+}
+
+// errorsLogger records messages and fields of error records
+type errorsLogger struct {
+	*noplogger.Nop
+
+	messages []string
+	fields   map[string]interface{}
+}
+
+func (l *errorsLogger) Error(msg string, fields ...logger.LogField) {
+	l.messages = append(l.messages, msg)
+	for _, field := range fields {
+		l.fields[field.Key] = field.Value
+	}
+}
+
+func TestRecoveryMiddlewareAbort(t *testing.T) {
+	writeError := func(w http.ResponseWriter, _ *http.Request, status int, _ string) {
+		w.WriteHeader(status)
+	}
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+	}{
+		{
+			name: "Aborted response",
+			handler: func(http.ResponseWriter, *http.Request) {
+				panic(http.ErrAbortHandler)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "Panic after header is written",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("boom")
+			},
+			wantStatus: http.StatusAccepted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := &errorsLogger{Nop: noplogger.New(), fields: map[string]interface{}{}}
+			handler := LoggingMiddleware(log)(RecoveryMiddleware(noplogger.New(), writeError)(tt.handler))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("X-Request-ID", "req-1")
+			func() {
+				// panic is passed on to net/http to abort response
+				defer func() {
+					if rec := recover(); rec != http.ErrAbortHandler {
+						t.Errorf("panic = %v, want %v", rec, http.ErrAbortHandler)
+					}
+				}()
+				handler.ServeHTTP(httptest.NewRecorder(), r)
+			}()
+
+			if !slices.Contains(log.messages, "Request aborted") {
+				t.Fatalf("logged errors = %v, want aborted request", log.messages)
+			}
+			if log.fields["request_id"] != "req-1" || log.fields["status"] != tt.wantStatus {
+				t.Errorf("logged request_id = %v, status = %v, want req-1, %d", log.fields["request_id"], log.fields["status"], tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{
+			name:       "Direct client",
+			remoteAddr: "203.0.113.7:5000",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "Untrusted proxy header is ignored",
+			remoteAddr: "203.0.113.7:5000",
+			forwarded:  "198.51.100.1",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "Trusted proxy",
+			remoteAddr: "10.0.0.2:5000",
+			forwarded:  "198.51.100.1",
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "Spoofed hop before real client",
+			remoteAddr: "10.0.0.2:5000",
+			forwarded:  "1.1.1.1, 198.51.100.1, 10.0.0.3",
+			want:       "198.51.100.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := ClientIP(r, trusted); got != tt.want {
+				t.Errorf("ClientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	compressor := compress.New(&config.CompressionConfig{
+		Encodings:    []string{compress.EncodingGzip},
+		MinSize:      64,
+		ContentTypes: []string{"application/json"},
+	})
+	large := strings.Repeat("a", 1000)
+
+	tests := []struct {
+		name         string
+		contentType  string
+		encoding     string
+		body         string
+		wantEncoding string
+	}{
+		{name: "Large JSON", contentType: "application/json", body: large, wantEncoding: compress.EncodingGzip},
+		{name: "Small JSON", contentType: "application/json", body: "{}", wantEncoding: ""},
+		{name: "Not allowed type", contentType: "image/png", body: large, wantEncoding: ""},
+		{name: "Pre-encoded", contentType: "application/json", encoding: "zstd", body: large, wantEncoding: "zstd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CompressionMiddleware(noplogger.New(), compressor)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				_, _ = w.Write([]byte(tt.body))
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+
+			body := rec.Body.Bytes()
+			if tt.wantEncoding == compress.EncodingGzip {
+				gr, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatalf("gzip.NewReader() error = %v", err)
+				}
+				if body, err = io.ReadAll(gr); err != nil {
+					t.Fatalf("gzip read error = %v", err)
+				}
+			}
+			if string(body) != tt.body {
+				t.Errorf("body length = %d, want %d", len(body), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddlewareChain(t *testing.T) {
+	writeError := func(w http.ResponseWriter, _ *http.Request, status int, _ string) {
+		w.WriteHeader(status)
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		ipBurst       int
+		routeBurst    int
+		requests      int
+		wantStatus    int
+		wantLimit     string
+		wantRemaining string
+	}{
+		{
+			name:          "Route limiter is more restrictive",
+			ipBurst:       10,
+			routeBurst:    3,
+			requests:      1,
+			wantStatus:    http.StatusOK,
+			wantLimit:     "3",
+			wantRemaining: "2",
+		},
+		{
+			name:          "IP limiter is more restrictive",
+			ipBurst:       3,
+			routeBurst:    10,
+			requests:      2,
+			wantStatus:    http.StatusOK,
+			wantLimit:     "3",
+			wantRemaining: "1",
+		},
+		{
+			name:          "Rejecting route limiter",
+			ipBurst:       10,
+			routeBurst:    1,
+			requests:      2,
+			wantStatus:    http.StatusTooManyRequests,
+			wantLimit:     "1",
+			wantRemaining: "0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipLimiter := ratelimit.New(0.001, tt.ipBurst, time.Minute, 10)
+			routeLimiter := ratelimit.New(0.001, tt.routeBurst, time.Minute, 10)
+			handler := IPRateLimitMiddleware(noplogger.New(), ipLimiter, nil, writeError)(
+				RateLimitMiddleware(noplogger.New(), routeLimiter, nil, writeError)(ok))
+
+			var rec *httptest.ResponseRecorder
+			for range tt.requests {
+				rec = httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("RateLimit-Limit"); got != tt.wantLimit {
+				t.Errorf("RateLimit-Limit = %s, want %s", got, tt.wantLimit)
+			}
+			if got := rec.Header().Get("RateLimit-Remaining"); got != tt.wantRemaining {
+				t.Errorf("RateLimit-Remaining = %s, want %s", got, tt.wantRemaining)
+			}
+		})
+	}
+}
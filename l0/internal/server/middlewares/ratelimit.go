@@ -0,0 +1,88 @@
+package middlewares
+
+import (
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/server/ratelimit"
+)
+
+// RateLimitMiddleware limits requests rate with limiter.
+// Requests are keyed by authenticated principal (API key or JWT subject)
+// or by client IP (see ClientIP) for anonymous ones, so it must be placed
+// after AuthMiddleware to limit callers by their credentials.
+// Every response has RateLimit-* headers of the most restrictive of chained
+// limiters and rejected requests get 429 with Retry-After header
+func RateLimitMiddleware(log logger.Logger, limiter *ratelimit.Limiter, trustedProxies []netip.Prefix, writeError ErrorWriter) func(http.Handler) http.Handler {
+	return rateLimit(log, limiter, writeError, func(r *http.Request) string {
+		if principal := auth.PrincipalFrom(r.Context()); principal != nil && principal.Method != auth.MethodNone {
+			return "principal:" + principal.Subject
+		}
+		return "ip:" + ClientIP(r, trustedProxies)
+	})
+}
+
+// IPRateLimitMiddleware limits requests rate with limiter by client IP only.
+// It is placed before AuthMiddleware, so floods of requests with invalid
+// or missing credentials are rejected before authentication work
+func IPRateLimitMiddleware(log logger.Logger, limiter *ratelimit.Limiter, trustedProxies []netip.Prefix, writeError ErrorWriter) func(http.Handler) http.Handler {
+	return rateLimit(log, limiter, writeError, func(r *http.Request) string {
+		return "ip:" + ClientIP(r, trustedProxies)
+	})
+}
+
+// rateLimit limits requests rate with limiter by request key
+func rateLimit(log logger.Logger, limiter *ratelimit.Limiter, writeError ErrorWriter, key func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := key(r)
+			res := limiter.Allow(key)
+
+			// outer limiter could have set headers already, they are replaced
+			// only by more restrictive or rejecting limiter result
+			if !res.Allowed || moreRestrictive(w.Header(), res) {
+				w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+				w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+				w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+			}
+
+			if !res.Allowed {
+				log.Warn("Request rate limited",
+					logger.Field("request_id", GetRequestID(r.Context())),
+					logger.Field("path", r.URL.Path),
+					logger.Field("key", key),
+				)
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				writeError(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// moreRestrictive reports whether limiter result leaves fewer requests (or the same
+// number of requests for longer) than RateLimit-* headers already set in header
+func moreRestrictive(header http.Header, res ratelimit.Result) bool {
+	remaining, err := strconv.Atoi(header.Get("RateLimit-Remaining"))
+	if err != nil {
+		// headers are not set yet
+		return true
+	}
+	if res.Remaining != remaining {
+		return res.Remaining < remaining
+	}
+	reset, _ := strconv.Atoi(header.Get("RateLimit-Reset"))
+	return ceilSeconds(res.Reset) > reset
+}
+
+// ceilSeconds rounds duration up to whole seconds as headers require
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
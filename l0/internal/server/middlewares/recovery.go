@@ -0,0 +1,58 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"wb-tech-l0/internal/logger"
+)
+
+// RecoveryMiddleware recovers panics in next handlers, so one bad request
+// doesn't take down the connection without any trace.
+// It logs panic value and stack with request id and writes 500 response
+// with writeError if nothing was written yet.
+// It must be placed after LoggingMiddleware to have request id in context
+func RecoveryMiddleware(log logger.Logger, writeError ErrorWriter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// reusing wrapper from LoggingMiddleware if present
+			// to know whether handler already started the response
+			rw, ok := w.(*responseWriter)
+			if !ok {
+				rw = newResponseWriter(w)
+			}
+
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				// http.ErrAbortHandler is a sentinel used to abort response
+				// and must be passed to net/http which suppresses its logging.
+				// LoggingMiddleware still writes access record of aborted request
+				if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(rec)
+				}
+
+				log.Error("Recovered panic in HTTP handler",
+					logger.Field("request_id", GetRequestID(r.Context())),
+					logger.Field("method", r.Method),
+					logger.Field("path", r.URL.Path),
+					logger.Field("panic", fmt.Sprint(rec)),
+					logger.Field("stack", string(debug.Stack())),
+				)
+
+				// if handler already wrote header, we can't change the status.
+				// aborting to make client see broken response instead of truncated one
+				if rw.WroteHeader() {
+					panic(http.ErrAbortHandler)
+				}
+				writeError(rw, r, http.StatusInternalServerError, "internal server error")
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
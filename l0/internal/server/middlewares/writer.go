@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// responseWriter wraps http.ResponseWriter to capture
+// response status code and number of written bytes.
+// It still supports http.Flusher and http.Hijacker
+// of wrapped writer and Unwrap for http.ResponseController
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+}
+
+// newResponseWriter creates and returns responseWriter wrapping w
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w}
+}
+
+// WriteHeader captures status code and passes it to wrapped writer.
+// Only the first call is captured as the next ones are ignored by net/http
+func (rw *responseWriter) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes data to wrapped writer and counts written bytes.
+// If header was not written, status is implicitly 200
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.size += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher if wrapped writer supports it
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		if !rw.wroteHeader {
+			rw.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if wrapped writer supports it.
+// After hijacking, status is reported as 101 Switching Protocols
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("underlying response writer does not support hijacking")
+	}
+	if !rw.wroteHeader {
+		rw.status = http.StatusSwitchingProtocols
+		rw.wroteHeader = true
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns wrapped writer for http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Status returns captured status code.
+// It is 200 if handler wrote nothing, as net/http does
+func (rw *responseWriter) Status() int {
+	if !rw.wroteHeader {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
+// Size returns number of written response body bytes
+func (rw *responseWriter) Size() int64 {
+	return rw.size
+}
+
+// WroteHeader reports whether response header was already written
+func (rw *responseWriter) WroteHeader() bool {
+	return rw.wroteHeader
+}
@@ -0,0 +1,189 @@
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/server/middlewares"
+	"wb-tech-l0/internal/storage"
+)
+
+// ContentType is a media type of RFC 7807 problem details responses
+const ContentType = "application/problem+json"
+
+// typeBase is a prefix of problem type URIs.
+// Problem type is built as typeBase + Code, so clients
+// can rely on both of them
+const typeBase = "urn:wb-tech-l0:problem:"
+
+// Stable error codes. Clients must rely on them instead of
+// parsing title or detail, which are human-readable and can change
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidation       = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeRuleViolation    = "rule_violation"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeRateLimited      = "rate_limited"
+	CodeTimeout          = "timeout"
+	CodeUnavailable      = "unavailable"
+	CodeInternal         = "internal_error"
+)
+
+// Problem is an RFC 7807 problem details response body.
+// @Description Error response in RFC 7807 problem details format.
+type Problem struct {
+	// URI reference that identifies the problem type
+	Type string `json:"type"`
+	// Short human-readable summary of the problem type
+	Title string `json:"title"`
+	// HTTP status code
+	Status int `json:"status"`
+	// Human-readable explanation specific to this occurrence
+	Detail string `json:"detail,omitempty"`
+	// URI reference that identifies this occurrence (request path)
+	Instance string `json:"instance,omitempty"`
+	// Stable machine-readable error code
+	Code string `json:"code"`
+	// Request ID for chaining with server logs
+	RequestID string `json:"request_id,omitempty"`
+	// Field errors, set for validation problems
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes single invalid field of validation problem.
+// @Description Single invalid field of validation problem.
+type FieldError struct {
+	// Field path, for example Order.Delivery.Phone
+	Field string `json:"field"`
+	// Failed validation rule, for example required
+	Rule string `json:"rule"`
+	// Violation details, set for business rules violations
+	Detail string `json:"detail,omitempty"`
+}
+
+// New creates and returns Problem with given status, code and detail.
+// Title is taken from HTTP status text
+func New(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   typeBase + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// BadRequest creates and returns Problem for malformed requests
+func BadRequest(detail string) *Problem {
+	return New(http.StatusBadRequest, CodeBadRequest, detail)
+}
+
+// NotFound creates and returns Problem for missing resources
+func NotFound(detail string) *Problem {
+	return New(http.StatusNotFound, CodeNotFound, detail)
+}
+
+// MethodNotAllowed creates and returns Problem for unsupported request methods
+func MethodNotAllowed(detail string) *Problem {
+	return New(http.StatusMethodNotAllowed, CodeMethodNotAllowed, detail)
+}
+
+// Internal creates and returns Problem for unexpected server errors.
+// Detail is always generic to not leak internal errors to clients
+func Internal() *Problem {
+	return New(http.StatusInternalServerError, CodeInternal, "internal server error")
+}
+
+// FromError maps error to Problem with stable error code.
+// Known errors are storage.ErrNotFound, storage.ErrUniqueViolation,
+// validator.ValidationErrors and timeouts. Errors of other packages
+// are mapped by handlers using them.
+// All other errors are mapped to internal error
+func FromError(err error) *Problem {
+	var validationErrs validator.ValidationErrors
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return NotFound("resource not found")
+	case errors.Is(err, storage.ErrUniqueViolation):
+		return New(http.StatusConflict, CodeConflict, "resource conflicts with existing one")
+	case errors.As(err, &validationErrs):
+		p := New(http.StatusBadRequest, CodeValidation, "request validation failed")
+		p.Errors = make([]FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			p.Errors = append(p.Errors, FieldError{Field: fe.Namespace(), Rule: fe.Tag()})
+		}
+		return p
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return New(http.StatusGatewayTimeout, CodeTimeout, "request timed out")
+	default:
+		return Internal()
+	}
+}
+
+// Write writes Problem to response with problem+json content type.
+// It fills Instance with request path and RequestID from request context
+func Write(w http.ResponseWriter, r *http.Request, log logger.Logger, p *Problem) {
+	p.Instance = r.URL.Path
+	p.RequestID = middlewares.GetRequestID(r.Context())
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		// client could be gone already, nothing else to do here
+		log.Debug("Failed to write problem response", logger.Field("request_id", p.RequestID), logger.Error(err))
+	}
+}
+
+// Error maps error to Problem with FromError and writes it to response
+func Error(w http.ResponseWriter, r *http.Request, log logger.Logger, err error) {
+	Write(w, r, log, FromError(err))
+}
+
+// Writer returns middlewares.ErrorWriter that writes problems,
+// so middlewares rejections have the same format as handler errors
+func Writer(log logger.Logger) middlewares.ErrorWriter {
+	return func(w http.ResponseWriter, r *http.Request, status int, detail string) {
+		Write(w, r, log, New(status, codeForStatus(status), detail))
+	}
+}
+
+// codeForStatus returns stable error code for status
+// of middlewares rejections
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	default:
+		return CodeInternal
+	}
+}
@@ -0,0 +1,79 @@
+package problem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+
+	"wb-tech-l0/internal/storage"
+)
+
+func TestFromError(t *testing.T) {
+	type payload struct {
+		Name string `validate:"required"`
+	}
+	validationErr := validator.New().Struct(payload{})
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "Not found",
+			err:        storage.ErrNotFound,
+			wantStatus: http.StatusNotFound,
+			wantCode:   CodeNotFound,
+		},
+		{
+			name:       "Wrapped not found",
+			err:        fmt.Errorf("get order: %w", storage.ErrNotFound),
+			wantStatus: http.StatusNotFound,
+			wantCode:   CodeNotFound,
+		},
+		{
+			name:       "Validation",
+			err:        validationErr,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   CodeValidation,
+		},
+		{
+			name:       "Conflict",
+			err:        fmt.Errorf("save order: %w", storage.ErrUniqueViolation),
+			wantStatus: http.StatusConflict,
+			wantCode:   CodeConflict,
+		},
+		{
+			name:       "Timeout",
+			err:        fmt.Errorf("get order failed: %w", context.DeadlineExceeded),
+			wantStatus: http.StatusGatewayTimeout,
+			wantCode:   CodeTimeout,
+		},
+		{
+			name:       "Unknown",
+			err:        errors.New("boom"),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   CodeInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := FromError(tt.err)
+			if p.Status != tt.wantStatus {
+				t.Errorf("FromError() status = %d, want %d", p.Status, tt.wantStatus)
+			}
+			if p.Code != tt.wantCode {
+				t.Errorf("FromError() code = %s, want %s", p.Code, tt.wantCode)
+			}
+			if p.Type != typeBase+tt.wantCode {
+				t.Errorf("FromError() type = %s, want %s", p.Type, typeBase+tt.wantCode)
+			}
+		})
+	}
+}
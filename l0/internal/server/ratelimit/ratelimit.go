@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket rate limiter with a bucket per key
+// (API key, client IP). Bucket is refilled with rate tokens per second
+// up to burst tokens and every allowed request takes one token.
+// Buckets not used for ttl are evicted, and number of buckets is
+// limited by maxBuckets to keep memory bounded.
+// It's methods are safe for concurrent use
+type Limiter struct {
+	rate       float64
+	burst      int
+	ttl        time.Duration
+	maxBuckets int
+
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	mu        sync.Mutex
+
+	now func() time.Time
+}
+
+// bucket is a token bucket of single key
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// Result is a result of Limiter.Allow
+type Result struct {
+	// Allowed reports whether request is allowed
+	Allowed bool
+	// Limit is a bucket capacity (burst)
+	Limit int
+	// Remaining is a number of requests left in bucket
+	Remaining int
+	// Reset is a time until bucket is full again
+	Reset time.Duration
+	// RetryAfter is a time until next request is allowed, set if request is not allowed
+	RetryAfter time.Duration
+}
+
+// New creates and returns Limiter with given rate (tokens per second),
+// burst (bucket capacity), bucket ttl and maximum number of buckets
+func New(rate float64, burst int, ttl time.Duration, maxBuckets int) *Limiter {
+	return &Limiter{
+		rate:       rate,
+		burst:      burst,
+		ttl:        ttl,
+		maxBuckets: maxBuckets,
+		buckets:    make(map[string]*bucket),
+		now:        time.Now,
+	}
+}
+
+// Allow takes token from key bucket and reports whether request is allowed
+func (l *Limiter) Allow(key string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	// sweeping stale buckets not more than once per ttl
+	if now.Sub(l.lastSweep) >= l.ttl {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		// if still there is no space, remove random bucket.
+		// it is at most one extra burst for evicted key
+		if len(l.buckets) >= l.maxBuckets {
+			// range over map is random
+			for k := range l.buckets {
+				delete(l.buckets, k)
+				break
+			}
+		}
+		b = &bucket{tokens: float64(l.burst), lastSeen: now}
+		l.buckets[key] = b
+	}
+
+	// refilling tokens for elapsed time
+	elapsed := now.Sub(b.lastSeen).Seconds()
+	b.tokens = math.Min(float64(l.burst), b.tokens+elapsed*l.rate)
+	b.lastSeen = now
+
+	res := Result{Limit: l.burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.duration(1 - b.tokens)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = l.duration(float64(l.burst) - b.tokens)
+	return res
+}
+
+// Len returns current number of buckets
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// sweep removes buckets not used for ttl
+func (l *Limiter) sweep(now time.Time) {
+	for k, b := range l.buckets {
+		if now.Sub(b.lastSeen) >= l.ttl {
+			delete(l.buckets, k)
+		}
+	}
+	l.lastSweep = now
+}
+
+// duration returns time needed to refill given number of tokens
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for Limiter
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestLimiter(rate float64, burst int, ttl time.Duration, maxBuckets int) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := New(rate, burst, ttl, maxBuckets)
+	l.now = clock.Now
+	return l, clock
+}
+
+func TestLimiterAllow(t *testing.T) {
+	l, clock := newTestLimiter(1, 3, time.Minute, 100)
+
+	// burst is allowed at once
+	for i := 0; i < 3; i++ {
+		if res := l.Allow("a"); !res.Allowed {
+			t.Fatalf("request %d: Allow() = false, want true", i)
+		}
+	}
+
+	res := l.Allow("a")
+	if res.Allowed {
+		t.Fatalf("Allow() over burst = true, want false")
+	}
+	if res.RetryAfter <= 0 || res.RetryAfter > time.Second {
+		t.Errorf("RetryAfter = %v, want (0, 1s]", res.RetryAfter)
+	}
+	if res.Remaining != 0 {
+		t.Errorf("Remaining = %d, want 0", res.Remaining)
+	}
+
+	// other keys have own buckets
+	if res := l.Allow("b"); !res.Allowed {
+		t.Errorf("Allow() for other key = false, want true")
+	}
+
+	// bucket is refilled with rate
+	clock.Advance(time.Second)
+	if res := l.Allow("a"); !res.Allowed {
+		t.Errorf("Allow() after refill = false, want true")
+	}
+}
+
+func TestLimiterEviction(t *testing.T) {
+	t.Run("Stale buckets", func(t *testing.T) {
+		l, clock := newTestLimiter(1, 1, time.Minute, 100)
+		l.Allow("a")
+		l.Allow("b")
+
+		clock.Advance(2 * time.Minute)
+		l.Allow("c")
+
+		if got := l.Len(); got != 1 {
+			t.Errorf("Len() = %d, want 1", got)
+		}
+	})
+
+	t.Run("Max buckets", func(t *testing.T) {
+		l, _ := newTestLimiter(1, 1, time.Hour, 10)
+		for i := 0; i < 100; i++ {
+			l.Allow(strconv.Itoa(i))
+		}
+
+		if got := l.Len(); got != 10 {
+			t.Errorf("Len() = %d, want 10", got)
+		}
+	})
+}
@@ -2,23 +2,246 @@ package server
 
 import (
 	"net/http"
+	"net/url"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	httpSwagger "github.com/swaggo/http-swagger"
 
-	"wb-tech-l0/internal/cache"
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/broker"
+	"wb-tech-l0/internal/config"
+	"wb-tech-l0/internal/events"
 	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/pii"
+	"wb-tech-l0/internal/replay"
+	"wb-tech-l0/internal/rules"
+	"wb-tech-l0/internal/schema"
+	"wb-tech-l0/internal/server/compress"
 	serverHandlers "wb-tech-l0/internal/server/handlers"
 	"wb-tech-l0/internal/server/middlewares"
+	"wb-tech-l0/internal/server/problem"
+	"wb-tech-l0/internal/server/ratelimit"
 	"wb-tech-l0/internal/storage"
 )
 
-// NewRouter creates and returns a new HTTP router with all handlers registered
-func NewRouter(log logger.Logger, cache cache.Cache, storage storage.Storage) http.Handler {
+// apiV1 is a prefix of current API version paths
+const apiV1 = "/api/v1"
+
+// Dependencies are components used by router handlers
+type Dependencies struct {
+	// Authenticator authenticates API requests
+	Authenticator *auth.Authenticator
+	// Orders is a cache of orders and their encoded responses
+	Orders *serverHandlers.OrderCache
+	// Storage is an orders storage
+	Storage storage.Storage
+	// Hub broadcasts saved orders to streams
+	Hub *events.Hub
+	// Waiters are requests waiting for orders to be saved
+	Waiters *events.Waiters
+	// Schemas is a registry of message schemas
+	Schemas *schema.Registry
+	// Rules validates orders by business rules
+	Rules *rules.Validator
+	// Notifier notifies in-process subscribers of orders saved by API
+	Notifier events.Notifier
+	// Consumer is an orders consumer controlled by admin API
+	Consumer broker.Broker
+	// Replays runs replays of broker messages. Replays routes are not registered if it is nil
+	Replays *replay.Runner
+}
+
+// NewRouter creates and returns a new HTTP router with all handlers registered.
+// Routes are defined with method and wildcard patterns, so 404 and 405
+// responses are generated by router itself (and rendered as problem+json).
+// All API routes except docs, schemas, readiness and public metrics require authentication and one of route roles
+func NewRouter(cfg *config.ServerConfig, log logger.Logger, deps *Dependencies) http.Handler {
 	mux := http.NewServeMux()
-	// register GetOrder handler
-	mux.HandleFunc("/api/order/", serverHandlers.GetOrderHandler(log, cache, storage))
+	writeError := problem.Writer(log)
+
+	// limit returns rate limiting middleware for route name.
+	// every route has own limiter, so clients buckets are per route
+	limit := func(route string) func(http.Handler) http.Handler {
+		if !cfg.RateLimit.Enabled {
+			return func(next http.Handler) http.Handler { return next }
+		}
+		rps, burst := cfg.RateLimit.RPS, cfg.RateLimit.Burst
+		if v, ok := cfg.RateLimit.RouteRPS[route]; ok {
+			rps = v
+		}
+		if v, ok := cfg.RateLimit.RouteBurst[route]; ok {
+			burst = v
+		}
+		limiter := ratelimit.New(rps, burst, cfg.RateLimit.BucketTTL, cfg.RateLimit.MaxBuckets)
+		return middlewares.RateLimitMiddleware(log.With(logger.Field("route", route)), limiter, cfg.RateLimit.TrustedProxies, writeError)
+	}
+
+	// limitIP limits all protected routes requests by client IP before authentication,
+	// so unauthenticated floods are rejected too. it is shared by all routes
+	limitIP := func(next http.Handler) http.Handler { return next }
+	if cfg.RateLimit.Enabled {
+		limiter := ratelimit.New(cfg.RateLimit.IPRPS, cfg.RateLimit.IPBurst, cfg.RateLimit.BucketTTL, cfg.RateLimit.MaxBuckets)
+		limitIP = middlewares.IPRateLimitMiddleware(log.With(logger.Field("route", "ip")), limiter, cfg.RateLimit.TrustedProxies, writeError)
+	}
+
+	// protect wraps handler of route with IP rate limiting, authentication, rate limiting and role check.
+	// route rate limiting is after authentication to limit clients by their credentials
+	authenticate := middlewares.AuthMiddleware(log, deps.Authenticator, writeError)
+	protect := func(route string, handler http.Handler, roles ...auth.Role) http.Handler {
+		return limitIP(authenticate(limit(route)(middlewares.RequireRoles(log, writeError, roles...)(handler))))
+	}
+
+	// masker shapes responses for caller role
+	masker := pii.New(&cfg.Masking)
+
+	// compressor is shared by compression middleware and handlers caching encoded bodies
+	var compressor *compress.Compressor
+	if cfg.Compression.Enabled {
+		compressor = compress.New(&cfg.Compression)
+	}
+
+	getOrder := protect("orders", serverHandlers.GetOrderHandler(log, deps.Orders, deps.Storage, masker, compressor, deps.Waiters, cfg.Wait.MaxWait), auth.RoleSupport, auth.RoleAdmin)
+
+	getOrderHistory := protect("history", serverHandlers.GetOrderHistoryHandler(log, deps.Storage, masker), auth.RoleSupport, auth.RoleAdmin)
+
+	// register GetOrder and GetOrderHistory handlers
+	mux.Handle("GET "+apiV1+"/order/{order_uid}", getOrder)
+	mux.Handle("GET "+apiV1+"/order/{order_uid}/history", getOrderHistory)
+
+	// register orders stream handler
+	mux.Handle("GET "+apiV1+"/orders/stream", protect("stream",
+		serverHandlers.OrdersStreamHandler(log, deps.Hub, masker, cfg.Stream.Heartbeat), auth.RoleSupport, auth.RoleAdmin))
+
+	validate := models.NewValidator()
+	admin := func(handler http.Handler) http.Handler {
+		return protect("admin", handler, auth.RoleAdmin)
+	}
+
+	// register order ingestion handler. orders are saved the same way as broker messages
+	mux.Handle("POST "+apiV1+"/orders", admin(serverHandlers.CreateOrderHandler(log, deps.Storage, deps.Schemas, validate, deps.Rules, deps.Notifier)))
+
+	// register webhooks admin handlers
+	mux.Handle("POST "+apiV1+"/admin/webhooks", admin(serverHandlers.CreateWebhookHandler(log, deps.Storage, validate)))
+	mux.Handle("GET "+apiV1+"/admin/webhooks", admin(serverHandlers.ListWebhooksHandler(log, deps.Storage)))
+	mux.Handle("DELETE "+apiV1+"/admin/webhooks/{id}", admin(serverHandlers.DeleteWebhookHandler(log, deps.Storage)))
+	mux.Handle("POST "+apiV1+"/admin/webhooks/{id}/enable", admin(serverHandlers.EnableWebhookHandler(log, deps.Storage)))
+	mux.Handle("GET "+apiV1+"/admin/webhooks/{id}/deliveries", admin(serverHandlers.ListWebhookDeliveriesHandler(log, deps.Storage)))
+
+	// register rejected messages admin handlers
+	mux.Handle("GET "+apiV1+"/admin/rejections", admin(serverHandlers.ListRejectionsHandler(log, deps.Storage)))
+	mux.Handle("GET "+apiV1+"/admin/rejections/{id}", admin(serverHandlers.GetRejectionHandler(log, deps.Storage)))
+	mux.Handle("POST "+apiV1+"/admin/rejections/{id}/replay", admin(serverHandlers.ReplayRejectionHandler(log, deps.Storage, deps.Schemas, validate, deps.Rules, deps.Notifier)))
+
+	// register consumer control handlers
+	mux.Handle("GET "+apiV1+"/admin/consumer", admin(serverHandlers.GetConsumerHandler(log, deps.Consumer, deps.Replays)))
+	mux.Handle("POST "+apiV1+"/admin/consumer/pause", admin(serverHandlers.PauseConsumerHandler(log, deps.Consumer, deps.Replays)))
+	mux.Handle("POST "+apiV1+"/admin/consumer/drain", admin(serverHandlers.DrainConsumerHandler(log, deps.Consumer, deps.Replays)))
+	mux.Handle("POST "+apiV1+"/admin/consumer/resume", admin(serverHandlers.ResumeConsumerHandler(log, deps.Consumer, deps.Replays)))
+
+	// register consumer group offsets tools handlers if broker supports them
+	if resetter, ok := deps.Consumer.(broker.OffsetResetter); ok {
+		mux.Handle("POST "+apiV1+"/admin/consumer/offsets", admin(serverHandlers.ResetOffsetsHandler(log, resetter)))
+	}
+	if deps.Replays != nil {
+		mux.Handle("POST "+apiV1+"/admin/replays", admin(serverHandlers.StartReplayHandler(log, deps.Replays)))
+		mux.Handle("GET "+apiV1+"/admin/replays", admin(serverHandlers.ListReplaysHandler(log, deps.Replays)))
+		mux.Handle("GET "+apiV1+"/admin/replays/{id}", admin(serverHandlers.GetReplayHandler(log, deps.Replays)))
+		mux.Handle("POST "+apiV1+"/admin/replays/{id}/cancel", admin(serverHandlers.CancelReplayHandler(log, deps.Replays)))
+	}
+
+	// deprecated unversioned aliases. they will be removed after cfg.LegacySunset.
+	// path values are unescaped, so they are escaped back to not break Link header
+	deprecated := middlewares.DeprecationMiddleware(cfg.LegacyDeprecation, cfg.LegacySunset, func(r *http.Request) string {
+		return apiV1 + "/order/" + url.PathEscape(r.PathValue("order_uid"))
+	})
+	mux.Handle("GET /api/order/{order_uid}", deprecated(getOrder))
+	deprecatedHistory := middlewares.DeprecationMiddleware(cfg.LegacyDeprecation, cfg.LegacySunset, func(r *http.Request) string {
+		return apiV1 + "/order/" + url.PathEscape(r.PathValue("order_uid")) + "/history"
+	})
+	mux.Handle("GET /api/order/{order_uid}/history", deprecatedHistory(getOrderHistory))
+
 	// Swagger docs handler
-	mux.HandleFunc("/api/docs/", httpSwagger.WrapHandler)
+	mux.Handle("GET /api/docs/", limit("docs")(httpSwagger.WrapHandler))
+
+	// message schemas handlers. they are public like docs, so producers can fetch them
+	mux.Handle("GET /api/schemas", limit("schemas")(serverHandlers.ListSchemasHandler(log, deps.Schemas)))
+	mux.Handle("GET /api/schemas/{subject}/{version}", limit("schemas")(serverHandlers.GetSchemaHandler(log, deps.Schemas)))
+
+	// readiness probe. it is not rate limited for orchestrators
+	mux.Handle("GET /readyz", serverHandlers.ReadyHandler(log, deps.Consumer))
+
+	// metrics handler in Prometheus text format. metrics reveal load and failures,
+	// so they are public only if configured for scrapers of trusted network
+	if cfg.Metrics.Public {
+		mux.Handle("GET /metrics", limit("metrics")(promhttp.Handler()))
+	} else {
+		mux.Handle("GET /metrics", protect("metrics", promhttp.Handler(), auth.RoleAdmin))
+	}
+
+	// rendering router 404 and 405 responses as problem+json
+	handler := routeErrors(log, mux)
+	// compressing responses not encoded by handlers themselves
+	if compressor != nil {
+		handler = middlewares.CompressionMiddleware(log, compressor)(handler)
+	}
+	// adding recovery middleware, it must be after logger middleware
+	// to have request id and to let access log see 500 status
+	handler = middlewares.RecoveryMiddleware(log, writeError)(handler)
 	// adding logger middleware
-	return middlewares.LoggingMiddleware(log)(mux)
+	return middlewares.LoggingMiddleware(log)(handler)
+}
+
+// routeErrors wraps mux to replace its plain text 404 and 405 responses
+// with problem+json ones. Requests matching any route are passed as is
+func routeErrors(log logger.Logger, mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// empty pattern means that mux will answer with 404 or 405
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		mux.ServeHTTP(&routeErrorWriter{ResponseWriter: w, r: r, log: log}, r)
+	})
+}
+
+// routeErrorWriter intercepts mux 404 and 405 responses
+// and writes problem instead of them. Other responses
+// (for example, redirects) are passed to wrapped writer
+type routeErrorWriter struct {
+	http.ResponseWriter
+	r   *http.Request
+	log logger.Logger
+
+	wroteHeader bool
+	passthrough bool
+}
+
+func (w *routeErrorWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	switch status {
+	case http.StatusNotFound:
+		problem.Write(w.ResponseWriter, w.r, w.log, problem.NotFound("no such endpoint"))
+	case http.StatusMethodNotAllowed:
+		// Allow header is already set by mux
+		problem.Write(w.ResponseWriter, w.r, w.log, problem.MethodNotAllowed("method "+w.r.Method+" is not allowed"))
+	default:
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *routeErrorWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	// discarding mux plain text body, problem is already written
+	return len(b), nil
 }
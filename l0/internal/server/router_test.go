@@ -0,0 +1,444 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/auth"
+	"wb-tech-l0/internal/broker"
+	"wb-tech-l0/internal/config"
+	"wb-tech-l0/internal/events"
+	noplogger "wb-tech-l0/internal/logger/nop"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/rules"
+	"wb-tech-l0/internal/schema"
+	serverHandlers "wb-tech-l0/internal/server/handlers"
+	"wb-tech-l0/internal/server/problem"
+	"wb-tech-l0/internal/storage"
+)
+
+// fakeCache is an always empty Cache implementation
+type fakeCache struct{}
+
+func (fakeCache) Close() error                        { return nil }
+func (fakeCache) GetOrder(string) (interface{}, bool) { return nil, false }
+func (fakeCache) SaveOrder(string, interface{})       {}
+func (fakeCache) DeleteOrder(string)                  {}
+
+// fakeStorage is a Storage implementation with single known order
+type fakeStorage struct {
+	storage.Storage
+}
+
+func (fakeStorage) GetOrder(_ context.Context, uid string) (*models.Order, error) {
+	if uid == "known" {
+		return &models.Order{OrderUID: uid}, nil
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (fakeStorage) SaveOrder(context.Context, *models.Order, models.Source, *models.Position) (storage.SaveResult, error) {
+	return storage.SaveCreated, nil
+}
+
+func (fakeStorage) GetRejection(context.Context, string) (*models.Rejection, error) {
+	return nil, storage.ErrNotFound
+}
+
+// fakeBroker is a paused Broker implementation
+type fakeBroker struct {
+	broker.Broker
+}
+
+func (fakeBroker) Pause(context.Context) error { return nil }
+func (fakeBroker) Drain(context.Context) error { return nil }
+func (fakeBroker) Resume()                     {}
+func (fakeBroker) State() broker.State         { return broker.StatePaused }
+
+// testDependencies returns router dependencies with fakes
+func testDependencies(authenticator *auth.Authenticator, schemas *schema.Registry) *Dependencies {
+	return &Dependencies{
+		Authenticator: authenticator,
+		Orders:        serverHandlers.NewOrderCache(fakeCache{}),
+		Storage:       fakeStorage{},
+		Hub:           events.NewHub(10, 10, 10),
+		Waiters:       events.NewWaiters(10),
+		Schemas:       schemas,
+		Rules:         rules.New(&config.RulesConfig{DefaultAction: rules.ActionReject}),
+		Notifier:      events.Multi(),
+		Consumer:      fakeBroker{},
+	}
+}
+
+func TestRouter(t *testing.T) {
+	cfg := &config.ServerConfig{
+		LegacyDeprecation: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		LegacySunset:      time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+		Auth:              config.AuthConfig{Enabled: false},
+	}
+	authenticator, err := auth.New(&cfg.Auth)
+	if err != nil {
+		t.Fatalf("auth.New() error = %v", err)
+	}
+	schemas, err := schema.New()
+	if err != nil {
+		t.Fatalf("schema.New() error = %v", err)
+	}
+	router := NewRouter(cfg, noplogger.New(), testDependencies(authenticator, schemas))
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		wantStatus  int
+		wantProblem bool
+		wantLink    string
+	}{
+		{
+			name:       "Existing order",
+			method:     http.MethodGet,
+			path:       "/api/v1/order/known",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "Missing order",
+			method:      http.MethodGet,
+			path:        "/api/v1/order/unknown",
+			wantStatus:  http.StatusNotFound,
+			wantProblem: true,
+		},
+		{
+			name:        "Nested path is not uid",
+			method:      http.MethodGet,
+			path:        "/api/v1/order/known/extra",
+			wantStatus:  http.StatusNotFound,
+			wantProblem: true,
+		},
+		{
+			name:        "Wrong method",
+			method:      http.MethodPost,
+			path:        "/api/v1/order/known",
+			wantStatus:  http.StatusMethodNotAllowed,
+			wantProblem: true,
+		},
+		{
+			name:        "Ingestion without order",
+			method:      http.MethodPost,
+			path:        "/api/v1/orders",
+			wantStatus:  http.StatusBadRequest,
+			wantProblem: true,
+		},
+		{
+			name:       "Schemas",
+			method:     http.MethodGet,
+			path:       "/api/schemas",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Order schema",
+			method:     http.MethodGet,
+			path:       "/api/schemas/order/2",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "Rejections with invalid date",
+			method:      http.MethodGet,
+			path:        "/api/v1/admin/rejections?from=yesterday",
+			wantStatus:  http.StatusBadRequest,
+			wantProblem: true,
+		},
+		{
+			name:        "Rejections with empty range",
+			method:      http.MethodGet,
+			path:        "/api/v1/admin/rejections?from=2025-01-02T00:00:00Z&to=2025-01-01T00:00:00Z",
+			wantStatus:  http.StatusBadRequest,
+			wantProblem: true,
+		},
+		{
+			name:        "Replay of unknown rejection",
+			method:      http.MethodPost,
+			path:        "/api/v1/admin/rejections/unknown/replay",
+			wantStatus:  http.StatusNotFound,
+			wantProblem: true,
+		},
+		{
+			name:       "Metrics",
+			method:     http.MethodGet,
+			path:       "/metrics",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Ready while consumer is paused",
+			method:     http.MethodGet,
+			path:       "/readyz",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Drain consumer",
+			method:     http.MethodPost,
+			path:       "/api/v1/admin/consumer/drain",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "Offsets reset is not supported",
+			method:      http.MethodPost,
+			path:        "/api/v1/admin/consumer/offsets",
+			wantStatus:  http.StatusNotFound,
+			wantProblem: true,
+		},
+		{
+			name:        "Unknown schema version",
+			method:      http.MethodGet,
+			path:        "/api/schemas/order/100",
+			wantStatus:  http.StatusNotFound,
+			wantProblem: true,
+		},
+		{
+			name:       "Deprecated alias",
+			method:     http.MethodGet,
+			path:       "/api/order/known",
+			wantStatus: http.StatusOK,
+			wantLink:   `</api/v1/order/known>; rel="successor-version"`,
+		},
+		{
+			name:        "Deprecated alias successor is escaped",
+			method:      http.MethodGet,
+			path:        "/api/order/a%3E%20b",
+			wantStatus:  http.StatusNotFound,
+			wantProblem: true,
+			wantLink:    `</api/v1/order/a%3E%20b>; rel="successor-version"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type") == problem.ContentType; got != tt.wantProblem {
+				t.Errorf("problem content type = %v, want %v", got, tt.wantProblem)
+			}
+			if got := rec.Header().Get("Link"); got != tt.wantLink {
+				t.Errorf("link header = %q, want %q", got, tt.wantLink)
+			}
+			// deprecated responses have dates of deprecation and sunset
+			if tt.wantLink != "" {
+				if got := rec.Header().Get("Deprecation"); got != "@1767225600" {
+					t.Errorf("deprecation header = %q, want @1767225600", got)
+				}
+				if got := rec.Header().Get("Sunset"); got != "Fri, 01 Jan 2027 00:00:00 GMT" {
+					t.Errorf("sunset header = %q, want Fri, 01 Jan 2027 00:00:00 GMT", got)
+				}
+			}
+		})
+	}
+}
+
+func TestRouterWait(t *testing.T) {
+	cfg := &config.ServerConfig{
+		Auth: config.AuthConfig{Enabled: false},
+		Wait: config.WaitConfig{MaxWait: 5 * time.Second, MaxWaiters: 1},
+	}
+	authenticator, err := auth.New(&cfg.Auth)
+	if err != nil {
+		t.Fatalf("auth.New() error = %v", err)
+	}
+	schemas, err := schema.New()
+	if err != nil {
+		t.Fatalf("schema.New() error = %v", err)
+	}
+	waiters := events.NewWaiters(cfg.Wait.MaxWaiters)
+	deps := testDependencies(authenticator, schemas)
+	deps.Waiters = waiters
+	router := NewRouter(cfg, noplogger.New(), deps)
+
+	t.Run("Woken by saved order", func(t *testing.T) {
+		go func() {
+			// waiting for handler to register waiter
+			for waiters.Len() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			waiters.OrderSaved(&models.Order{OrderUID: "later"})
+		}()
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/order/later?wait=5s", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+	})
+
+	t.Run("Timed out", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/order/never?wait=10ms", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+		}
+		if got := waiters.Len(); got != 0 {
+			t.Errorf("waiters.Len() = %d, want 0", got)
+		}
+	})
+
+	t.Run("Invalid wait", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/order/never?wait=soon", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestRouterIPRateLimit(t *testing.T) {
+	cfg := &config.ServerConfig{
+		Auth: config.AuthConfig{Enabled: true, APIKeys: map[string]string{"0123456789abcdef": "admin"}},
+		RateLimit: config.RateLimitConfig{
+			Enabled: true, RPS: 100, Burst: 100, IPRPS: 0.001, IPBurst: 2,
+			BucketTTL: time.Minute, MaxBuckets: 10,
+		},
+	}
+	authenticator, err := auth.New(&cfg.Auth)
+	if err != nil {
+		t.Fatalf("auth.New() error = %v", err)
+	}
+	schemas, err := schema.New()
+	if err != nil {
+		t.Fatalf("schema.New() error = %v", err)
+	}
+	router := NewRouter(cfg, noplogger.New(), testDependencies(authenticator, schemas))
+
+	// unauthenticated requests are limited by IP before authentication
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/order/known", nil))
+		if rec.Code != want {
+			t.Errorf("request %d status = %d, want %d", i+1, rec.Code, want)
+		}
+	}
+}
+
+func TestRouterMetricsAccess(t *testing.T) {
+	tests := []struct {
+		name       string
+		public     bool
+		apiKey     string
+		wantStatus int
+	}{
+		{name: "Protected without credentials", wantStatus: http.StatusUnauthorized},
+		{name: "Protected for support", apiKey: "support-key-0123456", wantStatus: http.StatusForbidden},
+		{name: "Protected for admin", apiKey: "admin-key-012345678", wantStatus: http.StatusOK},
+		{name: "Public", public: true, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.ServerConfig{
+				Auth: config.AuthConfig{Enabled: true, APIKeys: map[string]string{
+					"support-key-0123456": "support", "admin-key-012345678": "admin",
+				}},
+				Metrics: config.MetricsConfig{Public: tt.public},
+			}
+			authenticator, err := auth.New(&cfg.Auth)
+			if err != nil {
+				t.Fatalf("auth.New() error = %v", err)
+			}
+			router := NewRouter(cfg, noplogger.New(), testDependencies(authenticator, nil))
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Code == http.StatusOK && !strings.Contains(rec.Body.String(), "go_goroutines") {
+				t.Errorf("metrics body is not Prometheus exposition: %s", rec.Body)
+			}
+		})
+	}
+}
+
+// orderV1 is an order message of schema v1 with lowercase currency and uppercase locale
+const orderV1 = `{
+	"order_uid": "b563feb7b2b84b6test", "track_number": "WBILMTESTTRACK", "entry": "WBIL",
+	"delivery": {
+		"name": "Test Testov", "phone": "+9720000000", "zip": "2639809", "city": "Kiryat Mozkin",
+		"address": "Ploshad Mira 15", "region": "Kraiot", "email": "test@gmail.com"
+	},
+	"payment": {
+		"transaction": "b563feb7b2b84b6test", "request_id": "", "currency": "usd", "provider": "wbpay",
+		"amount": 1817, "payment_dt": 1637907727, "bank": "alpha", "delivery_cost": 1500, "goods_total": 317, "custom_fee": 0
+	},
+	"items": [{
+		"chrt_id": 9934930, "track_number": "WBILMTESTTRACK", "price": 453, "rid": "ab4219087a764ae0btest",
+		"name": "Mascaras", "sale": 30, "size": "0", "total_price": 317, "nm_id": 2389212, "brand": "Vivienne Sabo", "status": 202
+	}],
+	"locale": "EN", "internal_signature": "", "customer_id": "test", "delivery_service": "meest",
+	"shardkey": "9", "sm_id": 99, "date_created": "2021-11-26T06:22:19Z", "oof_shard": "1"
+}`
+
+func TestRouterIngestSchema(t *testing.T) {
+	cfg := &config.ServerConfig{Auth: config.AuthConfig{Enabled: false}}
+	authenticator, err := auth.New(&cfg.Auth)
+	if err != nil {
+		t.Fatalf("auth.New() error = %v", err)
+	}
+	schemas, err := schema.New()
+	if err != nil {
+		t.Fatalf("schema.New() error = %v", err)
+	}
+	router := NewRouter(cfg, noplogger.New(), testDependencies(authenticator, schemas))
+
+	tests := []struct {
+		name       string
+		version    string
+		body       string
+		wantStatus int
+		wantField  string
+	}{
+		{name: "v1 is upcasted", body: orderV1, wantStatus: http.StatusCreated},
+		{name: "v1 is not valid v2", version: "2", body: orderV1, wantStatus: http.StatusBadRequest, wantField: "payment.currency"},
+		{name: "Schema violation", body: strings.Replace(orderV1, `"entry": "WBIL",`, "", 1), wantStatus: http.StatusBadRequest, wantField: "entry"},
+		{name: "Unknown version", version: "10", body: orderV1, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", strings.NewReader(tt.body))
+			if tt.version != "" {
+				req.Header.Set(schema.HeaderVersion, tt.version)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantField == "" {
+				return
+			}
+
+			var p problem.Problem
+			if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+				t.Fatalf("could not decode problem: %v", err)
+			}
+			found := false
+			for _, fe := range p.Errors {
+				found = found || fe.Field == tt.wantField
+			}
+			if !found {
+				t.Errorf("problem errors = %+v, want error of %s", p.Errors, tt.wantField)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package signing
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/broker"
+	"wb-tech-l0/internal/config"
+	"wb-tech-l0/internal/payload"
+)
+
+const (
+	oldSecret = "old-secret-old-secret-old-secret"
+	newSecret = "new-secret-new-secret-new-secret"
+)
+
+func newVerifier(required bool, now time.Time) *Verifier {
+	v := New(&config.SigningConfig{
+		Required: required,
+		Keys:     map[string]string{"old": oldSecret, "new": newSecret},
+		MaxAge:   time.Minute,
+	})
+	v.now = func() time.Time { return now }
+	return v
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	signed := func(keyID, secret string, modify func(m *broker.Message)) *broker.Message {
+		m := &broker.Message{
+			Key:       []byte("order-1"),
+			Value:     []byte(`{"order_uid":"order-1"}`),
+			Timestamp: now.Add(-time.Second),
+			Headers:   map[string][]byte{payload.HeaderClaimCheck: []byte("orders/1.json")},
+		}
+		Sign(keyID, []byte(secret), m)
+		if modify != nil {
+			modify(m)
+		}
+		return m
+	}
+
+	tests := []struct {
+		name     string
+		required bool
+		message  *broker.Message
+		want     error
+	}{
+		{name: "current key", message: signed("new", newSecret, nil)},
+		{name: "rotated key", message: signed("old", oldSecret, nil)},
+		{name: "unsigned allowed", message: &broker.Message{Value: []byte("{}")}},
+		{name: "unsigned required", required: true, message: &broker.Message{Value: []byte("{}")}, want: ErrUnsigned},
+		{name: "unknown key", message: signed("removed", newSecret, nil), want: ErrUnknownKey},
+		{name: "wrong secret", message: signed("new", oldSecret, nil), want: ErrBadSignature},
+		{name: "tampered value", message: signed("new", newSecret, func(m *broker.Message) {
+			m.Value = []byte(`{"order_uid":"order-2"}`)
+		}), want: ErrBadSignature},
+		{name: "key moved to value", message: signed("new", newSecret, func(m *broker.Message) {
+			m.Key, m.Value = m.Key[:6], append([]byte("1"), m.Value...)
+		}), want: ErrBadSignature},
+		{name: "header moved to value", message: signed("new", newSecret, func(m *broker.Message) {
+			claimCheck := m.Headers[payload.HeaderClaimCheck]
+			delete(m.Headers, payload.HeaderClaimCheck)
+			m.Value = append(m.Value, payload.HeaderClaimCheck+"="+strconv.Itoa(len(claimCheck))+"."+string(claimCheck)...)
+		}), want: ErrBadSignature},
+		{name: "tampered claim check", message: signed("new", newSecret, func(m *broker.Message) {
+			m.Headers[payload.HeaderClaimCheck] = []byte("orders/2.json")
+		}), want: ErrBadSignature},
+		{name: "tampered timestamp", message: signed("new", newSecret, func(m *broker.Message) {
+			m.Timestamp = now
+		}), want: ErrBadSignature},
+		{name: "malformed signature", message: signed("new", newSecret, func(m *broker.Message) {
+			m.Headers[HeaderSignature] = []byte("v2=zz")
+		}), want: ErrBadSignature},
+		{name: "unknown version", message: signed("new", newSecret, func(m *broker.Message) {
+			m.Headers[HeaderSignature] = append([]byte("v1="), m.Headers[HeaderSignature][3:]...)
+		}), want: ErrBadSignature},
+		{name: "expired", message: signed("new", newSecret, func(m *broker.Message) {
+			m.Timestamp = now.Add(-2 * time.Minute)
+			Sign("new", []byte(newSecret), m)
+		}), want: ErrExpired},
+		{name: "expired reread", message: signed("new", newSecret, func(m *broker.Message) {
+			m.Timestamp = now.Add(-2 * time.Minute)
+			Sign("new", []byte(newSecret), m)
+			m.Reread = true
+		})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newVerifier(tt.required, now).Verify(tt.message)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReason(t *testing.T) {
+	tests := map[error]string{
+		ErrUnsigned:     "unsigned",
+		ErrUnknownKey:   "unknown_key",
+		ErrBadSignature: "bad_signature",
+		ErrExpired:      "expired",
+	}
+	for err, want := range tests {
+		if got := Reason(err); got != want {
+			t.Errorf("Reason(%v) = %q, want %q", err, got, want)
+		}
+	}
+}
@@ -4,3 +4,10 @@ import "fmt"
 
 var ErrUniqueViolation = fmt.Errorf("unique violation")
 var ErrNotFound = fmt.Errorf("not found")
+
+// ErrInvalidTransition is returned when order status can't be changed to requested one
+var ErrInvalidTransition = fmt.Errorf("invalid status transition")
+
+// ErrAlreadyProcessed is returned when message position is not after stored
+// consumer position, so message was already processed
+var ErrAlreadyProcessed = fmt.Errorf("already processed")
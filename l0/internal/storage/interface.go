@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"time"
 
 	"wb-tech-l0/internal/models"
 )
@@ -10,10 +11,140 @@ import (
 type Storage interface {
 	// Close closes the Storage connection
 	Close() error
-	// SaveOrder takes order and saves it to storage.
-	// It also must handle the retries of saving
-	SaveOrder(order *models.Order) error
+	// SaveOrder takes order and saves it to storage. Existing order is replaced
+	// (with its delivery, payment and items) only if order version is greater than
+	// stored one, otherwise it is ignored. Parked status changes of created order are
+	// applied in the same transaction. order.saved outbox message must be saved
+	// in the same transaction if order is created or updated.
+	// Created and updated orders are saved as revisions with mutation source.
+	// If position is not nil, it is stored in the same transaction too
+	// and ErrAlreadyProcessed is returned if it is not after stored position.
+	// Saving is bounded by ctx deadline. It also must handle the retries of saving
+	SaveOrder(ctx context.Context, order *models.Order, source models.Source, position *models.Position) (SaveResult, error)
+	// ChangeOrderStatus changes order status and saves transition to order timeline
+	// with order.status_changed outbox message and order revision in one transaction. It returns
+	// SaveIgnored if order already has the status and ErrInvalidTransition if transition
+	// is not allowed. Changes of missing orders are parked with SavePending result and
+	// applied by SaveOrder when order is created.
+	// Saving is bounded by ctx deadline. It also must handle the retries of saving
+	ChangeOrderStatus(ctx context.Context, change *models.StatusChange, source models.Source) (SaveResult, error)
 	// GetOrder takes user request context and order uid and fetches its model.
 	// It also must handle the retries of fetching
 	GetOrder(ctx context.Context, uid string) (*models.Order, error)
+	// GetOrderHistory returns order revisions, oldest first.
+	// It returns ErrNotFound if there is no such order.
+	// It also must handle the retries of fetching
+	GetOrderHistory(ctx context.Context, uid string) ([]models.Revision, error)
+
+	WebhookStorage
+	OutboxStorage
+	OffsetStorage
+	RejectionStorage
+}
+
+// RejectionStorage is a part of Storage interface for rejected
+// consumed messages. All methods must handle the retries
+type RejectionStorage interface {
+	// SaveRejection saves rejected message. Rejection ID and CreatedAt are set by storage.
+	// Message at the same broker position is saved once, its rejection is updated
+	SaveRejection(ctx context.Context, rejection *models.Rejection) error
+	// ListRejections returns rejections matching filter, newest first
+	ListRejections(ctx context.Context, filter RejectionFilter) ([]models.Rejection, error)
+	// GetRejection returns rejection by ID.
+	// It returns ErrNotFound if there is no such rejection
+	GetRejection(ctx context.Context, id string) (*models.Rejection, error)
+	// MarkRejectionReplayed records that corrected payload of rejection was saved by caller.
+	// It returns ErrNotFound if there is no such rejection
+	MarkRejectionReplayed(ctx context.Context, id, caller string) error
+	// PurgeRejections deletes rejections created before given time and returns their number
+	PurgeRejections(ctx context.Context, before time.Time) (int64, error)
+}
+
+// RejectionFilter selects rejections. Zero fields match any rejection
+type RejectionFilter struct {
+	// Reason is a rejection reason
+	Reason string
+	// From is an inclusive start of rejections dates range
+	From time.Time
+	// To is an exclusive end of rejections dates range
+	To time.Time
+	// Limit is a maximum number of returned rejections
+	Limit int
+}
+
+// OffsetStorage is a part of Storage interface for consumer positions
+// stored with consumed data. It implements broker.OffsetStore
+type OffsetStorage interface {
+	// Offsets returns stored next offsets of group topic partitions
+	Offsets(ctx context.Context, group, topic string) (map[int]int64, error)
+	// CommitOffset stores position if it is after stored one
+	CommitOffset(ctx context.Context, position models.Position) error
+	// ResetOffsets replaces stored next offsets of group topic partitions,
+	// they can be moved back
+	ResetOffsets(ctx context.Context, group, topic string, offsets map[int]int64) error
 }
+
+// OutboxStorage is a part of Storage interface for transactional outbox.
+// Outbox messages are saved with the changes they describe and relayed to broker
+type OutboxStorage interface {
+	// ClaimOutbox leases at most limit oldest pending outbox messages, so concurrent
+	// relays don't take them, and returns them. Messages not marked sent or released
+	// before lease expires are claimed again
+	ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxMessage, error)
+	// MarkOutboxSent marks messages sent
+	MarkOutboxSent(ctx context.Context, ids []int64) error
+	// ReleaseOutbox releases lease of messages not sent
+	ReleaseOutbox(ctx context.Context, ids []int64) error
+	// PurgeOutbox deletes messages sent before given time and returns their number
+	PurgeOutbox(ctx context.Context, before time.Time) (int64, error)
+}
+
+// WebhookStorage is a part of Storage interface for webhook subscriptions
+// and their delivery log. All methods must handle the retries
+type WebhookStorage interface {
+	// CreateWebhook saves new webhook. Webhook ID and CreatedAt are set by storage
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	// ListWebhooks returns all webhooks with their secrets
+	ListWebhooks(ctx context.Context) ([]models.Webhook, error)
+	// DeleteWebhook deletes webhook with its delivery log.
+	// It returns ErrNotFound if there is no such webhook
+	DeleteWebhook(ctx context.Context, id string) error
+	// EnableWebhook enables webhook and resets its failures counter.
+	// It returns ErrNotFound if there is no such webhook
+	EnableWebhook(ctx context.Context, id string) error
+	// SaveWebhookDelivery saves delivery attempt to delivery log
+	SaveWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	// ListWebhookDeliveries returns at most limit last delivery attempts of webhook
+	ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]models.WebhookDelivery, error)
+	// FanOutWebhooks takes at most limit oldest outbox messages not fanned out to webhooks yet,
+	// calls fanOut with them and saves returned jobs and marks messages fanned out in one transaction.
+	// Jobs of the same webhook and event are saved once. It returns number of taken messages
+	FanOutWebhooks(ctx context.Context, limit int, fanOut func(messages []models.OutboxMessage) ([]models.WebhookJob, error)) (int, error)
+	// ClaimWebhookJobs locks at most limit due jobs for lease, so other dispatchers
+	// don't take them, and returns them with their webhooks.
+	// Jobs not finished or retried before lease expires are claimed again
+	ClaimWebhookJobs(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookJob, error)
+	// RetryWebhookJob unlocks job and schedules its next attempt at given time
+	RetryWebhookJob(ctx context.Context, id int64, at time.Time) error
+	// DeleteWebhookJob deletes finished job
+	DeleteWebhookJob(ctx context.Context, id int64) error
+	// RecordWebhookResult updates webhook consecutive failures counter with final
+	// result of event delivery and disables webhook if counter reaches disableAfter.
+	// It returns true if webhook was disabled by this call
+	RecordWebhookResult(ctx context.Context, id string, success bool, disableAfter int) (bool, error)
+}
+
+// SaveResult is a result of saving entity that can be updated
+type SaveResult string
+
+// Results of saving
+const (
+	// SaveCreated means that entity was created
+	SaveCreated SaveResult = "created"
+	// SaveUpdated means that stored entity was replaced with newer version
+	SaveUpdated SaveResult = "updated"
+	// SaveIgnored means that stored entity version is the same or newer
+	SaveIgnored SaveResult = "ignored"
+	// SavePending means that entity is parked until entity it depends on is saved
+	SavePending SaveResult = "pending"
+)
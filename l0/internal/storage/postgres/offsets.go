@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"wb-tech-l0/internal/models"
+)
+
+// advanceOffsetTx is a helper method to store position within a given transaction.
+// Position is stored only if it is after stored one. It returns false if it is not,
+// so message at position was already processed
+func (p *Postgres) advanceOffsetTx(ctx context.Context, tx pgx.Tx, position *models.Position) (bool, error) {
+	tag, err := tx.Exec(ctx, advanceOffsetQuery, position.Group, position.Topic, position.Partition, position.Offset)
+	if err != nil {
+		return false, fmt.Errorf("could not store offset: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// advanceOffsetQuery inserts or moves forward stored position
+const advanceOffsetQuery = `
+	INSERT INTO consumer_offsets (group_id, topic, partition, next_offset)
+	VALUES ($1,$2,$3,$4)
+	ON CONFLICT (group_id, topic, partition) DO UPDATE
+	SET next_offset = EXCLUDED.next_offset, updated_at = NOW()
+	WHERE consumer_offsets.next_offset < EXCLUDED.next_offset
+`
+
+// Offsets returns stored next offsets of group topic partitions
+func (p *Postgres) Offsets(ctx context.Context, group, topic string) (map[int]int64, error) {
+	offsets := make(map[int]int64)
+
+	err := p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		rows, err := p.pool.Query(ctx, `
+			SELECT partition, next_offset FROM consumer_offsets WHERE group_id = $1 AND topic = $2
+		`, group, topic)
+		if err != nil {
+			return fmt.Errorf("failed to query offsets: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var partition int
+			var offset int64
+			if err := rows.Scan(&partition, &offset); err != nil {
+				return fmt.Errorf("failed to scan offset: %w", err)
+			}
+			offsets[partition] = offset
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return offsets, nil
+}
+
+// CommitOffset stores position if it is after stored one.
+// Position stored by SaveOrder is not moved back
+func (p *Postgres) CommitOffset(ctx context.Context, position models.Position) error {
+	return p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		_, err := p.pool.Exec(ctx, advanceOffsetQuery, position.Group, position.Topic, position.Partition, position.Offset)
+		if err != nil {
+			return fmt.Errorf("failed to commit offset: %w", err)
+		}
+		return nil
+	})
+}
+
+// ResetOffsets replaces stored next offsets of group topic partitions in one transaction
+func (p *Postgres) ResetOffsets(ctx context.Context, group, topic string, offsets map[int]int64) error {
+	return p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		return p.inTx(ctx, p.log, func(tx pgx.Tx) error {
+			for partition, offset := range offsets {
+				_, err := tx.Exec(ctx, `
+					INSERT INTO consumer_offsets (group_id, topic, partition, next_offset)
+					VALUES ($1,$2,$3,$4)
+					ON CONFLICT (group_id, topic, partition) DO UPDATE
+					SET next_offset = EXCLUDED.next_offset, updated_at = NOW()
+				`, group, topic, partition, offset)
+				if err != nil {
+					return fmt.Errorf("failed to reset offset: %w", err)
+				}
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"wb-tech-l0/internal/models"
+)
+
+// insertOutboxTx is a helper method to insert outbox message within a given transaction.
+// Trace of mutation source is kept, so published events continue it
+func (p *Postgres) insertOutboxTx(ctx context.Context, tx pgx.Tx, event, key string, source models.Source, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("could not encode outbox payload: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO outbox (event, key, payload, trace_parent) VALUES ($1,$2,$3,$4)`, event, key, payload, source.TraceParent)
+	if err != nil {
+		return fmt.Errorf("could not insert outbox: %w", err)
+	}
+	return nil
+}
+
+// ClaimOutbox leases pending messages in one statement. Messages are selected
+// with SKIP LOCKED, so concurrent relays (for example, in several application instances)
+// take different messages, and no transaction is held while they are published
+func (p *Postgres) ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+
+	err := p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		rows, err := p.pool.Query(ctx, `
+			WITH claimed AS (
+				UPDATE outbox SET locked_until = NOW() + make_interval(secs => $2)
+				WHERE id IN (
+					SELECT id FROM outbox
+					WHERE sent_at IS NULL AND (locked_until IS NULL OR locked_until <= NOW())
+					ORDER BY id
+					LIMIT $1
+					FOR UPDATE SKIP LOCKED
+				)
+				RETURNING id, event, key, payload, created_at, trace_parent
+			)
+			SELECT id, event, key, payload, created_at, trace_parent FROM claimed ORDER BY id
+		`, limit, lease.Seconds())
+		if err != nil {
+			return fmt.Errorf("failed to claim outbox: %w", err)
+		}
+		messages, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.OutboxMessage, error) {
+			var m models.OutboxMessage
+			err := row.Scan(&m.ID, &m.Event, &m.Key, &m.Payload, &m.CreatedAt, &m.TraceParent)
+			return m, err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan outbox: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// MarkOutboxSent marks messages sent and releases their lease
+func (p *Postgres) MarkOutboxSent(ctx context.Context, ids []int64) error {
+	return p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		_, err := p.pool.Exec(ctx, `UPDATE outbox SET sent_at = NOW(), locked_until = NULL WHERE id = ANY($1)`, ids)
+		if err != nil {
+			return fmt.Errorf("failed to mark outbox sent: %w", err)
+		}
+		return nil
+	})
+}
+
+// ReleaseOutbox releases lease of messages, so they are claimed by next relay call
+func (p *Postgres) ReleaseOutbox(ctx context.Context, ids []int64) error {
+	return p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		_, err := p.pool.Exec(ctx, `UPDATE outbox SET locked_until = NULL WHERE id = ANY($1) AND sent_at IS NULL`, ids)
+		if err != nil {
+			return fmt.Errorf("failed to release outbox: %w", err)
+		}
+		return nil
+	})
+}
+
+// PurgeOutbox deletes messages sent before given time.
+// Messages not fanned out to webhooks yet are kept
+func (p *Postgres) PurgeOutbox(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+
+	err := p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		tag, err := p.pool.Exec(ctx, `DELETE FROM outbox WHERE sent_at < $1 AND webhooks_at IS NOT NULL`, before)
+		if err != nil {
+			return fmt.Errorf("failed to purge outbox: %w", err)
+		}
+		purged = tag.RowsAffected()
+		return nil
+	})
+
+	return purged, err
+}
@@ -71,12 +71,17 @@ func (p *Postgres) Close() error {
 
 // SaveOrder takes order and tries to save it max retries times or until success.
 // It returns error if after max retires times order still was not saved.
-// It is using application context with timeout for requests
-func (p *Postgres) SaveOrder(order *models.Order) error {
+// Existing order is replaced only by greater version, so reordered and
+// duplicated messages are ignored. If position is not nil, it is advanced
+// in the same transaction, so order and consumer position are saved
+// atomically (exactly-once consuming).
+// Every attempt has request timeout within ctx deadline
+func (p *Postgres) SaveOrder(ctx context.Context, order *models.Order, source models.Source, position *models.Position) (storage.SaveResult, error) {
 	var err error
+	var result storage.SaveResult
 
 	// adding order uid to logs for chaining with handler logs
-	log := p.log.With(logger.Field("order_uid", order.OrderUID))
+	log := p.log.With(logger.Field("order_uid", order.OrderUID), logger.Field("version", order.Version))
 	// adding max attempts to logs
 	log = log.With(logger.Field("max_attempts", p.maxRetries))
 
@@ -86,14 +91,14 @@ func (p *Postgres) SaveOrder(order *models.Order) error {
 		log.Debug("Attempting to save order", logger.Field("attempt", attempt))
 
 		// creating context for this retry with request timeout
-		ctx, cancel := context.WithTimeout(p.ctx, p.requestTimeout)
+		reqCtx, cancel := context.WithTimeout(ctx, p.requestTimeout)
 
 		// using function, to defer context cancel and rollback on error
 		func() {
 			defer cancel()
 
 			// begin the transaction
-			tx, txErr := p.pool.BeginTx(ctx, pgx.TxOptions{})
+			tx, txErr := p.pool.BeginTx(reqCtx, pgx.TxOptions{})
 			if txErr != nil {
 				err = fmt.Errorf("could not begin tx: %w", txErr)
 				return
@@ -113,15 +118,30 @@ func (p *Postgres) SaveOrder(order *models.Order) error {
 				}
 			}()
 
-			// inserting
-			err = p.insertOrderTx(ctx, tx, order)
+			// advancing consumer position first, already processed
+			// messages must not be saved again
+			if position != nil {
+				advanced, offsetErr := p.advanceOffsetTx(reqCtx, tx, position)
+				if offsetErr != nil {
+					err = offsetErr
+					log.Warn("Failed to store offset", logger.Field("attempt", attempt), logger.Error(err))
+					return
+				}
+				if !advanced {
+					err = storage.ErrAlreadyProcessed
+					return
+				}
+			}
+
+			// inserting or updating
+			result, err = p.saveOrderTx(reqCtx, tx, order, source)
 			if err != nil {
 				log.Warn("Failed to save order", logger.Field("attempt", attempt), logger.Error(err))
 				return
 			}
 
-			// commiting transaction
-			err = tx.Commit(ctx)
+			// commiting transaction. ignored order is committed too to store position
+			err = tx.Commit(reqCtx)
 			if err != nil {
 				log.Warn("Failed to commit transaction", logger.Field("attempt", attempt), logger.Error(err))
 				return
@@ -130,54 +150,142 @@ func (p *Postgres) SaveOrder(order *models.Order) error {
 
 		if err == nil {
 			// if everything was good, return nil error
-			log.Debug("Order saved successfully")
-			return nil
+			log.Debug("Order saved successfully", logger.Field("result", result))
+			return result, nil
+		}
+
+		if errors.Is(err, storage.ErrAlreadyProcessed) {
+			log.Debug("Order message was already processed")
+			return "", err
 		}
 
 		// if error is about sql
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
-			// if message violates unique constraint, return this error
-			if pgErr.Code == pgerrcode.UniqueViolation {
-				log.Debug("Order violates unique constraint")
-				return storage.ErrUniqueViolation
+			// if message violates unique constraint, return this error.
+			// orders primary key violation means that the same order was
+			// inserted concurrently, it will be updated or ignored on retry
+			if pgErr.Code == pgerrcode.UniqueViolation && pgErr.ConstraintName != "orders_pkey" {
+				log.Debug("Order violates unique constraint", logger.Field("constraint", pgErr.ConstraintName))
+				return "", storage.ErrUniqueViolation
 			}
 		}
 
-		// waiting for next try or app context cancellation
+		// waiting for next try or app or caller context cancellation
 		if attempt < p.maxRetries {
 			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
 			case <-p.ctx.Done():
-				return p.ctx.Err()
+				return "", p.ctx.Err()
 			case <-time.After(p.retryTimeout):
 				// continue retries
 			}
 		}
 	}
 
-	return fmt.Errorf("save order failed after %d attempts: %w", p.maxRetries, err)
+	return "", fmt.Errorf("save order failed after %d attempts: %w", p.maxRetries, err)
+}
+
+// saveOrderTx is a helper method to insert or update order within a given transaction.
+// Stored order row is locked, so concurrent saves of the same order are serialized.
+// It returns error if something goes wrong. In that case, transaction must be
+// rolled back by function that called this method
+func (p *Postgres) saveOrderTx(ctx context.Context, tx pgx.Tx, o *models.Order, source models.Source) (storage.SaveResult, error) {
+	var version int64
+	err := tx.QueryRow(ctx, `SELECT version FROM orders WHERE order_uid = $1 FOR UPDATE`, o.OrderUID).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		if err := p.insertOrderTx(ctx, tx, o, source); err != nil {
+			return "", err
+		}
+		if err := p.insertRevisionTx(ctx, tx, models.RevisionCreated, o, source); err != nil {
+			return "", err
+		}
+		// status changes could come before order
+		return storage.SaveCreated, p.applyPendingStatusesTx(ctx, tx, o)
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not lock order: %w", err)
+	}
+
+	// the same version is a duplicate and older one is reordered message
+	if o.Version <= version {
+		return storage.SaveIgnored, nil
+	}
+
+	if err := p.updateOrderTx(ctx, tx, o, source); err != nil {
+		return "", err
+	}
+	return storage.SaveUpdated, p.insertRevisionTx(ctx, tx, models.RevisionUpdated, o, source)
 }
 
 // insertOrderTx is a helper method to insert order within a given transaction
 // It returns error if something goes wrong. In that case, transaction must be
 // rolled back by function that called this method
-func (p *Postgres) insertOrderTx(ctx context.Context, tx pgx.Tx, o *models.Order) error {
-	// inserting order
-	_, err := tx.Exec(ctx, `
+func (p *Postgres) insertOrderTx(ctx context.Context, tx pgx.Tx, o *models.Order, source models.Source) error {
+	// inserting order. new orders always have created status
+	err := tx.QueryRow(ctx, `
 		INSERT INTO orders (
 			order_uid, track_number, entry, locale, internal_signature,
-			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
-		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, version, status
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)
+		RETURNING updated_at
 	`,
 		o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature,
-		o.CustomerID, o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard,
-	)
+		o.CustomerID, o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard, o.Version, models.StatusCreated,
+	).Scan(&o.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("could not insert orders: %w", err)
 	}
 
+	// starting status timeline
+	created := models.StatusTransition{To: models.StatusCreated, ChangedAt: o.DateCreated}
+	if err := p.insertTransitionTx(ctx, tx, o.OrderUID, created); err != nil {
+		return err
+	}
+	o.Status = models.StatusCreated
+	o.Timeline = []models.StatusTransition{created}
+
+	return p.insertOrderPartsTx(ctx, tx, o, source)
+}
+
+// updateOrderTx is a helper method to replace order with its delivery,
+// payment and items within a given transaction
+func (p *Postgres) updateOrderTx(ctx context.Context, tx pgx.Tx, o *models.Order, source models.Source) error {
+	// updating order. status is changed only by status messages, so it is kept
+	err := tx.QueryRow(ctx, `
+		UPDATE orders SET
+			track_number = $2, entry = $3, locale = $4, internal_signature = $5,
+			customer_id = $6, delivery_service = $7, shardkey = $8, sm_id = $9,
+			date_created = $10, oof_shard = $11, version = $12, updated_at = NOW()
+		WHERE order_uid = $1
+		RETURNING updated_at, status
+	`,
+		o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature,
+		o.CustomerID, o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard, o.Version,
+	).Scan(&o.UpdatedAt, &o.Status)
+	if err != nil {
+		return fmt.Errorf("could not update orders: %w", err)
+	}
+	if o.Timeline, err = p.getTimeline(ctx, tx, o.OrderUID); err != nil {
+		return err
+	}
+
+	// deleting old parts, they are inserted again from new version
+	for _, table := range []string{"delivery", "payment", "items"} {
+		if _, err := tx.Exec(ctx, "DELETE FROM "+table+" WHERE order_uid = $1", o.OrderUID); err != nil {
+			return fmt.Errorf("could not delete %s: %w", table, err)
+		}
+	}
+
+	return p.insertOrderPartsTx(ctx, tx, o, source)
+}
+
+// insertOrderPartsTx is a helper method to insert order delivery, payment, items
+// and order.saved outbox message within a given transaction
+func (p *Postgres) insertOrderPartsTx(ctx context.Context, tx pgx.Tx, o *models.Order, source models.Source) error {
 	// inserting delivery
-	_, err = tx.Exec(ctx, `
+	_, err := tx.Exec(ctx, `
 		INSERT INTO delivery (
 			order_uid, name, phone, zip, city, address, region, email
 		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
@@ -221,7 +329,8 @@ func (p *Postgres) insertOrderTx(ctx context.Context, tx pgx.Tx, o *models.Order
 		}
 	}
 
-	return nil
+	// inserting outbox message, so event is published if and only if order is saved
+	return p.insertOutboxTx(ctx, tx, models.EventOrderSaved, o.OrderUID, source, o)
 }
 
 // GetOrder retrieves an order by its UID with retry logic.
@@ -251,7 +360,7 @@ func (p *Postgres) GetOrder(ctx context.Context, uid string) (*models.Order, err
 		// using function, to defer request context cancel
 		func() {
 			defer cancel()
-			order, err = p.getOrder(reqCtx, uid)
+			order, err = p.getOrder(reqCtx, p.pool, uid)
 		}()
 
 		if err == nil {
@@ -283,7 +392,7 @@ func (p *Postgres) GetOrder(ctx context.Context, uid string) (*models.Order, err
 	return nil, fmt.Errorf("get order failed after %d attempts: %w", p.maxRetries, err)
 }
 
-func (p *Postgres) getOrder(ctx context.Context, uid string) (*models.Order, error) {
+func (p *Postgres) getOrder(ctx context.Context, q querier, uid string) (*models.Order, error) {
 	var order models.Order
 
 	// first request - order, delivery, payment
@@ -291,6 +400,7 @@ func (p *Postgres) getOrder(ctx context.Context, uid string) (*models.Order, err
 	SELECT 
 		o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
 		o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+		o.version, o.updated_at, o.status,
 
 		d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
 
@@ -302,10 +412,11 @@ func (p *Postgres) getOrder(ctx context.Context, uid string) (*models.Order, err
 	WHERE o.order_uid = $1
 	`
 
-	err := p.pool.QueryRow(ctx, queryOrder, uid).Scan(
+	err := q.QueryRow(ctx, queryOrder, uid).Scan(
 		// order
 		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale, &order.InternalSignature,
 		&order.CustomerID, &order.DeliveryService, &order.ShardKey, &order.SmID, &order.DateCreated, &order.OofShard,
+		&order.Version, &order.UpdatedAt, &order.Status,
 		// delivery
 		&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Zip, &order.Delivery.City,
 		&order.Delivery.Address, &order.Delivery.Region, &order.Delivery.Email,
@@ -330,7 +441,7 @@ func (p *Postgres) getOrder(ctx context.Context, uid string) (*models.Order, err
 	WHERE order_uid = $1
 	`
 
-	rows, err := p.pool.Query(ctx, queryItems, uid)
+	rows, err := q.Query(ctx, queryItems, uid)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query items: %w", err)
 	}
@@ -351,5 +462,11 @@ func (p *Postgres) getOrder(ctx context.Context, uid string) (*models.Order, err
 		return nil, err
 	}
 
+	// third request - status timeline
+	order.Timeline, err = p.getTimeline(ctx, q, uid)
+	if err != nil {
+		return nil, err
+	}
+
 	return &order, nil
 }
@@ -0,0 +1,252 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	noplogger "wb-tech-l0/internal/logger/nop"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/storage"
+)
+
+// Tests need disposable Postgres database, its schema is recreated with migrations.
+// They use POSTGRES_* variables with POSTGRES_TEST_DB database:
+//
+//	POSTGRES_TEST_DB=l0_test go test -tags integration ./internal/storage/postgres
+const testDBEnv = "POSTGRES_TEST_DB"
+
+// testOrderJSON is an order template, uid and transaction are replaced by testOrder
+const testOrderJSON = `{
+	"order_uid": "uid", "track_number": "WBILMTESTTRACK", "entry": "WBIL",
+	"delivery": {
+		"name": "Test Testov", "phone": "+9720000000", "zip": "2639809", "city": "Kiryat Mozkin",
+		"address": "Ploshad Mira 15", "region": "Kraiot", "email": "test@gmail.com"
+	},
+	"payment": {
+		"transaction": "uid", "request_id": "", "currency": "USD", "provider": "wbpay",
+		"amount": 1817, "payment_dt": 1637907727, "bank": "alpha", "delivery_cost": 1500, "goods_total": 317, "custom_fee": 0
+	},
+	"items": [{
+		"chrt_id": 9934930, "track_number": "WBILMTESTTRACK", "price": 453, "rid": "ab4219087a764ae0btest",
+		"name": "Mascaras", "sale": 30, "size": "0", "total_price": 317, "nm_id": 2389212, "brand": "Vivienne Sabo", "status": 202
+	}],
+	"locale": "en", "internal_signature": "", "customer_id": "test", "delivery_service": "meest",
+	"shardkey": "9", "sm_id": 99, "date_created": "2021-11-26T06:22:19Z", "oof_shard": "1"
+}`
+
+// testOrder returns valid order with uid, payment transaction and version
+func testOrder(t *testing.T, uid, transaction string, version int64) *models.Order {
+	t.Helper()
+	var order models.Order
+	if err := json.Unmarshal([]byte(testOrderJSON), &order); err != nil {
+		t.Fatalf("could not decode test order: %v", err)
+	}
+	order.OrderUID, order.Payment.Transaction, order.Version = uid, transaction, version
+	return &order
+}
+
+// newTestPostgres connects to test database, recreates its schema and applies migrations
+func newTestPostgres(t *testing.T) *Postgres {
+	t.Helper()
+	db := os.Getenv(testDBEnv)
+	if db == "" {
+		t.Skipf("%s is not set", testDBEnv)
+	}
+	t.Setenv("POSTGRES_DB", db)
+	t.Setenv("POSTGRES_RETRY_TIMEOUT", "100ms")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	p, err := New(ctx, cfg, noplogger.New())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if err := p.Close(); err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+	})
+
+	if _, err := p.pool.Exec(ctx, `DROP SCHEMA public CASCADE; CREATE SCHEMA public`); err != nil {
+		t.Fatalf("could not recreate schema: %v", err)
+	}
+	migrations, err := filepath.Glob("../../../migrations/*.up.sql")
+	if err != nil || len(migrations) == 0 {
+		t.Fatalf("could not find migrations: %v", err)
+	}
+	slices.Sort(migrations)
+	for _, migration := range migrations {
+		query, err := os.ReadFile(migration)
+		if err != nil {
+			t.Fatalf("could not read migration: %v", err)
+		}
+		if _, err := p.pool.Exec(ctx, string(query)); err != nil {
+			t.Fatalf("could not apply migration %s: %v", filepath.Base(migration), err)
+		}
+	}
+	return p
+}
+
+// count returns number of rows of table matching order uid
+func count(t *testing.T, p *Postgres, table, uid string) int {
+	t.Helper()
+	var n int
+	query := `SELECT COUNT(*) FROM ` + table + ` WHERE order_uid = $1`
+	if table == "outbox" {
+		query = `SELECT COUNT(*) FROM outbox WHERE key = $1`
+	}
+	if err := p.pool.QueryRow(context.Background(), query, uid).Scan(&n); err != nil {
+		t.Fatalf("could not count %s: %v", table, err)
+	}
+	return n
+}
+
+func TestSaveOrder(t *testing.T) {
+	p := newTestPostgres(t)
+	ctx := context.Background()
+	source := models.BrokerSource("orders", 0, 1)
+
+	updated := testOrder(t, "o1", "o1", 2)
+	updated.Items[0].Name = "Lipstick"
+	stale := testOrder(t, "o1", "o1", 1)
+	stale.Items[0].Name = "Stale"
+
+	tests := []struct {
+		name      string
+		order     *models.Order
+		want      storage.SaveResult
+		wantItem  string
+		revisions int
+	}{
+		{name: "created", order: testOrder(t, "o1", "o1", 1), want: storage.SaveCreated, wantItem: "Mascaras", revisions: 1},
+		{name: "duplicate is ignored", order: testOrder(t, "o1", "o1", 1), want: storage.SaveIgnored, wantItem: "Mascaras", revisions: 1},
+		{name: "greater version is updated", order: updated, want: storage.SaveUpdated, wantItem: "Lipstick", revisions: 2},
+		{name: "stale version is ignored", order: stale, want: storage.SaveIgnored, wantItem: "Lipstick", revisions: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := p.SaveOrder(ctx, tt.order, source, nil)
+			if err != nil {
+				t.Fatalf("SaveOrder() error = %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("SaveOrder() = %s, want %s", result, tt.want)
+			}
+
+			order, err := p.GetOrder(ctx, "o1")
+			if err != nil {
+				t.Fatalf("GetOrder() error = %v", err)
+			}
+			// items are replaced, not appended
+			if len(order.Items) != 1 || order.Items[0].Name != tt.wantItem {
+				t.Errorf("stored items = %+v, want single %s", order.Items, tt.wantItem)
+			}
+			// every created or updated order has revision and outbox message
+			if n := count(t, p, "order_revisions", "o1"); n != tt.revisions {
+				t.Errorf("revisions = %d, want %d", n, tt.revisions)
+			}
+			if n := count(t, p, "outbox", "o1"); n != tt.revisions {
+				t.Errorf("outbox messages = %d, want %d", n, tt.revisions)
+			}
+		})
+	}
+}
+
+func TestSaveOrderPosition(t *testing.T) {
+	p := newTestPostgres(t)
+	ctx := context.Background()
+	position := func(offset int64) *models.Position {
+		return &models.Position{Group: "g", Topic: "orders", Partition: 0, Offset: offset}
+	}
+
+	result, err := p.SaveOrder(ctx, testOrder(t, "o1", "o1", 1), models.BrokerSource("orders", 0, 0), position(1))
+	if err != nil || result != storage.SaveCreated {
+		t.Fatalf("SaveOrder() = %s, %v, want created", result, err)
+	}
+
+	// redelivered message is not saved again even with greater version
+	_, err = p.SaveOrder(ctx, testOrder(t, "o1", "o1", 2), models.BrokerSource("orders", 0, 0), position(1))
+	if !errors.Is(err, storage.ErrAlreadyProcessed) {
+		t.Fatalf("SaveOrder() error = %v, want %v", err, storage.ErrAlreadyProcessed)
+	}
+
+	// ignored order still advances position
+	result, err = p.SaveOrder(ctx, testOrder(t, "o1", "o1", 1), models.BrokerSource("orders", 0, 1), position(2))
+	if err != nil || result != storage.SaveIgnored {
+		t.Fatalf("SaveOrder() = %s, %v, want ignored", result, err)
+	}
+	offsets, err := p.Offsets(ctx, "g", "orders")
+	if err != nil {
+		t.Fatalf("Offsets() error = %v", err)
+	}
+	if offsets[0] != 2 {
+		t.Errorf("stored offset = %d, want 2", offsets[0])
+	}
+}
+
+func TestSaveOrderConflict(t *testing.T) {
+	p := newTestPostgres(t)
+	ctx := context.Background()
+	source := models.BrokerSource("orders", 0, 0)
+
+	if _, err := p.SaveOrder(ctx, testOrder(t, "o1", "tx", 1), source, nil); err != nil {
+		t.Fatalf("SaveOrder() error = %v", err)
+	}
+
+	// other order with the same payment transaction
+	_, err := p.SaveOrder(ctx, testOrder(t, "o2", "tx", 1), source, nil)
+	if !errors.Is(err, storage.ErrUniqueViolation) {
+		t.Fatalf("SaveOrder() error = %v, want %v", err, storage.ErrUniqueViolation)
+	}
+	if _, err := p.GetOrder(ctx, "o2"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("GetOrder() error = %v, want conflicting order not saved", err)
+	}
+}
+
+func TestSaveOrderConcurrent(t *testing.T) {
+	p := newTestPostgres(t)
+	ctx := context.Background()
+
+	// concurrent inserts of the same order violate orders_pkey,
+	// they are retried and ignored instead of reported as conflicts
+	const workers = 5
+	results := make([]storage.SaveResult, workers)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for i := range workers {
+		order := testOrder(t, "o1", "o1", 1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = p.SaveOrder(ctx, order, models.BrokerSource("orders", 0, int64(i)), nil)
+		}()
+	}
+	wg.Wait()
+
+	var got []string
+	for i := range workers {
+		if errs[i] != nil {
+			t.Fatalf("SaveOrder() error = %v", errs[i])
+		}
+		got = append(got, string(results[i]))
+	}
+	slices.Sort(got)
+	want := append([]string{string(storage.SaveCreated)}, slices.Repeat([]string{string(storage.SaveIgnored)}, workers-1)...)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("results = %v, want one created and others ignored", got)
+	}
+}
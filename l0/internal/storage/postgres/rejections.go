@@ -0,0 +1,186 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/server/middlewares"
+	"wb-tech-l0/internal/storage"
+)
+
+// rejectionColumns are selected columns of rejections scanned by scanRejection
+const rejectionColumns = `id, stream, reason, error, errors, message_key, payload, headers, source,
+	replayed_at, COALESCE(replayed_by, ''), created_at`
+
+// SaveRejection saves rejected message with generated ID.
+// Rejection of message at the same broker position is updated with new
+// errors and keeps its ID, creation and replay dates
+func (p *Postgres) SaveRejection(ctx context.Context, r *models.Rejection) error {
+	errorsData, err := json.Marshal(r.Errors)
+	if err != nil {
+		return fmt.Errorf("could not encode rejection errors: %w", err)
+	}
+	headersData, err := json.Marshal(r.Headers)
+	if err != nil {
+		return fmt.Errorf("could not encode rejection headers: %w", err)
+	}
+	sourceData, err := json.Marshal(r.Source)
+	if err != nil {
+		return fmt.Errorf("could not encode rejection source: %w", err)
+	}
+
+	return p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		return p.pool.QueryRow(ctx, `
+			INSERT INTO rejections (id, stream, reason, error, errors, message_key, payload, headers, source)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+			ON CONFLICT (source_topic, source_partition, source_offset) DO UPDATE SET
+				reason = EXCLUDED.reason,
+				error = EXCLUDED.error,
+				errors = EXCLUDED.errors,
+				message_key = EXCLUDED.message_key,
+				payload = EXCLUDED.payload,
+				headers = EXCLUDED.headers
+			RETURNING id, created_at
+		`,
+			uuid.NewString(), r.Stream, r.Reason, r.Error, errorsData, r.Key, r.Payload, headersData, sourceData,
+		).Scan(&r.ID, &r.CreatedAt)
+	})
+}
+
+// PurgeRejections deletes rejections created before given time
+func (p *Postgres) PurgeRejections(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+
+	err := p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		tag, err := p.pool.Exec(ctx, `DELETE FROM rejections WHERE created_at < $1`, before)
+		if err != nil {
+			return fmt.Errorf("failed to purge rejections: %w", err)
+		}
+		purged = tag.RowsAffected()
+		return nil
+	})
+
+	return purged, err
+}
+
+// ListRejections returns rejections matching filter, newest first.
+// Filter conditions are added only for its non-zero fields
+func (p *Postgres) ListRejections(ctx context.Context, filter storage.RejectionFilter) ([]models.Rejection, error) {
+	var conditions []string
+	var args []any
+	where := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(args))))
+	}
+	if filter.Reason != "" {
+		where("reason = ?", filter.Reason)
+	}
+	if !filter.From.IsZero() {
+		where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		where("created_at < ?", filter.To)
+	}
+
+	query := `SELECT ` + rejectionColumns + ` FROM rejections`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += ` ORDER BY created_at DESC LIMIT $` + strconv.Itoa(len(args))
+
+	log := p.log.With(logger.Field("request_id", middlewares.GetRequestID(ctx)))
+
+	var rejections []models.Rejection
+	err := p.withRetries(ctx, log, func(ctx context.Context) error {
+		rows, err := p.pool.Query(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to query rejections: %w", err)
+		}
+		rejections, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Rejection, error) {
+			return scanRejection(row)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rejections, nil
+}
+
+// GetRejection returns rejection by ID
+func (p *Postgres) GetRejection(ctx context.Context, id string) (*models.Rejection, error) {
+	if uuid.Validate(id) != nil {
+		return nil, storage.ErrNotFound
+	}
+
+	log := p.log.With(logger.Field("request_id", middlewares.GetRequestID(ctx)), logger.Field("rejection_id", id))
+
+	var rejection models.Rejection
+	err := p.withRetries(ctx, log, func(ctx context.Context) error {
+		var err error
+		rejection, err = scanRejection(p.pool.QueryRow(ctx, `SELECT `+rejectionColumns+` FROM rejections WHERE id = $1`, id))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ErrNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &rejection, nil
+}
+
+// MarkRejectionReplayed sets replay date and caller of rejection.
+// Replaying rejection again overwrites them
+func (p *Postgres) MarkRejectionReplayed(ctx context.Context, id, caller string) error {
+	if uuid.Validate(id) != nil {
+		return storage.ErrNotFound
+	}
+
+	return p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		tag, err := p.pool.Exec(ctx, `
+			UPDATE rejections SET replayed_at = NOW(), replayed_by = NULLIF($2, '') WHERE id = $1
+		`, id, caller)
+		if err != nil {
+			return fmt.Errorf("failed to mark rejection replayed: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return storage.ErrNotFound
+		}
+		return nil
+	})
+}
+
+// scanRejection scans rejection row selected with rejectionColumns
+func scanRejection(row pgx.Row) (models.Rejection, error) {
+	var r models.Rejection
+	var errorsData, headersData, sourceData []byte
+	err := row.Scan(&r.ID, &r.Stream, &r.Reason, &r.Error, &errorsData, &r.Key, &r.Payload, &headersData, &sourceData,
+		&r.ReplayedAt, &r.ReplayedBy, &r.CreatedAt)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(errorsData, &r.Errors); err != nil {
+		return r, fmt.Errorf("invalid rejection errors: %w", err)
+	}
+	if err := json.Unmarshal(headersData, &r.Headers); err != nil {
+		return r, fmt.Errorf("invalid rejection headers: %w", err)
+	}
+	if err := json.Unmarshal(sourceData, &r.Source); err != nil {
+		return r, fmt.Errorf("invalid rejection source: %w", err)
+	}
+	return r, nil
+}
@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/storage"
+)
+
+// withRetries calls fn with request timeout context max retries times or until success.
+// Permanent errors (storage errors, constraint violations, invalid data) are returned
+// immediately, as retrying them makes no sense. ctx is used as parent of request
+// contexts and for waiting between retries along with application context
+func (p *Postgres) withRetries(ctx context.Context, log logger.Logger, fn func(ctx context.Context) error) error {
+	var err error
+
+	for attempt := 1; attempt <= p.maxRetries; attempt++ {
+		// creating context for this retry with request timeout
+		reqCtx, cancel := context.WithTimeout(ctx, p.requestTimeout)
+		err = fn(reqCtx)
+		cancel()
+
+		if err == nil {
+			return nil
+		}
+		if isPermanent(err) {
+			return err
+		}
+
+		log.Warn("Storage operation failed", logger.Field("attempt", attempt), logger.Field("max_attempts", p.maxRetries), logger.Error(err))
+
+		// waiting for next try or app or caller context cancellation
+		if attempt < p.maxRetries {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-p.ctx.Done():
+				return p.ctx.Err()
+			case <-time.After(p.retryTimeout):
+				// continue retries
+			}
+		}
+	}
+
+	return fmt.Errorf("failed after %d attempts: %w", p.maxRetries, err)
+}
+
+// inTx calls fn within transaction and commits it if fn succeeds.
+// Transaction is rolled back on any error
+func (p *Postgres) inTx(ctx context.Context, log logger.Logger, fn func(tx pgx.Tx) error) error {
+	tx, err := p.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("could not begin tx: %w", err)
+	}
+
+	defer func() {
+		// transaction must be rolled back even if request context is timed out
+		// so using application context.
+		// rollback is safe to call if commit was successful
+		if err := tx.Rollback(p.ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Warn("Failed to rollback transaction", logger.Error(err))
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// isPermanent reports whether error can't be fixed by retrying
+func isPermanent(err error) bool {
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrUniqueViolation) ||
+		errors.Is(err, storage.ErrInvalidTransition) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// data exceptions and integrity constraint violations
+		return pgerrcode.IsDataException(pgErr.Code) || pgerrcode.IsIntegrityConstraintViolation(pgErr.Code)
+	}
+	return false
+}
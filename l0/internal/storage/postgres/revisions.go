@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/server/middlewares"
+	"wb-tech-l0/internal/storage"
+)
+
+// insertRevisionTx is a helper method to save order snapshot as next order revision
+// within a given transaction. Order row must be locked by transaction, so revision
+// numbers of the same order are not taken concurrently
+func (p *Postgres) insertRevisionTx(ctx context.Context, tx pgx.Tx, kind string, o *models.Order, source models.Source) error {
+	// timeline is a history itself, it is not a part of snapshot
+	snapshot := *o
+	snapshot.Timeline = nil
+
+	data, err := json.Marshal(&snapshot)
+	if err != nil {
+		return fmt.Errorf("could not encode order revision: %w", err)
+	}
+	sourceData, err := json.Marshal(source)
+	if err != nil {
+		return fmt.Errorf("could not encode revision source: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO order_revisions (order_uid, revision, kind, version, source, snapshot)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4, $5
+		FROM order_revisions WHERE order_uid = $1
+	`, o.OrderUID, kind, o.Version, sourceData, data)
+	if err != nil {
+		return fmt.Errorf("could not insert order revision: %w", err)
+	}
+	return nil
+}
+
+// GetOrderHistory returns order revisions, oldest first.
+// It is using user request context with timeout for requests
+func (p *Postgres) GetOrderHistory(ctx context.Context, uid string) ([]models.Revision, error) {
+	var revisions []models.Revision
+
+	log := p.log.With(logger.Field("order_uid", uid), logger.Field("request_id", middlewares.GetRequestID(ctx)))
+
+	err := p.withRetries(ctx, log, func(ctx context.Context) error {
+		var exists bool
+		err := p.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM orders WHERE order_uid = $1)`, uid).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check order: %w", err)
+		}
+		if !exists {
+			return storage.ErrNotFound
+		}
+
+		rows, err := p.pool.Query(ctx, `
+			SELECT revision, kind, version, source, snapshot, created_at
+			FROM order_revisions
+			WHERE order_uid = $1
+			ORDER BY revision
+		`, uid)
+		if err != nil {
+			return fmt.Errorf("failed to query order revisions: %w", err)
+		}
+
+		revisions, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Revision, error) {
+			var r models.Revision
+			var source, snapshot []byte
+			if err := row.Scan(&r.Revision, &r.Kind, &r.Version, &source, &snapshot, &r.CreatedAt); err != nil {
+				return r, err
+			}
+			if err := json.Unmarshal(source, &r.Source); err != nil {
+				return r, fmt.Errorf("invalid revision source: %w", err)
+			}
+			r.Order = &models.Order{}
+			if err := json.Unmarshal(snapshot, r.Order); err != nil {
+				return r, fmt.Errorf("invalid revision snapshot: %w", err)
+			}
+			return r, nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan order revisions: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
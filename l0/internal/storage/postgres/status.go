@@ -0,0 +1,200 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/storage"
+)
+
+// querier is a common part of pool and transaction used by helpers
+// that can be called both within and without transaction
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// ChangeOrderStatus takes order status change and tries to apply it max retries times or until success.
+// Order row is locked, so concurrent changes of the same order are serialized.
+// Changes of missing orders are parked and applied when order is created.
+// Every attempt has request timeout within ctx deadline
+func (p *Postgres) ChangeOrderStatus(ctx context.Context, change *models.StatusChange, source models.Source) (storage.SaveResult, error) {
+	var result storage.SaveResult
+
+	log := p.log.With(logger.Field("order_uid", change.OrderUID), logger.Field("status", change.Status))
+
+	err := p.withRetries(ctx, log, func(ctx context.Context) error {
+		return p.inTx(ctx, log, func(tx pgx.Tx) error {
+			var current models.OrderStatus
+			err := tx.QueryRow(ctx, `SELECT status FROM orders WHERE order_uid = $1 FOR UPDATE`, change.OrderUID).Scan(&current)
+			if errors.Is(err, pgx.ErrNoRows) {
+				// status message came before order message
+				result, err = p.parkStatusChangeTx(ctx, tx, change, source)
+				return err
+			}
+			if err != nil {
+				return fmt.Errorf("could not lock order: %w", err)
+			}
+
+			// repeated message of applied change
+			if current == change.Status {
+				result = storage.SaveIgnored
+				return nil
+			}
+			if !current.CanTransition(change.Status) {
+				return fmt.Errorf("%w: %s to %s", storage.ErrInvalidTransition, current, change.Status)
+			}
+
+			result = storage.SaveUpdated
+			return p.changeStatusTx(ctx, tx, current, change, source)
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	log.Debug("Order status change saved", logger.Field("result", result))
+	return result, nil
+}
+
+// changeStatusTx is a helper method to change status of locked order with current status,
+// saving transition, revision and order.status_changed outbox message within a given transaction
+func (p *Postgres) changeStatusTx(ctx context.Context, tx pgx.Tx, current models.OrderStatus, change *models.StatusChange, source models.Source) error {
+	_, err := tx.Exec(ctx, `UPDATE orders SET status = $2, updated_at = NOW() WHERE order_uid = $1`, change.OrderUID, change.Status)
+	if err != nil {
+		return fmt.Errorf("could not update order status: %w", err)
+	}
+
+	transition := models.StatusTransition{From: current, To: change.Status, ChangedAt: change.ChangedAt, Reason: change.Reason}
+	if err := p.insertTransitionTx(ctx, tx, change.OrderUID, transition); err != nil {
+		return err
+	}
+
+	// saving order with new status as revision
+	order, err := p.getOrder(ctx, tx, change.OrderUID)
+	if err != nil {
+		return err
+	}
+	if err := p.insertRevisionTx(ctx, tx, models.RevisionStatusChanged, order, source); err != nil {
+		return err
+	}
+
+	return p.insertOutboxTx(ctx, tx, models.EventOrderStatusChanged, change.OrderUID, source, change)
+}
+
+// parkStatusChangeTx is a helper method to save status change of missing order within a given transaction.
+// It returns SavePending or SaveIgnored if the same status is already parked
+func (p *Postgres) parkStatusChangeTx(ctx context.Context, tx pgx.Tx, change *models.StatusChange, source models.Source) (storage.SaveResult, error) {
+	sourceData, err := json.Marshal(source)
+	if err != nil {
+		return "", fmt.Errorf("could not encode status change source: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO pending_status_changes (order_uid, status, reason, changed_at, source)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+		ON CONFLICT (order_uid, status) DO NOTHING
+	`, change.OrderUID, change.Status, change.Reason, change.ChangedAt, sourceData)
+	if err != nil {
+		return "", fmt.Errorf("could not park status change: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.SaveIgnored, nil
+	}
+	return storage.SavePending, nil
+}
+
+// applyPendingStatusesTx is a helper method to apply parked status changes of just created order
+// within a given transaction. Changes are applied in order of their dates, not allowed
+// transitions are skipped. Applied and skipped changes are deleted, order status and
+// timeline are updated with applied ones
+func (p *Postgres) applyPendingStatusesTx(ctx context.Context, tx pgx.Tx, o *models.Order) error {
+	rows, err := tx.Query(ctx, `
+		WITH pending AS (
+			DELETE FROM pending_status_changes WHERE order_uid = $1
+			RETURNING id, status, COALESCE(reason, '') AS reason, changed_at, source
+		)
+		SELECT status, reason, changed_at, source FROM pending ORDER BY changed_at, id
+	`, o.OrderUID)
+	if err != nil {
+		return fmt.Errorf("could not take pending status changes: %w", err)
+	}
+
+	type pending struct {
+		change models.StatusChange
+		source models.Source
+	}
+	changes, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (pending, error) {
+		c := pending{change: models.StatusChange{OrderUID: o.OrderUID}}
+		var source []byte
+		if err := row.Scan(&c.change.Status, &c.change.Reason, &c.change.ChangedAt, &source); err != nil {
+			return c, err
+		}
+		return c, json.Unmarshal(source, &c.source)
+	})
+	if err != nil {
+		return fmt.Errorf("could not scan pending status changes: %w", err)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	for _, c := range changes {
+		if o.Status == c.change.Status {
+			continue
+		}
+		if !o.Status.CanTransition(c.change.Status) {
+			p.log.Warn("Skipping not allowed pending status change", logger.Field("order_uid", o.OrderUID),
+				logger.Field("from", o.Status), logger.Field("status", c.change.Status))
+			continue
+		}
+		if err := p.changeStatusTx(ctx, tx, o.Status, &c.change, c.source); err != nil {
+			return err
+		}
+		o.Status = c.change.Status
+	}
+
+	o.Timeline, err = p.getTimeline(ctx, tx, o.OrderUID)
+	return err
+}
+
+// insertTransitionTx is a helper method to insert status transition to order timeline within a given transaction
+func (p *Postgres) insertTransitionTx(ctx context.Context, tx pgx.Tx, uid string, t models.StatusTransition) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO order_status_history (order_uid, from_status, to_status, reason, changed_at)
+		VALUES ($1, NULLIF($2, ''), $3, NULLIF($4, ''), $5)
+	`, uid, t.From, t.To, t.Reason, t.ChangedAt)
+	if err != nil {
+		return fmt.Errorf("could not insert status transition: %w", err)
+	}
+	return nil
+}
+
+// getTimeline returns order status transitions in order they were saved
+func (p *Postgres) getTimeline(ctx context.Context, q querier, uid string) ([]models.StatusTransition, error) {
+	rows, err := q.Query(ctx, `
+		SELECT COALESCE(from_status, ''), to_status, COALESCE(reason, ''), changed_at
+		FROM order_status_history
+		WHERE order_uid = $1
+		ORDER BY id
+	`, uid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query status timeline: %w", err)
+	}
+
+	timeline, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.StatusTransition, error) {
+		var t models.StatusTransition
+		err := row.Scan(&t.From, &t.To, &t.Reason, &t.ChangedAt)
+		return t, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan status timeline: %w", err)
+	}
+	return timeline, nil
+}
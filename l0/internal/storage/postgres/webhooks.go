@@ -0,0 +1,334 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/server/middlewares"
+	"wb-tech-l0/internal/storage"
+)
+
+// CreateWebhook saves new webhook with generated ID.
+// Webhook is always created enabled
+func (p *Postgres) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	log := p.log.With(logger.Field("request_id", middlewares.GetRequestID(ctx)))
+
+	webhook.ID = uuid.NewString()
+	webhook.Enabled = true
+	webhook.ConsecutiveFailures = 0
+	if webhook.DeliveryServices == nil {
+		webhook.DeliveryServices = []string{}
+	}
+
+	return p.withRetries(ctx, log, func(ctx context.Context) error {
+		return p.pool.QueryRow(ctx, `
+			INSERT INTO webhooks (id, url, secret, events, delivery_services)
+			VALUES ($1,$2,$3,$4,$5)
+			RETURNING created_at
+		`,
+			webhook.ID, webhook.URL, webhook.Secret, webhook.Events, webhook.DeliveryServices,
+		).Scan(&webhook.CreatedAt)
+	})
+}
+
+// ListWebhooks returns all webhooks ordered by creation date
+func (p *Postgres) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+
+	err := p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		rows, err := p.pool.Query(ctx, `
+			SELECT id, url, secret, events, delivery_services, enabled, consecutive_failures, created_at
+			FROM webhooks
+			ORDER BY created_at
+		`)
+		if err != nil {
+			return fmt.Errorf("failed to query webhooks: %w", err)
+		}
+		defer rows.Close()
+
+		webhooks = webhooks[:0]
+		for rows.Next() {
+			var w models.Webhook
+			err := rows.Scan(&w.ID, &w.URL, &w.Secret, &w.Events, &w.DeliveryServices, &w.Enabled, &w.ConsecutiveFailures, &w.CreatedAt)
+			if err != nil {
+				return fmt.Errorf("failed to scan webhook: %w", err)
+			}
+			webhooks = append(webhooks, w)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes webhook. Its delivery log is deleted by cascade
+func (p *Postgres) DeleteWebhook(ctx context.Context, id string) error {
+	if uuid.Validate(id) != nil {
+		return storage.ErrNotFound
+	}
+
+	return p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		tag, err := p.pool.Exec(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("failed to delete webhook: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return storage.ErrNotFound
+		}
+		return nil
+	})
+}
+
+// EnableWebhook enables webhook and resets its failures counter
+func (p *Postgres) EnableWebhook(ctx context.Context, id string) error {
+	if uuid.Validate(id) != nil {
+		return storage.ErrNotFound
+	}
+
+	return p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		tag, err := p.pool.Exec(ctx, `
+			UPDATE webhooks SET enabled = TRUE, consecutive_failures = 0 WHERE id = $1
+		`, id)
+		if err != nil {
+			return fmt.Errorf("failed to enable webhook: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return storage.ErrNotFound
+		}
+		return nil
+	})
+}
+
+// SaveWebhookDelivery saves delivery attempt with generated ID
+func (p *Postgres) SaveWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	d.ID = uuid.NewString()
+
+	return p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		return p.pool.QueryRow(ctx, `
+			INSERT INTO webhook_deliveries (
+				id, webhook_id, event_id, event, order_uid, attempt,
+				status_code, error, success, duration_ms
+			) VALUES ($1,$2,$3,$4,$5,$6,$7,NULLIF($8, ''),$9,$10)
+			RETURNING created_at
+		`,
+			d.ID, d.WebhookID, d.EventID, d.Event, d.OrderUID, d.Attempt,
+			d.StatusCode, d.Error, d.Success, d.DurationMs,
+		).Scan(&d.CreatedAt)
+	})
+}
+
+// ListWebhookDeliveries returns at most limit last delivery attempts of webhook,
+// newest first. It returns ErrNotFound if there is no such webhook
+func (p *Postgres) ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]models.WebhookDelivery, error) {
+	if uuid.Validate(webhookID) != nil {
+		return nil, storage.ErrNotFound
+	}
+
+	var deliveries []models.WebhookDelivery
+
+	err := p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		var exists bool
+		err := p.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1)`, webhookID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check webhook: %w", err)
+		}
+		if !exists {
+			return storage.ErrNotFound
+		}
+
+		rows, err := p.pool.Query(ctx, `
+			SELECT id, webhook_id, event_id, event, order_uid, attempt,
+				status_code, COALESCE(error, ''), success, duration_ms, created_at
+			FROM webhook_deliveries
+			WHERE webhook_id = $1
+			ORDER BY created_at DESC
+			LIMIT $2
+		`, webhookID, limit)
+		if err != nil {
+			return fmt.Errorf("failed to query webhook deliveries: %w", err)
+		}
+		defer rows.Close()
+
+		deliveries = deliveries[:0]
+		for rows.Next() {
+			var d models.WebhookDelivery
+			err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.Event, &d.OrderUID, &d.Attempt,
+				&d.StatusCode, &d.Error, &d.Success, &d.DurationMs, &d.CreatedAt)
+			if err != nil {
+				return fmt.Errorf("failed to scan webhook delivery: %w", err)
+			}
+			deliveries = append(deliveries, d)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// RecordWebhookResult resets failures counter on success and increments it on failure.
+// Webhook is disabled when counter reaches disableAfter, disableAfter 0 means never
+func (p *Postgres) RecordWebhookResult(ctx context.Context, id string, success bool, disableAfter int) (bool, error) {
+	var disabled bool
+
+	err := p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		// previous enabled state is selected in the same statement
+		// to report only disabling made by this call
+		err := p.pool.QueryRow(ctx, `
+			WITH prev AS (SELECT enabled FROM webhooks WHERE id = $1 FOR UPDATE)
+			UPDATE webhooks w SET
+				consecutive_failures = CASE WHEN $2 THEN 0 ELSE w.consecutive_failures + 1 END,
+				enabled = w.enabled AND ($2 OR $3 = 0 OR w.consecutive_failures + 1 < $3)
+			FROM prev
+			WHERE w.id = $1
+			RETURNING prev.enabled AND NOT w.enabled
+		`, id, success, disableAfter).Scan(&disabled)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.ErrNotFound
+		}
+		return err
+	})
+
+	return disabled, err
+}
+
+// FanOutWebhooks locks outbox messages not fanned out yet with SKIP LOCKED, so concurrent
+// dispatchers take different messages, and saves their webhook jobs in the same transaction
+func (p *Postgres) FanOutWebhooks(ctx context.Context, limit int, fanOut func(messages []models.OutboxMessage) ([]models.WebhookJob, error)) (int, error) {
+	var taken int
+
+	err := p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		taken = 0
+		return p.inTx(ctx, p.log, func(tx pgx.Tx) error {
+			rows, err := tx.Query(ctx, `
+				SELECT id, event, key, payload, created_at, trace_parent
+				FROM outbox
+				WHERE webhooks_at IS NULL
+				ORDER BY id
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			`, limit)
+			if err != nil {
+				return fmt.Errorf("failed to query outbox: %w", err)
+			}
+			messages, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.OutboxMessage, error) {
+				var m models.OutboxMessage
+				err := row.Scan(&m.ID, &m.Event, &m.Key, &m.Payload, &m.CreatedAt, &m.TraceParent)
+				return m, err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to scan outbox: %w", err)
+			}
+			if len(messages) == 0 {
+				return nil
+			}
+
+			jobs, err := fanOut(messages)
+			if err != nil {
+				return fmt.Errorf("failed to fan out outbox: %w", err)
+			}
+
+			batch := &pgx.Batch{}
+			for _, j := range jobs {
+				// job of the same event could be saved by message fanned out before lost commit
+				batch.Queue(`
+					INSERT INTO webhook_jobs (webhook_id, event_id, event, order_uid, payload, trace_parent)
+					VALUES ($1,$2,$3,$4,$5,$6)
+					ON CONFLICT (webhook_id, event_id) DO NOTHING
+				`, j.Webhook.ID, j.EventID, j.Event, j.OrderUID, j.Payload, j.TraceParent)
+			}
+			ids := make([]int64, len(messages))
+			for i := range messages {
+				ids[i] = messages[i].ID
+			}
+			batch.Queue(`UPDATE outbox SET webhooks_at = NOW() WHERE id = ANY($1)`, ids)
+			if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+				return fmt.Errorf("failed to save webhook jobs: %w", err)
+			}
+
+			taken = len(messages)
+			return nil
+		})
+	})
+
+	return taken, err
+}
+
+// ClaimWebhookJobs locks due jobs until lease expires, jobs are ordered by their next attempt date
+func (p *Postgres) ClaimWebhookJobs(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookJob, error) {
+	var jobs []models.WebhookJob
+
+	err := p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		rows, err := p.pool.Query(ctx, `
+			WITH claimed AS (
+				UPDATE webhook_jobs SET locked_until = NOW() + make_interval(secs => $2)
+				WHERE id IN (
+					SELECT id FROM webhook_jobs
+					WHERE next_attempt_at <= NOW() AND (locked_until IS NULL OR locked_until <= NOW())
+					ORDER BY next_attempt_at
+					LIMIT $1
+					FOR UPDATE SKIP LOCKED
+				)
+				RETURNING id, webhook_id, event_id, event, order_uid, payload, attempt, trace_parent
+			)
+			SELECT c.id, c.event_id, c.event, c.order_uid, c.payload, c.attempt, c.trace_parent,
+				w.id, w.url, w.secret, w.enabled
+			FROM claimed c
+			JOIN webhooks w ON w.id = c.webhook_id
+		`, limit, lease.Seconds())
+		if err != nil {
+			return fmt.Errorf("failed to claim webhook jobs: %w", err)
+		}
+		jobs, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.WebhookJob, error) {
+			var j models.WebhookJob
+			err := row.Scan(&j.ID, &j.EventID, &j.Event, &j.OrderUID, &j.Payload, &j.Attempt, &j.TraceParent,
+				&j.Webhook.ID, &j.Webhook.URL, &j.Webhook.Secret, &j.Webhook.Enabled)
+			return j, err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan webhook jobs: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// RetryWebhookJob increments job attempt, unlocks it and schedules it at given time
+func (p *Postgres) RetryWebhookJob(ctx context.Context, id int64, at time.Time) error {
+	return p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		_, err := p.pool.Exec(ctx, `
+			UPDATE webhook_jobs SET attempt = attempt + 1, next_attempt_at = $2, locked_until = NULL
+			WHERE id = $1
+		`, id, at)
+		if err != nil {
+			return fmt.Errorf("failed to retry webhook job: %w", err)
+		}
+		return nil
+	})
+}
+
+// DeleteWebhookJob deletes job. Missing jobs (for example, of deleted webhooks) are ignored
+func (p *Postgres) DeleteWebhookJob(ctx context.Context, id int64) error {
+	return p.withRetries(ctx, p.log, func(ctx context.Context) error {
+		if _, err := p.pool.Exec(ctx, `DELETE FROM webhook_jobs WHERE id = $1`, id); err != nil {
+			return fmt.Errorf("failed to delete webhook job: %w", err)
+		}
+		return nil
+	})
+}
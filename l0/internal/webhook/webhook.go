@@ -0,0 +1,341 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"wb-tech-l0/internal/config"
+	"wb-tech-l0/internal/logger"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/pii"
+	"wb-tech-l0/internal/storage"
+)
+
+// EventOrderSaved is an event type of orders saved by broker handler
+const EventOrderSaved = models.EventOrderSaved
+
+// Webhook request headers
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderID        = "X-Webhook-ID"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	// HeaderTraceParent is a W3C traceparent of span event was saved in
+	HeaderTraceParent = "traceparent"
+)
+
+// leaseMargin is added to delivery timeout to get lease of claimed jobs,
+// it covers saving of delivery results
+const leaseMargin = 30 * time.Second
+
+// Payload is a body of webhook request
+type Payload struct {
+	// ID is an event identifier, the same for all delivery attempts.
+	// Receivers can use it to deduplicate events
+	ID        string        `json:"id"`
+	Event     string        `json:"event"`
+	CreatedAt time.Time     `json:"created_at"`
+	Data      *models.Order `json:"data"`
+}
+
+// Dispatcher delivers order events to subscribed webhooks.
+// Events are taken from transactional outbox, so every saved order
+// is delivered even after restart. Every event is fanned out to jobs of
+// matching webhooks stored in the same transaction, then jobs are claimed
+// and delivered by workers. Failed deliveries are retried with exponential
+// backoff from storage and webhooks are disabled after too many failed events.
+// Orders in payloads have delivery PII masked
+type Dispatcher struct {
+	cfg    *config.WebhookConfig
+	store  storage.WebhookStorage
+	masker *pii.Masker
+	client *http.Client
+	log    logger.Logger
+}
+
+// New creates and returns Dispatcher. Workers must be started with Run
+func New(cfg *config.WebhookConfig, store storage.WebhookStorage, masker *pii.Masker, log logger.Logger) *Dispatcher {
+	return &Dispatcher{
+		cfg:    cfg,
+		store:  store,
+		masker: masker,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// redirects are not followed, webhook URL must be final
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		log: log,
+	}
+}
+
+// Run polls outbox and jobs every PollInterval and delivers due jobs
+// by at most Workers concurrent deliveries. It blocks until ctx is done
+// and all running deliveries exited
+func (d *Dispatcher) Run(ctx context.Context) {
+	d.log.Debug("Starting webhooks dispatcher loop")
+	defer d.log.Debug("Webhooks dispatcher loop exited")
+
+	poll := time.NewTicker(d.cfg.PollInterval)
+	defer poll.Stop()
+
+	// workers is a semaphore of running deliveries
+	workers := make(chan struct{}, d.cfg.Workers)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+		}
+
+		d.fanOut(ctx)
+
+		// claiming jobs only for free workers, so claimed jobs
+		// are sent right away and their leases don't expire
+		free := cap(workers) - len(workers)
+		if free == 0 {
+			continue
+		}
+		jobs, err := d.store.ClaimWebhookJobs(ctx, free, d.cfg.Timeout+leaseMargin)
+		if err != nil {
+			d.log.Warn("Failed to claim webhook jobs", logger.Error(err))
+			continue
+		}
+		for _, j := range jobs {
+			workers <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer func() {
+					<-workers
+					wg.Done()
+				}()
+				d.deliver(ctx, j)
+			}()
+		}
+	}
+}
+
+// fanOut saves webhook jobs of outbox events until outbox is drained or fanning out fails.
+// Failed events stay in outbox and are fanned out on next poll
+func (d *Dispatcher) fanOut(ctx context.Context) {
+	for ctx.Err() == nil {
+		webhooks, err := d.store.ListWebhooks(ctx)
+		if err != nil {
+			d.log.Warn("Failed to list webhooks", logger.Error(err))
+			return
+		}
+
+		taken, err := d.store.FanOutWebhooks(ctx, d.cfg.BatchSize, func(messages []models.OutboxMessage) ([]models.WebhookJob, error) {
+			return Jobs(messages, webhooks, d.masker)
+		})
+		if err != nil {
+			d.log.Warn("Failed to fan out webhooks events", logger.Error(err))
+			return
+		}
+		if taken < d.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// Jobs returns delivery jobs of order.saved outbox messages to matching webhooks.
+// Event ID is an outbox message ID, so it is the same for all webhooks and attempts.
+// Other events are skipped
+func Jobs(messages []models.OutboxMessage, webhooks []models.Webhook, masker *pii.Masker) ([]models.WebhookJob, error) {
+	var jobs []models.WebhookJob
+	for _, m := range messages {
+		if m.Event != EventOrderSaved {
+			continue
+		}
+
+		var order models.Order
+		if err := json.Unmarshal(m.Payload, &order); err != nil {
+			return nil, fmt.Errorf("could not decode outbox message %d: %w", m.ID, err)
+		}
+		eventID := strconv.FormatInt(m.ID, 10)
+
+		var body []byte
+		for i := range webhooks {
+			if !webhooks[i].Matches(EventOrderSaved, order.DeliveryService) {
+				continue
+			}
+			// encoding lazily, most orders could have no subscribers
+			if body == nil {
+				var err error
+				body, err = json.Marshal(Payload{
+					ID:        eventID,
+					Event:     EventOrderSaved,
+					CreatedAt: m.CreatedAt.UTC(),
+					// webhook receivers have no caller role
+					Data: masker.Apply(&order, nil),
+				})
+				if err != nil {
+					return nil, fmt.Errorf("could not encode webhook payload: %w", err)
+				}
+			}
+			jobs = append(jobs, models.WebhookJob{
+				Webhook:  webhooks[i],
+				EventID:  eventID,
+				Event:    EventOrderSaved,
+				OrderUID: order.OrderUID,
+				Payload:  body,
+				Attempt:  1,
+				// receivers can continue trace of order message
+				TraceParent: m.TraceParent,
+			})
+		}
+	}
+	return jobs, nil
+}
+
+// deliver makes single delivery attempt of job, saves it to delivery log and
+// schedules retry or records final event result
+func (d *Dispatcher) deliver(ctx context.Context, j models.WebhookJob) {
+	log := d.log.With(
+		logger.Field("webhook_id", j.Webhook.ID), logger.Field("event_id", j.EventID),
+		logger.Field("order_uid", j.OrderUID), logger.Field("attempt", j.Attempt),
+	)
+
+	// webhook could be disabled after job was saved
+	if !j.Webhook.Enabled {
+		log.Debug("Webhook is disabled. Dropping job")
+		if err := d.store.DeleteWebhookJob(ctx, j.ID); err != nil {
+			log.Warn("Failed to delete webhook job", logger.Error(err))
+		}
+		return
+	}
+
+	delivery := d.send(ctx, j)
+	if err := d.store.SaveWebhookDelivery(ctx, delivery); err != nil {
+		log.Warn("Failed to save webhook delivery", logger.Error(err))
+	}
+
+	if !delivery.Success && j.Attempt < d.cfg.MaxAttempts {
+		delay := Backoff(j.Attempt, d.cfg.BackoffBase, d.cfg.BackoffMax, rand.Float64())
+		log.Debug("Webhook delivery failed. Retrying", logger.Field("delay", delay), logger.Field("error", delivery.Error))
+		// if retry is not saved, job is claimed again after its lease
+		if err := d.store.RetryWebhookJob(ctx, j.ID, time.Now().Add(delay)); err != nil {
+			log.Warn("Failed to schedule webhook job retry", logger.Error(err))
+		}
+		return
+	}
+
+	if delivery.Success {
+		log.Debug("Webhook delivered")
+	} else {
+		log.Warn("Webhook delivery failed. No attempts left", logger.Field("error", delivery.Error))
+	}
+
+	// if job is not deleted, it is delivered again after its lease,
+	// receivers deduplicate events by ID
+	if err := d.store.DeleteWebhookJob(ctx, j.ID); err != nil {
+		log.Warn("Failed to delete webhook job", logger.Error(err))
+		return
+	}
+
+	disabled, err := d.store.RecordWebhookResult(ctx, j.Webhook.ID, delivery.Success, d.cfg.DisableAfter)
+	if err != nil {
+		log.Warn("Failed to record webhook result", logger.Error(err))
+		return
+	}
+	if disabled {
+		log.Warn("Webhook disabled after too many failed deliveries", logger.Field("disable_after", d.cfg.DisableAfter))
+	}
+}
+
+// send posts signed payload to webhook and returns delivery log record
+func (d *Dispatcher) send(ctx context.Context, j models.WebhookJob) *models.WebhookDelivery {
+	delivery := &models.WebhookDelivery{
+		WebhookID: j.Webhook.ID,
+		EventID:   j.EventID,
+		Event:     j.Event,
+		OrderUID:  j.OrderUID,
+		Attempt:   j.Attempt,
+	}
+
+	start := time.Now()
+	defer func() {
+		delivery.DurationMs = time.Since(start).Milliseconds()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, j.Webhook.URL, bytes.NewReader(j.Payload))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "wb-tech-l0-webhook")
+	req.Header.Set(HeaderSignature, Sign(j.Webhook.Secret, start.Unix(), j.Payload))
+	req.Header.Set(HeaderID, j.Webhook.ID)
+	req.Header.Set(HeaderEvent, j.Event)
+	req.Header.Set(HeaderDelivery, j.EventID)
+	if j.TraceParent != "" {
+		req.Header.Set(HeaderTraceParent, j.TraceParent)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			d.log.Debug("Failed to close webhook response", logger.Error(err))
+		}
+	}()
+	// draining limited part of body to reuse connection
+	if _, err := io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)); err != nil {
+		d.log.Debug("Failed to drain webhook response", logger.Error(err))
+	}
+
+	delivery.StatusCode = resp.StatusCode
+	delivery.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !delivery.Success {
+		delivery.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return delivery
+}
+
+// Sign returns signature header value for body sent at timestamp (unix seconds).
+// Signature is a hex HMAC-SHA256 of "<timestamp>.<body>" with webhook secret,
+// header value is "t=<timestamp>,v1=<signature>". Receivers must compute
+// the same signature and reject requests with old timestamps to prevent replays
+func Sign(secret string, timestamp int64, body []byte) string {
+	t := strconv.FormatInt(timestamp, 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns delay before next attempt after failed attempt.
+// Delay is base doubled for every attempt and capped with maxDelay.
+// Half of delay is randomized with jitter in [0, 1) to spread retries
+func Backoff(attempt int, base, maxDelay time.Duration, jitter float64) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay/2 + time.Duration(jitter*float64(delay/2))
+}
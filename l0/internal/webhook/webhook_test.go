@@ -0,0 +1,245 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"wb-tech-l0/internal/config"
+	noplogger "wb-tech-l0/internal/logger/nop"
+	"wb-tech-l0/internal/models"
+	"wb-tech-l0/internal/pii"
+	"wb-tech-l0/internal/storage"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	got := Sign("secret", 1700000000, body)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "t=1700000000,v1=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base, maxDelay := time.Second, 10*time.Second
+	tests := []struct {
+		attempt int
+		jitter  float64
+		want    time.Duration
+	}{
+		{attempt: 1, jitter: 0, want: 500 * time.Millisecond},
+		{attempt: 1, jitter: 1, want: time.Second},
+		{attempt: 3, jitter: 0.5, want: 3 * time.Second},
+		{attempt: 10, jitter: 1, want: maxDelay},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempt, base, maxDelay, tt.jitter); got != tt.want {
+			t.Errorf("Backoff(%d, %v) = %v, want %v", tt.attempt, tt.jitter, got, tt.want)
+		}
+	}
+}
+
+// fakeStorage is a WebhookStorage with single webhook and in-memory outbox and jobs
+type fakeStorage struct {
+	storage.WebhookStorage
+	webhook models.Webhook
+
+	mu         sync.Mutex
+	outbox     []models.OutboxMessage
+	jobs       map[int64]*fakeJob
+	nextID     int64
+	deliveries []models.WebhookDelivery
+	results    chan bool
+}
+
+// fakeJob is a stored job with its schedule
+type fakeJob struct {
+	job    models.WebhookJob
+	at     time.Time
+	locked bool
+}
+
+func (s *fakeStorage) ListWebhooks(context.Context) ([]models.Webhook, error) {
+	return []models.Webhook{s.webhook}, nil
+}
+
+func (s *fakeStorage) FanOutWebhooks(_ context.Context, limit int, fanOut func([]models.OutboxMessage) ([]models.WebhookJob, error)) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch := s.outbox[:min(limit, len(s.outbox))]
+	jobs, err := fanOut(batch)
+	if err != nil {
+		return 0, err
+	}
+	for _, j := range jobs {
+		s.nextID++
+		j.ID = s.nextID
+		s.jobs[j.ID] = &fakeJob{job: j}
+	}
+	s.outbox = s.outbox[len(batch):]
+	return len(batch), nil
+}
+
+func (s *fakeStorage) ClaimWebhookJobs(_ context.Context, limit int, _ time.Duration) ([]models.WebhookJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var jobs []models.WebhookJob
+	for _, j := range s.jobs {
+		if len(jobs) < limit && !j.locked && !j.at.After(time.Now()) {
+			j.locked = true
+			jobs = append(jobs, j.job)
+		}
+	}
+	return jobs, nil
+}
+
+func (s *fakeStorage) RetryWebhookJob(_ context.Context, id int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j := s.jobs[id]
+	j.job.Attempt++
+	j.at, j.locked = at, false
+	return nil
+}
+
+func (s *fakeStorage) DeleteWebhookJob(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+	return nil
+}
+
+func (s *fakeStorage) SaveWebhookDelivery(_ context.Context, d *models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries = append(s.deliveries, *d)
+	return nil
+}
+
+func (s *fakeStorage) RecordWebhookResult(_ context.Context, _ string, success bool, _ int) (bool, error) {
+	s.results <- success
+	return false, nil
+}
+
+func TestJobs(t *testing.T) {
+	masker := pii.New(&config.MaskingConfig{})
+	webhooks := []models.Webhook{
+		{ID: "all", Events: []string{EventOrderSaved}, Enabled: true},
+		{ID: "meest", Events: []string{EventOrderSaved}, DeliveryServices: []string{"meest"}, Enabled: true},
+		{ID: "disabled", Events: []string{EventOrderSaved}},
+	}
+	messages := []models.OutboxMessage{
+		{ID: 1, Event: EventOrderSaved, Payload: []byte(`{"order_uid":"o1","delivery_service":"meest"}`)},
+		{ID: 2, Event: models.EventOrderStatusChanged, Payload: []byte(`{"order_uid":"o1"}`)},
+		{ID: 3, Event: EventOrderSaved, Payload: []byte(`{"order_uid":"o2","delivery_service":"dhl"}`)},
+	}
+
+	jobs, err := Jobs(messages, webhooks, masker)
+	if err != nil {
+		t.Fatalf("Jobs() error = %v", err)
+	}
+	var got []string
+	for _, j := range jobs {
+		got = append(got, j.Webhook.ID+":"+j.EventID)
+	}
+	want := []string{"all:1", "meest:1", "all:3"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("jobs = %v, want %v", got, want)
+	}
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	var calls int
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signature := r.Header.Get(HeaderSignature)
+		timestamp := strings.TrimPrefix(strings.Split(signature, ",")[0], "t=")
+		mac := hmac.New(sha256.New, []byte("0123456789abcdef"))
+		mac.Write([]byte(timestamp + "." + string(body)))
+		if !strings.HasSuffix(signature, "v1="+hex.EncodeToString(mac.Sum(nil))) {
+			t.Errorf("invalid signature %s", signature)
+		}
+		if !strings.Contains(string(body), `"phone":"+7******1234"`) {
+			t.Errorf("payload %s has unmasked phone", body)
+		}
+		if r.Header.Get(HeaderDelivery) != "7" {
+			t.Errorf("event id = %s, want outbox message id", r.Header.Get(HeaderDelivery))
+		}
+		if r.Header.Get(HeaderTraceParent) != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+			t.Errorf("traceparent = %s, want saving span", r.Header.Get(HeaderTraceParent))
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		// first attempt fails
+		if calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	store := &fakeStorage{
+		webhook: models.Webhook{
+			ID: "w1", URL: server.URL, Secret: "0123456789abcdef",
+			Events: []string{EventOrderSaved}, Enabled: true,
+		},
+		outbox: []models.OutboxMessage{{
+			ID: 7, Event: EventOrderSaved, Key: "o1", TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			Payload: []byte(`{"order_uid":"o1","delivery_service":"meest","delivery":{"phone":"+79991231234"}}`),
+		}},
+		jobs:    make(map[int64]*fakeJob),
+		results: make(chan bool, 1),
+	}
+	cfg := &config.WebhookConfig{
+		Workers: 2, PollInterval: 10 * time.Millisecond, BatchSize: 10, Timeout: time.Second,
+		MaxAttempts: 3, BackoffBase: 10 * time.Millisecond, BackoffMax: 10 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	masker := pii.New(&config.MaskingConfig{Fields: map[string]string{"phone": pii.StrategyPhone}})
+	dispatcher := New(cfg, store, masker, noplogger.New())
+	go dispatcher.Run(ctx)
+
+	select {
+	case success := <-store.results:
+		if !success {
+			t.Fatal("event delivery failed, want success on second attempt")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not delivered")
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.deliveries) != 2 {
+		t.Fatalf("saved %d deliveries, want 2", len(store.deliveries))
+	}
+	if store.deliveries[0].Success || store.deliveries[0].StatusCode != http.StatusInternalServerError {
+		t.Errorf("first delivery = %+v, want failed with 500", store.deliveries[0])
+	}
+	if !store.deliveries[1].Success || store.deliveries[1].Attempt != 2 {
+		t.Errorf("second delivery = %+v, want successful second attempt", store.deliveries[1])
+	}
+	if len(store.jobs) != 0 {
+		t.Errorf("jobs = %d after delivery, want 0", len(store.jobs))
+	}
+}
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event TEXT NOT NULL,
+    key TEXT NOT NULL,
+    payload BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMPTZ,
+    -- messages are fanned out to webhooks independently of relaying to broker
+    webhooks_at TIMESTAMPTZ,
+    -- pending messages are leased by relays while they are published outside of transaction
+    locked_until TIMESTAMPTZ,
+    -- trace of handling span message was saved in, published events and webhooks continue it
+    trace_parent TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx
+    ON outbox (id) WHERE sent_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS outbox_webhooks_pending_idx
+    ON outbox (id) WHERE webhooks_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS outbox_sent_at_idx
+    ON outbox (sent_at) WHERE sent_at IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS order_revisions;
+
+DROP FUNCTION IF EXISTS order_revisions_immutable();
\ No newline at end of file
@@ -0,0 +1,74 @@
+CREATE TABLE IF NOT EXISTS order_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid TEXT NOT NULL REFERENCES orders(order_uid) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    version BIGINT NOT NULL,
+    source JSONB NOT NULL,
+    snapshot JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (order_uid, revision)
+);
+
+-- revisions are immutable, deleting orders with history is not allowed too
+CREATE OR REPLACE FUNCTION order_revisions_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'order revisions are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER order_revisions_no_update
+    BEFORE UPDATE ON order_revisions
+    FOR EACH ROW EXECUTE FUNCTION order_revisions_immutable();
+
+CREATE TRIGGER order_revisions_no_delete
+    BEFORE DELETE ON order_revisions
+    FOR EACH ROW EXECUTE FUNCTION order_revisions_immutable();
+
+CREATE TRIGGER order_revisions_no_truncate
+    BEFORE TRUNCATE ON order_revisions
+    FOR EACH STATEMENT EXECUTE FUNCTION order_revisions_immutable();
+
+-- orders saved before revisions have no history. their current state
+-- is saved as first revision, so every order has at least one revision
+INSERT INTO order_revisions (order_uid, revision, kind, version, source, snapshot, created_at)
+SELECT
+    o.order_uid, 1, 'created', o.version, '{"kind": "migration"}'::jsonb,
+    jsonb_build_object(
+        'order_uid', o.order_uid,
+        'track_number', o.track_number,
+        'entry', o.entry,
+        'delivery', jsonb_build_object(
+            'name', d.name, 'phone', d.phone, 'zip', d.zip, 'city', d.city,
+            'address', d.address, 'region', d.region, 'email', d.email
+        ),
+        'payment', jsonb_build_object(
+            'transaction', p.transaction, 'request_id', COALESCE(p.request_id, ''),
+            'currency', p.currency, 'provider', p.provider, 'amount', p.amount,
+            'payment_dt', p.payment_dt, 'bank', p.bank, 'delivery_cost', p.delivery_cost,
+            'goods_total', p.goods_total, 'custom_fee', p.custom_fee
+        ),
+        'items', COALESCE((
+            SELECT jsonb_agg(jsonb_build_object(
+                'chrt_id', i.chrt_id, 'track_number', i.track_number, 'price', i.price,
+                'rid', i.rid, 'name', i.name, 'sale', i.sale, 'size', i.size,
+                'total_price', i.total_price, 'nm_id', i.nm_id, 'brand', i.brand, 'status', i.status
+            ) ORDER BY i.id)
+            FROM items i WHERE i.order_uid = o.order_uid
+        ), '[]'::jsonb),
+        'locale', o.locale,
+        'internal_signature', COALESCE(o.internal_signature, ''),
+        'customer_id', o.customer_id,
+        'delivery_service', o.delivery_service,
+        'shardkey', o.shardkey,
+        'sm_id', o.sm_id,
+        'date_created', o.date_created,
+        'oof_shard', o.oof_shard,
+        'version', o.version,
+        'updated_at', o.updated_at,
+        'status', o.status
+    ),
+    o.updated_at
+FROM orders o
+JOIN delivery d ON d.order_uid = o.order_uid
+JOIN payment p ON p.order_uid = o.order_uid;
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS rejections (
+    id UUID PRIMARY KEY,
+    stream TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    error TEXT NOT NULL,
+    errors JSONB NOT NULL,
+    message_key TEXT NOT NULL,
+    payload BYTEA NOT NULL,
+    headers JSONB NOT NULL,
+    source JSONB NOT NULL,
+    -- broker position of rejected message, redeliveries and replays of the same message update one rejection
+    source_topic TEXT GENERATED ALWAYS AS (source->>'topic') STORED,
+    source_partition INT GENERATED ALWAYS AS ((source->>'partition')::INT) STORED,
+    source_offset BIGINT GENERATED ALWAYS AS ((source->>'offset')::BIGINT) STORED,
+    replayed_at TIMESTAMPTZ,
+    replayed_by TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS rejections_created_at_idx ON rejections (created_at DESC);
+
+CREATE INDEX IF NOT EXISTS rejections_reason_created_at_idx ON rejections (reason, created_at DESC);
+
+CREATE UNIQUE INDEX IF NOT EXISTS rejections_source_position_idx ON rejections (source_topic, source_partition, source_offset);
@@ -0,0 +1,18 @@
+-- deliveries of outbox messages to webhooks, so they survive restarts and are retried
+CREATE TABLE IF NOT EXISTS webhook_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_id TEXT NOT NULL,
+    event TEXT NOT NULL,
+    order_uid TEXT NOT NULL,
+    payload BYTEA NOT NULL,
+    attempt INTEGER NOT NULL DEFAULT 1,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMPTZ,
+    trace_parent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_jobs_next_attempt_at_idx
+    ON webhook_jobs (next_attempt_at);
@@ -0,0 +1,12 @@
+-- status changes of orders that are not saved yet. they are applied
+-- and deleted when order is created, so reordered messages are not lost
+CREATE TABLE IF NOT EXISTS pending_status_changes (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid TEXT NOT NULL,
+    status TEXT NOT NULL,
+    reason TEXT,
+    changed_at TIMESTAMPTZ NOT NULL,
+    source JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (order_uid, status)
+);